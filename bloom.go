@@ -0,0 +1,204 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrBloomFilterFile means a Bloom filter file is read with Reader.
+var ErrBloomFilterFile = errors.New("unikmer: Bloom filter file, please read it with ReadBloomFilter")
+
+// ErrNotBloomFilterFile means a normal binary file is read with ReadBloomFilter.
+var ErrNotBloomFilterFile = errors.New("unikmer: not a Bloom filter file")
+
+// ErrInvalidFPR means the false positive rate is not in range (0, 1).
+var ErrInvalidFPR = errors.New("unikmer: false positive rate should be in range (0, 1)")
+
+// BloomFilter is a Bloom filter of k-mers for probabilistic membership query.
+// Positions of a k-mer are computed with double hashing from Hash64 or Hash2.
+type BloomFilter struct {
+	Header
+	NumBits   uint64 // number of bits
+	NumHashes int    // number of hash functions
+
+	bits []uint64
+}
+
+// NewBloomFilter creates a Bloom filter for n k-mers with a false positive rate of fpr.
+// Flags of the k-mers, e.g., UNIK_CANONICAL, are saved in the header.
+func NewBloomFilter(k int, flag uint32, n int64, fpr float64) (*BloomFilter, error) {
+	if k <= 0 || k > MaxK2 {
+		return nil, ErrKOverflow2
+	}
+	if fpr <= 0 || fpr >= 1 {
+		return nil, ErrInvalidFPR
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	// m = -n*ln(p)/(ln2)^2, h = m/n*ln2
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpr) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	h := int(math.Round(float64(m) / float64(n) * math.Ln2))
+	if h < 1 {
+		h = 1
+	}
+
+	flag &^= UNIK_COMPACT | UNIK_SORTED | UNIK_COUNT | UNIK_SKETCH
+	return &BloomFilter{
+		Header:    Header{MainVersion: MainVersion, MinorVersion: MinorVersion, K: k, Words: wordsOfK(k), Flag: flag | UNIK_BLOOM, Number: 0},
+		NumBits:   m,
+		NumHashes: h,
+		bits:      make([]uint64, m/64),
+	}, nil
+}
+
+// Add adds a KmerCode (k <= 32).
+func (bf *BloomFilter) Add(kcode KmerCode) {
+	bf.add(Hash64(kcode.Code))
+}
+
+// Add2 adds a KmerCode2 (k > 32).
+func (bf *BloomFilter) Add2(kcode KmerCode2) {
+	bf.add(Hash2(kcode.Code))
+}
+
+// Contains checks whether a KmerCode (k <= 32) may be in the set.
+// False positive is possible while false negative is not.
+func (bf *BloomFilter) Contains(kcode KmerCode) bool {
+	return bf.contains(Hash64(kcode.Code))
+}
+
+// Contains2 checks whether a KmerCode2 (k > 32) may be in the set.
+func (bf *BloomFilter) Contains2(kcode KmerCode2) bool {
+	return bf.contains(Hash2(kcode.Code))
+}
+
+func (bf *BloomFilter) add(h1 uint64) {
+	h2 := Hash64(h1) | 1
+	var p uint64
+	for i := 0; i < bf.NumHashes; i++ {
+		p = h1 % bf.NumBits
+		bf.bits[p>>6] |= 1 << (p & 63)
+		h1 += h2
+	}
+	bf.Number++
+}
+
+func (bf *BloomFilter) contains(h1 uint64) bool {
+	h2 := Hash64(h1) | 1
+	var p uint64
+	for i := 0; i < bf.NumHashes; i++ {
+		p = h1 % bf.NumBits
+		if bf.bits[p>>6]&(1<<(p&63)) == 0 {
+			return false
+		}
+		h1 += h2
+	}
+	return true
+}
+
+// FPR returns the expected false positive rate with the number of added k-mers.
+func (bf *BloomFilter) FPR() float64 {
+	return math.Pow(1-math.Exp(-float64(bf.NumHashes)*float64(bf.Number)/float64(bf.NumBits)), float64(bf.NumHashes))
+}
+
+// WriteTo writes the Bloom filter, with the same header as binary k-mer files.
+func (bf *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	writer := &Writer{Header: bf.Header, w: cw}
+	err := writer.WriteHeader()
+	if err != nil {
+		return cw.n, err
+	}
+
+	err = binary.Write(cw, be, [2]uint64{bf.NumBits, uint64(bf.NumHashes)})
+	if err != nil {
+		return cw.n, err
+	}
+
+	buf := make([]byte, 8*1024)
+	var j int
+	for i, v := range bf.bits {
+		j = (i & 1023) << 3
+		be.PutUint64(buf[j:j+8], v)
+		if j == len(buf)-8 || i == len(bf.bits)-1 {
+			_, err = cw.Write(buf[:j+8])
+			if err != nil {
+				return cw.n, err
+			}
+		}
+	}
+	return cw.n, nil
+}
+
+// ReadBloomFilter reads a Bloom filter written by WriteTo.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	reader := &Reader{r: r}
+	err := reader.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if reader.Flag&UNIK_BLOOM == 0 {
+		return nil, ErrNotBloomFilterFile
+	}
+
+	var params [2]uint64
+	err = binary.Read(r, be, &params)
+	if err != nil {
+		return nil, err
+	}
+	if params[0] == 0 || params[0]%64 != 0 || params[1] == 0 {
+		return nil, ErrInvalidFileFormat
+	}
+
+	bf := &BloomFilter{
+		Header:    reader.Header,
+		NumBits:   params[0],
+		NumHashes: int(params[1]),
+		bits:      make([]uint64, params[0]/64),
+	}
+
+	buf := make([]byte, 8*1024)
+	var n, j int
+	for i := 0; i < len(bf.bits); i += n {
+		n = len(bf.bits) - i
+		if n > 1024 {
+			n = 1024
+		}
+		_, err = io.ReadFull(r, buf[:n<<3])
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, ErrBrokenFile
+			}
+			return nil, err
+		}
+		for j = 0; j < n; j++ {
+			bf.bits[i+j] = be.Uint64(buf[j<<3 : j<<3+8])
+		}
+	}
+	return bf, nil
+}
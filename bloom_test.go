@@ -0,0 +1,98 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	n := 100000
+	fpr := 0.01
+	a, b := twoSets(n / 2) // a and b share n/2 k-mers
+
+	bf, err := NewBloomFilter(21, UNIK_CANONICAL, int64(len(a)), fpr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range a {
+		bf.Add(KmerCode{Code: code, K: 21})
+	}
+	bf.Meta = map[string]string{"source": "a.unik"}
+
+	var buf bytes.Buffer
+	nBytes, err := bf.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if nBytes != int64(len(data)) {
+		t.Errorf("number of written bytes mismatch: %d vs %d", nBytes, len(data))
+	}
+
+	if _, err = NewReader(bytes.NewReader(data)); err != ErrBloomFilterFile {
+		t.Errorf("Bloom filter file should not be read by Reader")
+	}
+
+	bf2, err := ReadBloomFilter(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bf2.K != 21 || bf2.Flag&UNIK_CANONICAL == 0 || bf2.Number != int64(len(a)) ||
+		bf2.NumBits != bf.NumBits || bf2.NumHashes != bf.NumHashes || bf2.Meta["source"] != "a.unik" {
+		t.Errorf("Bloom filter mismatch after writing and reading")
+	}
+
+	for _, code := range a {
+		if !bf2.Contains(KmerCode{Code: code, K: 21}) {
+			t.Fatalf("false negative: %d", code)
+		}
+	}
+	var fp int
+	for _, code := range b[n/2:] { // k-mers not in a
+		if bf2.Contains(KmerCode{Code: code, K: 21}) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / float64(n/2); rate > 2*fpr {
+		t.Errorf("false positive rate too high: %f > %f", rate, fpr)
+	}
+
+	if _, err = ReadBloomFilter(bytes.NewReader(data[:len(data)-8])); err != ErrBrokenFile {
+		t.Errorf("broken file not detected")
+	}
+}
+
+func TestBloomFilter2(t *testing.T) {
+	bf, _ := NewBloomFilter(51, 0, 1000, 0.001)
+	codes := make([]KmerCode2, 1000)
+	for i := range codes {
+		codes[i] = KmerCode2{Code: mask2([2]uint64{rand.Uint64(), rand.Uint64()}, 51), K: 51}
+		bf.Add2(codes[i])
+	}
+	for _, kcode := range codes {
+		if !bf.Contains2(kcode) {
+			t.Fatalf("false negative: %s", kcode)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// ChecksumMagic is the magic number at the end of the checksum trailer.
+var ChecksumMagic = [8]byte{'.', 'u', 'n', 'i', 'k', 'c', 'r', 'c'}
+
+// ErrTruncatedFile means the file is truncated, i.e., the checksum trailer is missing.
+var ErrTruncatedFile = errors.New("unikmer: file truncated")
+
+// ErrChecksumMismatch means the number of k-mers or the checksum of data
+// does not match the values saved in the checksum trailer.
+var ErrChecksumMismatch = errors.New("unikmer: checksum mismatch, file corrupted")
+
+// Layout of the checksum trailer of file with flag UNIK_CHECKSUM,
+// which is right after all other data:
+//
+//	number of k-mers (uint64), CRC32C of all bytes between the header and
+//	the trailer (uint32), ChecksumMagic
+//
+// The trailer is written by Writer.Flush, and validated by Reader when
+// reaching the end of file. Random access with SeekBlock or Seek skips
+// the validation.
+const checksumTrailerSize = 8 + 4 + 8
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// parseChecksumTrailer returns the number of k-mers and checksum in the trailer.
+func parseChecksumTrailer(trailer []byte) (uint64, uint32, error) {
+	if len(trailer) != checksumTrailerSize || !bytes.Equal(trailer[12:], ChecksumMagic[:]) {
+		return 0, 0, ErrTruncatedFile
+	}
+	return be.Uint64(trailer[0:8]), be.Uint32(trailer[8:12]), nil
+}
+
+// checksumWriter computes the checksum of data written.
+type checksumWriter struct {
+	w    io.Writer
+	crc  hash.Hash32
+	done bool // trailer written
+}
+
+func (cw *checksumWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.crc.Write(p[:n])
+	return n, err
+}
+
+// writeChecksum writes the checksum trailer.
+func (writer *Writer) writeChecksum() (err error) {
+	cw := writer.cw
+	if cw.done {
+		return nil
+	}
+	if err = writer.WriteHeader(); err != nil {
+		return err
+	}
+
+	var trailer [checksumTrailerSize]byte
+	be.PutUint64(trailer[0:8], uint64(writer.nWritten))
+	be.PutUint32(trailer[8:12], cw.crc.Sum32())
+	copy(trailer[12:], ChecksumMagic[:])
+	if _, err = cw.w.Write(trailer[:]); err != nil {
+		return err
+	}
+	cw.done = true
+	return nil
+}
+
+// checksumReader computes the checksum of data read, and holds back the last
+// checksumTrailerSize bytes which are the trailer when reaching the end of file.
+type checksumReader struct {
+	r          io.Reader
+	crc        hash.Hash32
+	buf        []byte
+	start, end int // unread data in buf
+	eof        bool
+}
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{r: r, crc: crc32.New(crc32cTable), buf: make([]byte, 65536)}
+}
+
+func (cr *checksumReader) Read(p []byte) (n int, err error) {
+	for !cr.eof && cr.end-cr.start < len(p)+checksumTrailerSize {
+		if cr.start > 0 {
+			cr.end = copy(cr.buf, cr.buf[cr.start:cr.end])
+			cr.start = 0
+		}
+		if cr.end == len(cr.buf) {
+			break
+		}
+		n, err = cr.r.Read(cr.buf[cr.end:])
+		cr.end += n
+		if err == io.EOF {
+			cr.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	n = cr.end - cr.start - checksumTrailerSize
+	if n <= 0 {
+		return 0, io.EOF
+	}
+	if n > len(p) {
+		n = len(p)
+	}
+	copy(p, cr.buf[cr.start:cr.start+n])
+	cr.crc.Write(p[:n])
+	cr.start += n
+	return n, nil
+}
+
+// checkEOF counts k-mers read, and validates the checksum trailer
+// when reaching the end of file.
+func (reader *Reader) checkEOF(err error) error {
+	switch err {
+	case nil:
+		reader.nRead++
+		return nil
+	case io.EOF:
+		if err = reader.checkTrailer(); err != nil {
+			return err
+		}
+		return io.EOF
+	case io.ErrUnexpectedEOF:
+		return ErrTruncatedFile
+	}
+	return err
+}
+
+// checkTrailer skips remaining data, e.g., the block index, and validates the checksum trailer.
+func (reader *Reader) checkTrailer() error {
+	cr := reader.cr
+	reader.cr = nil // only check once
+
+	if _, err := io.Copy(ioutil.Discard, reader.r); err != nil {
+		return err
+	}
+	n, crc, err := parseChecksumTrailer(cr.buf[cr.start:cr.end])
+	if err != nil {
+		return err
+	}
+	if n != uint64(reader.nRead) || crc != cr.crc.Sum32() {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func readAll(data []byte) (int, error) {
+	reader, err := NewReader(struct{ io.Reader }{bytes.NewReader(data)})
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for {
+		if reader.K > 32 {
+			_, err = reader.Read2()
+		} else {
+			_, err = reader.Read()
+		}
+		if err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		n++
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	for _, k := range []int{21, 41} {
+		for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_COUNT, UNIK_INDEXED} {
+			mers := genKmers(k, 1001, true)
+
+			var buf bytes.Buffer
+			writer, err := NewWriter(&buf, k, flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mer := range mers {
+				if err = writer.WriteKmer(mer); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			n, err := readAll(data)
+			if err != nil {
+				t.Fatalf("k=%d flag=%d: %s", k, flag, err)
+			}
+			if n != len(mers) {
+				t.Errorf("k=%d flag=%d: number mismatch: %d vs %d", k, flag, n, len(mers))
+			}
+
+			// truncated at the boundary of k-mers
+			if flag == 0 {
+				_, err = readAll(data[:len(data)-checksumTrailerSize-8*wordsOfK(k)])
+				if err != ErrTruncatedFile {
+					t.Errorf("k=%d flag=%d: truncated file not detected: %v", k, flag, err)
+				}
+			}
+
+			// corrupted checksum
+			data2 := make([]byte, len(data))
+			copy(data2, data)
+			data2[len(data2)-checksumTrailerSize+8] ^= 1
+			_, err = readAll(data2)
+			if err != ErrChecksumMismatch {
+				t.Errorf("k=%d flag=%d: corrupted file not detected: %v", k, flag, err)
+			}
+		}
+	}
+}
+
+func TestCorruptedControlByte(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, 21, UNIK_SORTED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	// control bytes of sorted k-mers only use the lowest 6 bits, or the highest bit for the last k-mer
+	buf.Write([]byte{92, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	buf.Write(make([]byte, checksumTrailerSize))
+
+	_, err = readAll(buf.Bytes())
+	if err != ErrBrokenFile {
+		t.Errorf("corrupted control byte not detected: %v", err)
+	}
+}
+
+func TestChecksumEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, 21, UNIK_SORTED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing is written before the header
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("%d bytes written by Flush before the header", buf.Len())
+	}
+
+	if err = writer.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := readAll(buf.Bytes())
+	if err != nil || n != 0 {
+		t.Errorf("empty file: %d k-mers, %v", n, err)
+	}
+}
+
+// k-mers written after an early Flush, as "unikmer dump" did, should be
+// read back, i.e., the trailer is only written after the header.
+func TestFlushBeforeWriting(t *testing.T) {
+	for _, k := range []int{21, 41} {
+		for _, flag := range []uint32{0, UNIK_SORTED, UNIK_INDEXED} {
+			mers := genKmers(k, 3, true)
+
+			var buf bytes.Buffer
+			writer, err := NewWriter(&buf, k, flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			for _, mer := range mers {
+				if err = writer.WriteKmer(mer); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := readAll(buf.Bytes())
+			if err != nil {
+				t.Fatalf("k=%d flag=%d: %s", k, flag, err)
+			}
+			if n != len(mers) {
+				t.Errorf("k=%d flag=%d: number mismatch: %d vs %d", k, flag, n, len(mers))
+			}
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+// IndexMagic is the magic number at the end of the block index footer.
+var IndexMagic = [8]byte{'.', 'u', 'n', 'i', 'k', 'i', 'd', 'x'}
+
+// DefaultIndexBlockSize is the default number of k-mers in a block of indexed file.
+const DefaultIndexBlockSize = 4096
+
+// ErrNotIndexed means the file has no block index.
+var ErrNotIndexed = errors.New("unikmer: file not indexed")
+
+// ErrNotSeekable means the underlying reader is not an io.ReadSeeker.
+var ErrNotSeekable = errors.New("unikmer: reader not seekable")
+
+// ErrInvalidIndexBlockSize means the block size is not positive,
+// or not even for sorted files.
+var ErrInvalidIndexBlockSize = errors.New("unikmer: invalid index block size")
+
+// Layout of file with flag UNIK_INDEXED:
+//
+//   header
+//   blocks, every block is a uvarint of the number of k-mers in the block
+//       followed by the k-mers. For sorted file, k-mers of a block are
+//       encoded independently of previous blocks.
+//   uvarint 0, the end of blocks
+//   index, entries of all blocks, every entry contains:
+//       offset of the block (uint64), number of k-mers (uint64),
+//       and code of the first k-mer (1 or 2 uint64s)
+//   number of blocks (uint64), number of all k-mers (int64), IndexMagic
+//
+// All offsets are relative to the start of the uncompressed file,
+// so indexed files should not be compressed.
+
+// IndexBlock is an entry of the block index.
+type IndexBlock struct {
+	Offset int64     // byte offset of the block in file
+	Count  int       // number of k-mers in the block
+	First  [2]uint64 // code of the first k-mer, First[1] is the code for K <= 32
+}
+
+// Index is the block index of a binary file.
+type Index struct {
+	Number int64 // number of all k-mers
+	Blocks []IndexBlock
+}
+
+// countingWriter counts the bytes written.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// writerIndex holds the state of an indexed Writer.
+type writerIndex struct {
+	dst       *countingWriter // the real destination
+	block     bytes.Buffer    // k-mers of current block
+	nInBlock  int
+	index     Index
+	blockSize int
+	done      bool
+}
+
+// newBlockIfNeeded is called before writing a k-mer to an indexed file.
+func (writer *Writer) newBlockIfNeeded(first [2]uint64) error {
+	idx := writer.idx
+	if idx.nInBlock == idx.blockSize {
+		err := writer.flushBlock()
+		if err != nil {
+			return err
+		}
+	}
+	if idx.nInBlock == 0 {
+		idx.index.Blocks = append(idx.index.Blocks, IndexBlock{Offset: idx.dst.n, First: first})
+		writer.offset = 0
+	}
+	idx.nInBlock++
+	return nil
+}
+
+// flushBlock writes k-mers of current block to the destination.
+func (writer *Writer) flushBlock() (err error) {
+	idx := writer.idx
+	if idx.nInBlock == 0 {
+		return nil
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(idx.nInBlock))
+	if _, err = idx.dst.Write(buf[:n]); err != nil {
+		return err
+	}
+	if _, err = idx.block.WriteTo(idx.dst); err != nil {
+		return err
+	}
+	idx.index.Blocks[len(idx.index.Blocks)-1].Count = idx.nInBlock
+	idx.index.Number += int64(idx.nInBlock)
+	idx.nInBlock = 0
+	return nil
+}
+
+// writeIndex writes the last block and the index footer.
+func (writer *Writer) writeIndex() (err error) {
+	idx := writer.idx
+	if idx.done {
+		return nil
+	}
+	if err = writer.WriteHeader(); err != nil {
+		return err
+	}
+	if err = writer.flushBlock(); err != nil {
+		return err
+	}
+
+	w := idx.dst
+	if _, err = w.Write([]byte{0}); err != nil {
+		return err
+	}
+	for _, b := range idx.index.Blocks {
+		if err = binary.Write(w, be, [2]uint64{uint64(b.Offset), uint64(b.Count)}); err != nil {
+			return err
+		}
+		if writer.Words == 1 {
+			err = binary.Write(w, be, b.First[1])
+		} else {
+			err = binary.Write(w, be, b.First)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = binary.Write(w, be, [2]uint64{uint64(len(idx.index.Blocks)), uint64(idx.index.Number)}); err != nil {
+		return err
+	}
+	if err = binary.Write(w, be, IndexMagic); err != nil {
+		return err
+	}
+	idx.done = true
+	return nil
+}
+
+// nextBlock reads the number of k-mers of the next block.
+func (reader *Reader) nextBlock() error {
+	n, err := binary.ReadUvarint(reader.br)
+	if err != nil {
+		if err == io.EOF {
+			return ErrBrokenFile
+		}
+		return err
+	}
+	if n == 0 {
+		reader.eof = true
+		return io.EOF
+	}
+	reader.remaining = int(n)
+	reader.offset = 0
+	return nil
+}
+
+// Index reads the block index from the end of file,
+// the underlying reader should be an io.ReadSeeker.
+func (reader *Reader) Index() (*Index, error) {
+	if reader.index != nil {
+		return reader.index, nil
+	}
+	if reader.Flag&UNIK_INDEXED == 0 {
+		return nil, ErrNotIndexed
+	}
+	rs := reader.rs
+	if rs == nil {
+		return nil, ErrNotSeekable
+	}
+
+	// keep current position for sequential reading
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	// trailer, followed by the checksum trailer
+	end := int64(-24)
+	if reader.Flag&UNIK_CHECKSUM > 0 {
+		end -= checksumTrailerSize
+	}
+	var trailer [2]uint64
+	var magic [8]byte
+	if _, err = rs.Seek(end, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	if err = binary.Read(rs, be, &trailer); err != nil {
+		return nil, err
+	}
+	if err = binary.Read(rs, be, &magic); err != nil {
+		return nil, err
+	}
+	if magic != IndexMagic {
+		return nil, ErrBrokenFile
+	}
+
+	// entries, which can not be more than the data between the magic number
+	// and the trailer, so a broken trailer does not cause huge allocation.
+	entrySize := int64(16 + 8*reader.Words)
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	maxBlocks := (size + end - int64(len(Magic))) / entrySize
+	if maxBlocks < 0 || trailer[0] > uint64(maxBlocks) {
+		return nil, ErrBrokenFile
+	}
+	nBlocks := int64(trailer[0])
+	if _, err = rs.Seek(end-nBlocks*entrySize, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	index := &Index{Number: int64(trailer[1]), Blocks: make([]IndexBlock, nBlocks)}
+	br := bufio.NewReader(io.LimitReader(rs, nBlocks*entrySize))
+	var entry [2]uint64
+	for i := range index.Blocks {
+		if err = binary.Read(br, be, &entry); err != nil {
+			return nil, err
+		}
+		b := &index.Blocks[i]
+		b.Offset, b.Count = int64(entry[0]), int(entry[1])
+		if reader.Words == 1 {
+			err = binary.Read(br, be, &b.First[1])
+		} else {
+			err = binary.Read(br, be, &b.First)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = rs.Seek(cur, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader.index = index
+	return index, nil
+}
+
+// SeekBlock moves the reader to the start of the i-th block,
+// the underlying reader should be an io.ReadSeeker.
+func (reader *Reader) SeekBlock(i int) error {
+	index, err := reader.Index()
+	if err != nil {
+		return err
+	}
+	if i < 0 || i >= len(index.Blocks) {
+		return io.EOF
+	}
+	if _, err = reader.rs.Seek(index.Blocks[i].Offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader.bufr.Reset(reader.rs)
+	reader.r = reader.bufr
+	reader.br = reader.bufr
+	reader.cr = nil // checksum is not validated in random access
+	reader.remaining = 0
+	reader.offset = 0
+	reader.prev = nil
+	reader.pending = nil
+	reader.eof = false
+	return nil
+}
+
+// Seek moves the reader of sorted file (K <= 32) to the first k-mer
+// not less than code, which is returned by the next call of Read.
+// So k-mers in a range could be read by Seek to the start and Read
+// till the end.
+func (reader *Reader) Seek(code uint64) error {
+	if !reader.sorted {
+		return ErrNotSorted
+	}
+	index, err := reader.Index()
+	if err != nil {
+		return err
+	}
+	blocks := index.Blocks
+	// the last block with first code <= code
+	i := sort.Search(len(blocks), func(i int) bool { return blocks[i].First[1] > code }) - 1
+	if i < 0 {
+		i = 0
+	}
+	if err = reader.SeekBlock(i); err != nil {
+		if err == io.EOF { // empty file
+			reader.eof = true
+			return nil
+		}
+		return err
+	}
+
+	var kcode KmerCode
+	var count uint32
+	for {
+		kcode, count, err = reader.ReadWithCount()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if kcode.Code >= code {
+			reader.pending = &kcode
+			reader.pendingCount = count
+			return nil
+		}
+	}
+}
+
+// Contains checks whether a k-mer exists in a sorted and indexed file (K <= 32),
+// by binary searching the block index and decoding only the block containing it.
+// The position of the reader is changed.
+func (reader *Reader) Contains(code uint64) (bool, error) {
+	err := reader.Seek(code)
+	if err != nil {
+		return false, err
+	}
+	kcode, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return kcode.Code == code, nil
+}
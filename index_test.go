@@ -0,0 +1,210 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func writeIndexed(t *testing.T, mers [][]byte, flag uint32, blockSize int) *bytes.Reader {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, len(mers[0]), flag|UNIK_INDEXED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.SetIndexBlockSize(blockSize); err != nil {
+		t.Fatal(err)
+	}
+	for _, mer := range mers {
+		if err = writer.WriteKmer(mer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestIndex(t *testing.T) {
+	for _, k := range []int{21, 31, 41} {
+		for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_SORTED | UNIK_COUNT} {
+			for _, n := range []int{1, 99, 100, 1001} {
+				mers := genKmers(k, n, true)
+				r := writeIndexed(t, mers, flag, 10)
+
+				reader, err := NewReader(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				index, err := reader.Index()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if index.Number != int64(n) || len(index.Blocks) != (n+9)/10 {
+					t.Fatalf("k=%d flag=%d n=%d: index mismatch: %d k-mers, %d blocks", k, flag, n, index.Number, len(index.Blocks))
+				}
+
+				// sequential reading after loading index
+				var kcode KmerCode
+				var kcode2 KmerCode2
+				var mer []byte
+				var i int
+				for ; ; i++ {
+					if k > 32 {
+						kcode2, err = reader.Read2()
+					} else {
+						kcode, err = reader.Read()
+					}
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						t.Fatal(err)
+					}
+					if k > 32 {
+						mer = kcode2.Bytes()
+					} else {
+						mer = kcode.Bytes()
+					}
+					if i >= n || !bytes.Equal(mer, mers[i]) {
+						t.Fatalf("k=%d flag=%d n=%d: data mismatch at %d", k, flag, n, i)
+					}
+				}
+				if i != n {
+					t.Errorf("k=%d flag=%d n=%d: number mismatch: %d", k, flag, n, i)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexCorrupted(t *testing.T) {
+	mers := genKmers(21, 100, true)
+	r := writeIndexed(t, mers, UNIK_SORTED, 10)
+	data := make([]byte, r.Len())
+	if _, err := r.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// number of blocks in the trailer
+	i := len(data) - checksumTrailerSize - 24
+	for _, n := range []uint64{1 << 62, 1<<64 - 1, uint64(len(data))} {
+		data2 := make([]byte, len(data))
+		copy(data2, data)
+		be.PutUint64(data2[i:i+8], n)
+
+		reader, err := NewReader(bytes.NewReader(data2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = reader.Index(); err != ErrBrokenFile {
+			t.Errorf("broken number of blocks (%d) not detected: %v", n, err)
+		}
+	}
+}
+
+func TestIndexSeek(t *testing.T) {
+	k := 21
+	n := 1001
+	mers := genKmers(k, n, true)
+	codes := make([]uint64, n)
+	for i, mer := range mers {
+		codes[i], _ = Encode(mer)
+	}
+	r := writeIndexed(t, mers, UNIK_SORTED, 16)
+
+	reader, err := NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kcode KmerCode
+	for i := 0; i < 100; i++ {
+		j := rand.Intn(n)
+		code := codes[j]
+		if i&1 == 1 && code > 0 {
+			code-- // not existed k-mer
+		}
+		if err = reader.Seek(code); err != nil {
+			t.Fatal(err)
+		}
+		// the first k-mer >= code
+		for j > 0 && codes[j-1] >= code {
+			j--
+		}
+		for end := j + 5; j < n && j < end; j++ {
+			kcode, err = reader.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kcode.Code != codes[j] {
+				t.Fatalf("seek %d: data mismatch: %d vs %d", code, kcode.Code, codes[j])
+			}
+		}
+	}
+
+	// beyond the last one
+	if err = reader.Seek(codes[n-1] + 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = reader.Read(); err != io.EOF {
+		t.Errorf("seek beyond the last k-mer: EOF expected")
+	}
+}
+
+func TestIndexContains(t *testing.T) {
+	k := 21
+	n := 10001
+	mers := genKmers(k, n, true)
+	m := make(map[uint64]struct{}, n)
+	for _, mer := range mers {
+		code, _ := Encode(mer)
+		m[code] = struct{}{}
+	}
+	r := writeIndexed(t, mers, UNIK_SORTED, DefaultIndexBlockSize)
+
+	reader, err := NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ok, ok2 bool
+	for _, mer := range genKmers(k, 1000, false) {
+		code, _ := Encode(mer)
+		ok, err = reader.Contains(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ok2 = m[code]
+		if ok != ok2 {
+			t.Errorf("contains mismatch: %s", mer)
+		}
+	}
+	for code := range m {
+		if ok, err = reader.Contains(code); err != nil || !ok {
+			t.Errorf("k-mer not found: %d", code)
+		}
+	}
+}
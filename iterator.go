@@ -0,0 +1,217 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+// nucl2bit maps A/C/G/T(U) to 2-bit codes, other bases are marked as 4.
+var nucl2bit [256]uint64
+
+func init() {
+	for i := range nucl2bit {
+		nucl2bit[i] = 4
+	}
+	nucl2bit['A'], nucl2bit['a'] = 0, 0
+	nucl2bit['C'], nucl2bit['c'] = 1, 1
+	nucl2bit['G'], nucl2bit['g'] = 2, 2
+	nucl2bit['T'], nucl2bit['t'] = 3, 3
+	nucl2bit['U'], nucl2bit['u'] = 3, 3
+}
+
+// KmerIterator iterates k-mers (k <= 32) of a sequence with rolling 2-bit
+// encoding. K-mers containing bases other than A/C/G/T/U are skipped, and
+// encoding restarts after these bases.
+type KmerIterator struct {
+	s         []byte
+	k         int
+	canonical bool
+	circular  bool
+
+	end   int // index of the last base to add, exclusive
+	j     int // index of the next base to add
+	n     int // number of consecutive valid bases in the window
+	mask  uint64
+	shift uint // bits to shift for the first base of the reverse complement code
+
+	fcode uint64 // code of the k-mer
+	rcode uint64 // code of the reverse complement k-mer
+
+	idx int // start position of current k-mer
+	num int // number of returned k-mers
+}
+
+// NewKmerIterator returns a KmerIterator of sequence s.
+// For circular genome, k-mers spanning the end and start of sequence are
+// also returned.
+func NewKmerIterator(s []byte, k int, canonical bool, circular bool) (*KmerIterator, error) {
+	if k <= 0 || k > 32 {
+		return nil, ErrKOverflow
+	}
+	iter := &KmerIterator{s: s, k: k, canonical: canonical, circular: circular}
+	iter.end = len(s)
+	if circular && len(s) >= k {
+		iter.end += k - 1
+	}
+	iter.mask = MaxCode[k]
+	iter.shift = uint(k-1) << 1
+	return iter, nil
+}
+
+// Next returns the next KmerCode, ok is false when all k-mers are returned.
+func (iter *KmerIterator) Next() (kcode KmerCode, ok bool) {
+	var v uint64
+	l := len(iter.s)
+	for iter.j < iter.end {
+		if iter.j < l {
+			v = nucl2bit[iter.s[iter.j]]
+		} else {
+			v = nucl2bit[iter.s[iter.j-l]]
+		}
+		iter.j++
+
+		if v > 3 { // restart
+			iter.n = 0
+			iter.fcode, iter.rcode = 0, 0
+			continue
+		}
+
+		iter.fcode = (iter.fcode<<2 | v) & iter.mask
+		iter.rcode = iter.rcode>>2 | (v^3)<<iter.shift
+		iter.n++
+		if iter.n < iter.k {
+			continue
+		}
+
+		iter.idx = iter.j - iter.k
+		iter.num++
+		if iter.canonical && iter.rcode < iter.fcode {
+			return KmerCode{iter.rcode, iter.k}, true
+		}
+		return KmerCode{iter.fcode, iter.k}, true
+	}
+	return KmerCode{}, false
+}
+
+// Index returns the 0-based start position of current k-mer in sequence.
+func (iter *KmerIterator) Index() int {
+	return iter.idx
+}
+
+// Skipped returns the number of k-mers skipped so far because of containing
+// bases other than A/C/G/T/U.
+func (iter *KmerIterator) Skipped() int {
+	return skippedWindows(iter.j, iter.k, iter.num)
+}
+
+func skippedWindows(j, k, num int) int {
+	if j < k {
+		return 0
+	}
+	return j - k + 1 - num
+}
+
+// KmerIterator2 iterates k-mers (k <= 64) of a sequence with rolling 2-bit
+// encoding in two-word codes. The rules are the same with KmerIterator.
+type KmerIterator2 struct {
+	s         []byte
+	k         int
+	canonical bool
+	circular  bool
+
+	end   int
+	j     int
+	n     int
+	shift uint
+
+	fcode [2]uint64
+	rcode [2]uint64
+
+	idx int
+	num int
+}
+
+// NewKmerIterator2 returns a KmerIterator2 of sequence s.
+func NewKmerIterator2(s []byte, k int, canonical bool, circular bool) (*KmerIterator2, error) {
+	if k <= 0 || k > MaxK2 {
+		return nil, ErrKOverflow2
+	}
+	iter := &KmerIterator2{s: s, k: k, canonical: canonical, circular: circular}
+	iter.end = len(s)
+	if circular && len(s) >= k {
+		iter.end += k - 1
+	}
+	iter.shift = uint(k-1) << 1
+	return iter, nil
+}
+
+// Next returns the next KmerCode2, ok is false when all k-mers are returned.
+func (iter *KmerIterator2) Next() (kcode KmerCode2, ok bool) {
+	var v uint64
+	l := len(iter.s)
+	for iter.j < iter.end {
+		if iter.j < l {
+			v = nucl2bit[iter.s[iter.j]]
+		} else {
+			v = nucl2bit[iter.s[iter.j-l]]
+		}
+		iter.j++
+
+		if v > 3 { // restart
+			iter.n = 0
+			iter.fcode, iter.rcode = [2]uint64{}, [2]uint64{}
+			continue
+		}
+
+		iter.fcode[0] = iter.fcode[0]<<2 | iter.fcode[1]>>62
+		iter.fcode[1] = iter.fcode[1]<<2 | v
+		iter.fcode = mask2(iter.fcode, iter.k)
+
+		iter.rcode[1] = iter.rcode[1]>>2 | iter.rcode[0]<<62
+		iter.rcode[0] >>= 2
+		if iter.shift >= 64 {
+			iter.rcode[0] |= (v ^ 3) << (iter.shift - 64)
+		} else {
+			iter.rcode[1] |= (v ^ 3) << iter.shift
+		}
+
+		iter.n++
+		if iter.n < iter.k {
+			continue
+		}
+
+		iter.idx = iter.j - iter.k
+		iter.num++
+		if iter.canonical && Less2(iter.rcode, iter.fcode) {
+			return KmerCode2{iter.rcode, iter.k}, true
+		}
+		return KmerCode2{iter.fcode, iter.k}, true
+	}
+	return KmerCode2{}, false
+}
+
+// Index returns the 0-based start position of current k-mer in sequence.
+func (iter *KmerIterator2) Index() int {
+	return iter.idx
+}
+
+// Skipped returns the number of k-mers skipped so far because of containing
+// bases other than A/C/G/T/U.
+func (iter *KmerIterator2) Skipped() int {
+	return skippedWindows(iter.j, iter.k, iter.num)
+}
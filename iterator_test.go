@@ -0,0 +1,175 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomSeq(n int, withN bool) []byte {
+	s := make([]byte, n)
+	for i := range s {
+		if withN && rand.Intn(50) == 0 {
+			s[i] = 'N'
+			continue
+		}
+		s[i] = bit2base[rand.Intn(4)]
+	}
+	return s
+}
+
+// slidingKmers computes k-mers in the slow way.
+func slidingKmers(s []byte, k int, canonical bool, circular bool) ([]int, []KmerCode2) {
+	if circular && len(s) >= k {
+		s = append(append([]byte{}, s...), s[0:k-1]...)
+	}
+	var idxs []int
+	var codes []KmerCode2
+	var kcode KmerCode2
+	var valid bool
+	for i := 0; i+k <= len(s); i++ {
+		valid = true
+		for _, b := range s[i : i+k] {
+			if nucl2bit[b] > 3 {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		kcode, _ = NewKmerCode2(s[i : i+k])
+		if canonical {
+			kcode = kcode.Canonical()
+		}
+		idxs = append(idxs, i)
+		codes = append(codes, kcode)
+	}
+	return idxs, codes
+}
+
+func TestKmerIterator(t *testing.T) {
+	for _, withN := range []bool{false, true} {
+		s := randomSeq(1000, withN)
+		for _, k := range []int{1, 5, 21, 31, 32} {
+			for _, canonical := range []bool{false, true} {
+				for _, circular := range []bool{false, true} {
+					idxs, codes := slidingKmers(s, k, canonical, circular)
+
+					iter, err := NewKmerIterator(s, k, canonical, circular)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					var i int
+					for {
+						kcode, ok := iter.Next()
+						if !ok {
+							break
+						}
+						if i >= len(codes) {
+							t.Errorf("k=%d, canonical=%v, circular=%v: too many k-mers", k, canonical, circular)
+							break
+						}
+						if iter.Index() != idxs[i] || kcode.Code != codes[i].Code[1] {
+							t.Errorf("k=%d, canonical=%v, circular=%v: k-mer %d mismatch: %s@%d vs %s@%d",
+								k, canonical, circular, i, kcode, iter.Index(), codes[i], idxs[i])
+							break
+						}
+						i++
+					}
+					if i != len(codes) {
+						t.Errorf("k=%d, canonical=%v, circular=%v: number mismatch: %d vs %d", k, canonical, circular, i, len(codes))
+					}
+					if i+iter.Skipped() != windows(len(s), k, circular) {
+						t.Errorf("k=%d, canonical=%v, circular=%v: skipped number error: %d + %d != %d",
+							k, canonical, circular, i, iter.Skipped(), windows(len(s), k, circular))
+					}
+					if !withN && iter.Skipped() != 0 {
+						t.Errorf("k=%d, canonical=%v, circular=%v: no k-mer should be skipped", k, canonical, circular)
+					}
+				}
+			}
+		}
+	}
+}
+
+func windows(l, k int, circular bool) int {
+	if l < k {
+		return 0
+	}
+	if circular {
+		return l
+	}
+	return l - k + 1
+}
+
+func TestKmerIterator2(t *testing.T) {
+	for _, withN := range []bool{false, true} {
+		s := randomSeq(1000, withN)
+		for _, k := range []int{5, 32, 33, 51, 63, 64} {
+			for _, canonical := range []bool{false, true} {
+				for _, circular := range []bool{false, true} {
+					idxs, codes := slidingKmers(s, k, canonical, circular)
+
+					iter, err := NewKmerIterator2(s, k, canonical, circular)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					var i int
+					for {
+						kcode, ok := iter.Next()
+						if !ok {
+							break
+						}
+						if i >= len(codes) {
+							t.Errorf("k=%d, canonical=%v, circular=%v: too many k-mers", k, canonical, circular)
+							break
+						}
+						if iter.Index() != idxs[i] || !kcode.Equal(codes[i]) {
+							t.Errorf("k=%d, canonical=%v, circular=%v: k-mer %d mismatch: %s@%d vs %s@%d",
+								k, canonical, circular, i, kcode, iter.Index(), codes[i], idxs[i])
+							break
+						}
+						i++
+					}
+					if i != len(codes) {
+						t.Errorf("k=%d, canonical=%v, circular=%v: number mismatch: %d vs %d", k, canonical, circular, i, len(codes))
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkKmerIteratorK31(b *testing.B) {
+	s := randomSeq(10000, false)
+	for i := 0; i < b.N; i++ {
+		iter, _ := NewKmerIterator(s, 31, true, false)
+		for {
+			if _, ok := iter.Next(); !ok {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"errors"
+)
+
+// MaxK2 is the maximum K supported by two-word KmerCode2.
+const MaxK2 = 64
+
+// ErrKOverflow2 means K > 64.
+var ErrKOverflow2 = errors.New("unikmer: K-mer size (1-64) overflow")
+
+// Encode2 converts byte slice to bits in two uint64 words,
+// code[0] for the higher bits and code[1] for the lower 64 bits.
+//
+// Degenerate bases are handled the same way as Encode.
+func Encode2(kmer []byte) (code [2]uint64, err error) {
+	if len(kmer) == 0 || len(kmer) > MaxK2 {
+		return code, ErrKOverflow2
+	}
+
+	var v uint64
+	for _, b := range kmer {
+		code[0] = code[0]<<2 | code[1]>>62
+		code[1] <<= 2
+		v = base2bit[b]
+		if v > 3 {
+			return code, ErrIllegalBase
+		}
+		code[1] |= v
+	}
+	return code, nil
+}
+
+// MustEncodeFromFormerKmer2 encodes from former the k-mer,
+// assuming the k-mer and leftKmer are both OK.
+func MustEncodeFromFormerKmer2(kmer []byte, leftKmer []byte, leftCode [2]uint64) ([2]uint64, error) {
+	leftCode[0] = leftCode[0]<<2 | leftCode[1]>>62
+	leftCode[1] <<= 2
+	leftCode = mask2(leftCode, len(kmer))
+	v := base2bit[kmer[len(kmer)-1]]
+	if v > 3 {
+		return leftCode, ErrIllegalBase
+	}
+	leftCode[1] |= v
+	return leftCode, nil
+}
+
+// mask2 clears bits beyond 2*k.
+func mask2(code [2]uint64, k int) [2]uint64 {
+	if k <= 32 {
+		code[0] = 0
+		if k < 32 {
+			code[1] &= 1<<uint(k<<1) - 1
+		}
+		return code
+	}
+	if k < 64 {
+		code[0] &= 1<<uint((k-32)<<1) - 1
+	}
+	return code
+}
+
+// Reverse2 returns code of the reversed sequence.
+func Reverse2(code [2]uint64, k int) (c [2]uint64) {
+	if k <= 0 || k > MaxK2 {
+		panic(ErrKOverflow2)
+	}
+	for i := 0; i < k; i++ {
+		c[0] = c[0]<<2 | c[1]>>62
+		c[1] = c[1]<<2 | code[1]&3
+		code[1] = code[1]>>2 | code[0]<<62
+		code[0] >>= 2
+	}
+	return
+}
+
+// Complement2 returns code of complement sequence.
+func Complement2(code [2]uint64, k int) (c [2]uint64) {
+	if k <= 0 || k > MaxK2 {
+		panic(ErrKOverflow2)
+	}
+	return mask2([2]uint64{^code[0], ^code[1]}, k)
+}
+
+// RevComp2 returns code of reverse complement sequence.
+func RevComp2(code [2]uint64, k int) (c [2]uint64) {
+	if k <= 0 || k > MaxK2 {
+		panic(ErrKOverflow2)
+	}
+	for i := 0; i < k; i++ {
+		c[0] = c[0]<<2 | c[1]>>62
+		c[1] = c[1]<<2 | code[1]&3 ^ 3
+		code[1] = code[1]>>2 | code[0]<<62
+		code[0] >>= 2
+	}
+	return
+}
+
+// Decode2 converts the two-word code to original seq
+func Decode2(code [2]uint64, k int) []byte {
+	if k <= 0 || k > MaxK2 {
+		panic(ErrKOverflow2)
+	}
+	if mask2(code, k) != code {
+		panic(ErrCodeOverflow)
+	}
+	kmer := make([]byte, k)
+	for i := 0; i < k; i++ {
+		kmer[k-1-i] = bit2base[code[1]&3]
+		code[1] = code[1]>>2 | code[0]<<62
+		code[0] >>= 2
+	}
+	return kmer
+}
+
+// Less2 checks whether code a is smaller than code b.
+func Less2(a, b [2]uint64) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
+
+// KmerCode2 is a struct representing a k-mer (k <= 64) in two 64-bits words.
+type KmerCode2 struct {
+	Code [2]uint64
+	K    int
+}
+
+// NewKmerCode2 returns a new KmerCode2 struct from byte slice.
+func NewKmerCode2(kmer []byte) (KmerCode2, error) {
+	code, err := Encode2(kmer)
+	if err != nil {
+		return KmerCode2{}, err
+	}
+	return KmerCode2{code, len(kmer)}, err
+}
+
+// NewKmerCodeMustFromFormerOne2 computes KmerCode2 from the Former consecutive k-mer,
+// assuming the k-mer and leftKmer are both OK.
+func NewKmerCodeMustFromFormerOne2(kmer []byte, leftKmer []byte, preKcode KmerCode2) (KmerCode2, error) {
+	code, err := MustEncodeFromFormerKmer2(kmer, leftKmer, preKcode.Code)
+	if err != nil {
+		return KmerCode2{}, err
+	}
+	return KmerCode2{code, len(kmer)}, err
+}
+
+// Equal checks wether two KmerCode2s are the same.
+func (kcode KmerCode2) Equal(kcode2 KmerCode2) bool {
+	return kcode.K == kcode2.K && kcode.Code == kcode2.Code
+}
+
+// Rev returns KmerCode2 of the reverse sequence.
+func (kcode KmerCode2) Rev() KmerCode2 {
+	return KmerCode2{Reverse2(kcode.Code, kcode.K), kcode.K}
+}
+
+// Comp returns KmerCode2 of the complement sequence.
+func (kcode KmerCode2) Comp() KmerCode2 {
+	return KmerCode2{Complement2(kcode.Code, kcode.K), kcode.K}
+}
+
+// RevComp returns KmerCode2 of the reverse complement sequence.
+func (kcode KmerCode2) RevComp() KmerCode2 {
+	return KmerCode2{RevComp2(kcode.Code, kcode.K), kcode.K}
+}
+
+// Canonical returns its canonical kmer
+func (kcode KmerCode2) Canonical() KmerCode2 {
+	rcKcode := kcode.RevComp()
+	if Less2(rcKcode.Code, kcode.Code) {
+		return rcKcode
+	}
+	return kcode
+}
+
+// Bytes returns k-mer in []byte.
+func (kcode KmerCode2) Bytes() []byte {
+	return Decode2(kcode.Code, kcode.K)
+}
+
+// String returns k-mer in string
+func (kcode KmerCode2) String() string {
+	return string(Decode2(kcode.Code, kcode.K))
+}
+
+// BitsString returns code to string
+func (kcode KmerCode2) BitsString() string {
+	var buf bytes.Buffer
+	for _, b := range Decode2(kcode.Code, kcode.K) {
+		buf.WriteString(bit2str[base2bit[b]])
+	}
+	return buf.String()
+}
@@ -0,0 +1,129 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+var randomMers2 [][]byte
+
+func init() {
+	randomMers2 = make([][]byte, randomMersN)
+	for i := 0; i < randomMersN; i++ {
+		randomMers2[i] = make([]byte, rand.Intn(MaxK2)+1)
+		for j := range randomMers2[i] {
+			randomMers2[i][j] = bit2base[rand.Intn(4)]
+		}
+	}
+}
+
+func TestEncodeDecode2(t *testing.T) {
+	var kcode KmerCode2
+	var err error
+	for _, mer := range randomMers2 {
+		kcode, err = NewKmerCode2(mer) // encode
+		if err != nil {
+			t.Errorf("Encode error: %s", mer)
+		}
+
+		if !bytes.Equal(mer, kcode.Bytes()) { // decode
+			t.Errorf("Decode error: %s != %s ", mer, kcode.Bytes())
+		}
+	}
+
+	// consistent with single-word code for k <= 32
+	var code uint64
+	var code2 [2]uint64
+	for _, mer := range randomMers {
+		code, _ = Encode(mer)
+		code2, _ = Encode2(mer)
+		if code2[0] != 0 || code2[1] != code {
+			t.Errorf("Encode2 error for %s: %v != %d", mer, code2, code)
+		}
+	}
+}
+
+func TestEncodeFromFormerKmer2(t *testing.T) {
+	var err error
+	seq := bytes.Repeat(benchMer, 3)
+	for _, k := range []int{5, 31, 32, 33, 50, 64} {
+		var code, code0, pCode [2]uint64
+		for i := 0; i < len(seq)-k; i++ {
+			kmer := seq[i : i+k]
+			if i == 0 {
+				pCode, err = Encode2(kmer)
+				if err != nil {
+					t.Errorf("Encode error: %s", kmer)
+				}
+				continue
+			}
+			code, err = MustEncodeFromFormerKmer2(kmer, seq[i-1:i+k-1], pCode)
+			if err != nil {
+				t.Errorf("Encode error: %s", kmer)
+			}
+
+			code0, err = Encode2(kmer)
+			if err != nil {
+				t.Errorf("Encode error: %s", kmer)
+			}
+			if code0 != code {
+				t.Errorf("MustEncodeFromFormerKmer2 error for %s: wrong %v != right %v", kmer, code, code0)
+			}
+
+			pCode = code
+		}
+	}
+}
+
+func TestRevComp2(t *testing.T) {
+	var kcode KmerCode2
+	var rc []byte
+	for _, mer := range randomMers2 {
+		kcode, _ = NewKmerCode2(mer)
+
+		if !kcode.Rev().Rev().Equal(kcode) {
+			t.Errorf("Rev() error: %s, Rev(): %s", kcode, kcode.Rev())
+		}
+
+		if !kcode.Comp().Comp().Equal(kcode) {
+			t.Errorf("Comp() error: %s, Comp(): %s", kcode, kcode.Comp())
+		}
+
+		if !kcode.Comp().Rev().Equal(kcode.RevComp()) {
+			t.Errorf("Rev().Comp() error: %s, Rev(): %s, Comp(): %s, RevComp: %s", kcode, kcode.Rev(), kcode.Comp(), kcode.RevComp())
+		}
+
+		rc = make([]byte, len(mer))
+		for i, b := range mer {
+			rc[len(mer)-1-i] = bit2base[base2bit[b]^3]
+		}
+		if kcode.RevComp().String() != string(rc) {
+			t.Errorf("RevComp() error: %s, RevComp(): %s, expected: %s", kcode, kcode.RevComp(), rc)
+		}
+
+		if kcode.Canonical().String() > kcode.RevComp().String() {
+			t.Errorf("Canonical() error: %s, Canonical(): %s", kcode, kcode.Canonical())
+		}
+	}
+}
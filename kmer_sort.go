@@ -60,3 +60,21 @@ func (codes CodeSlice) Swap(i, j int) {
 func (codes CodeSlice) Less(i, j int) bool {
 	return codes[i] < codes[j]
 }
+
+// CodeSlice2 is a slice of two-word Kmer code ([2]uint64), for sorting
+type CodeSlice2 [][2]uint64
+
+// Len return length of the slice
+func (codes CodeSlice2) Len() int {
+	return len(codes)
+}
+
+// Swap swaps two elements
+func (codes CodeSlice2) Swap(i, j int) {
+	codes[i], codes[j] = codes[j], codes[i]
+}
+
+// Less simply compare two KmerCode2
+func (codes CodeSlice2) Less(i, j int) bool {
+	return Less2(codes[i], codes[j])
+}
@@ -0,0 +1,148 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import "math/bits"
+
+// MaxKOfBitmapKmerSet is the maximum K of KmerSet in bitmap,
+// which takes 4^K bits (32 MB for K = 14).
+const MaxKOfBitmapKmerSet = 14
+
+// KmerSet is a set of k-mers (K <= 32) in uint64 codes.
+// Use NewKmerSet to create one with a suitable backend for K.
+type KmerSet interface {
+	// Add adds a k-mer and returns true if it is new.
+	Add(code uint64) bool
+	// Contains checks whether a k-mer is in the set.
+	Contains(code uint64) bool
+	// Remove removes a k-mer.
+	Remove(code uint64)
+	// Len returns the number of k-mers.
+	Len() int
+	// Iterate calls f for every k-mer, removing the current k-mer in f is allowed.
+	Iterate(f func(code uint64))
+	// Sorted tells whether k-mers are iterated in ascending order.
+	Sorted() bool
+	// Clone returns a copy of the set.
+	Clone() KmerSet
+}
+
+// NewKmerSet creates a KmerSet for k-mers of length k.
+// For K <= MaxKOfBitmapKmerSet, k-mers are saved in a bitmap of 4^K bits,
+// which is faster than a map and k-mers are iterated in ascending order.
+// Otherwise, a map with an initial size is used.
+func NewKmerSet(k int, size int) (KmerSet, error) {
+	if k <= 0 || k > 32 {
+		return nil, ErrKOverflow
+	}
+	if k <= MaxKOfBitmapKmerSet {
+		return &bitmapKmerSet{bits: make([]uint64, (uint64(1)<<uint(k<<1)+63)>>6)}, nil
+	}
+	if size < 0 {
+		size = 0
+	}
+	return mapKmerSet(make(map[uint64]struct{}, size)), nil
+}
+
+// bitmapKmerSet is a KmerSet with a bit for every possible k-mer.
+type bitmapKmerSet struct {
+	bits []uint64
+	n    int
+}
+
+func (s *bitmapKmerSet) Add(code uint64) bool {
+	i, b := code>>6, uint64(1)<<(code&63)
+	if s.bits[i]&b != 0 {
+		return false
+	}
+	s.bits[i] |= b
+	s.n++
+	return true
+}
+
+func (s *bitmapKmerSet) Contains(code uint64) bool {
+	return s.bits[code>>6]&(1<<(code&63)) != 0
+}
+
+func (s *bitmapKmerSet) Remove(code uint64) {
+	i, b := code>>6, uint64(1)<<(code&63)
+	if s.bits[i]&b != 0 {
+		s.bits[i] &^= b
+		s.n--
+	}
+}
+
+func (s *bitmapKmerSet) Len() int { return s.n }
+
+func (s *bitmapKmerSet) Iterate(f func(code uint64)) {
+	var w uint64
+	for i := range s.bits {
+		w = s.bits[i]
+		for w != 0 {
+			f(uint64(i)<<6 | uint64(bits.TrailingZeros64(w)))
+			w &= w - 1
+		}
+	}
+}
+
+func (s *bitmapKmerSet) Sorted() bool { return true }
+
+func (s *bitmapKmerSet) Clone() KmerSet {
+	s2 := &bitmapKmerSet{bits: make([]uint64, len(s.bits)), n: s.n}
+	copy(s2.bits, s.bits)
+	return s2
+}
+
+// mapKmerSet is a KmerSet backed by a map.
+type mapKmerSet map[uint64]struct{}
+
+func (s mapKmerSet) Add(code uint64) bool {
+	if _, ok := s[code]; ok {
+		return false
+	}
+	s[code] = struct{}{}
+	return true
+}
+
+func (s mapKmerSet) Contains(code uint64) bool {
+	_, ok := s[code]
+	return ok
+}
+
+func (s mapKmerSet) Remove(code uint64) { delete(s, code) }
+
+func (s mapKmerSet) Len() int { return len(s) }
+
+func (s mapKmerSet) Iterate(f func(code uint64)) {
+	for code := range s {
+		f(code)
+	}
+}
+
+func (s mapKmerSet) Sorted() bool { return false }
+
+func (s mapKmerSet) Clone() KmerSet {
+	s2 := make(map[uint64]struct{}, len(s))
+	for code := range s {
+		s2[code] = struct{}{}
+	}
+	return mapKmerSet(s2)
+}
@@ -0,0 +1,92 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestKmerSet(t *testing.T) {
+	for _, k := range []int{1, 5, 11, MaxKOfBitmapKmerSet, MaxKOfBitmapKmerSet + 1, 21, 32} {
+		s, err := NewKmerSet(k, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Sorted() != (k <= MaxKOfBitmapKmerSet) {
+			t.Errorf("k=%d: unexpected backend", k)
+		}
+
+		var mask uint64 = (1 << uint(k<<1)) - 1
+		if k == 32 {
+			mask = ^uint64(0)
+		}
+		m := make(map[uint64]struct{}, 1000)
+		var code uint64
+		for i := 0; i < 1000; i++ {
+			code = rand.Uint64() & mask
+			_, ok := m[code]
+			m[code] = struct{}{}
+			if s.Add(code) == ok {
+				t.Fatalf("k=%d: Add returned wrong value for %d", k, code)
+			}
+		}
+		if s.Len() != len(m) {
+			t.Fatalf("k=%d: number mismatch: %d vs %d", k, s.Len(), len(m))
+		}
+		for code = range m {
+			if !s.Contains(code) {
+				t.Fatalf("k=%d: k-mer %d missing", k, code)
+			}
+		}
+
+		s2 := s.Clone()
+		codes := make([]uint64, 0, s.Len())
+		s.Iterate(func(code uint64) {
+			codes = append(codes, code)
+			if code&1 == 0 {
+				s.Remove(code)
+			}
+		})
+		if len(codes) != len(m) {
+			t.Fatalf("k=%d: iterated %d k-mers, expected %d", k, len(codes), len(m))
+		}
+		if s.Sorted() && !sort.IsSorted(CodeSlice(codes)) {
+			t.Errorf("k=%d: k-mers not iterated in order", k)
+		}
+		for _, code = range codes {
+			if s.Contains(code) == (code&1 == 0) {
+				t.Fatalf("k=%d: Remove error for %d", k, code)
+			}
+			if !s2.Contains(code) {
+				t.Fatalf("k=%d: clone affected by Remove", k)
+			}
+		}
+		if s2.Len() != len(m) {
+			t.Errorf("k=%d: number of clone mismatch", k)
+		}
+	}
+
+	if _, err := NewKmerSet(33, 0); err != ErrKOverflow {
+		t.Errorf("k=33 should not be supported")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"container/heap"
+	"errors"
+	"io"
+	"sort"
+)
+
+// ErrNotSorted means k-mers in the file are not sorted.
+var ErrNotSorted = errors.New("unikmer: k-mers not sorted")
+
+// MergeIterator merges k-mers (K <= 32) from multiple Readers of sorted files
+// in ascending order, with only one k-mer kept in memory for every Reader.
+// Each k-mer is returned once along with the indexes of Readers containing it,
+// so set operations (union, intersection, difference, ...) can be computed
+// in streaming.
+type MergeIterator struct {
+	readers []*Reader
+	k       int
+
+	h     mergeHeap
+	idx   []int
+	stamp []int // number of the k-mer at which the reader was recorded
+	num   int   // number of returned k-mers
+}
+
+// NewMergeIterator creates a MergeIterator.
+// All readers should be of the same K and have the flag UNIK_SORTED.
+func NewMergeIterator(readers ...*Reader) (*MergeIterator, error) {
+	m := &MergeIterator{
+		readers: readers,
+		h:       make(mergeHeap, 0, len(readers)),
+		idx:     make([]int, 0, len(readers)),
+		stamp:   make([]int, len(readers)),
+	}
+	for i, reader := range readers {
+		if reader.Words != 1 {
+			return nil, ErrWordsMismatch
+		}
+		if reader.Flag&UNIK_SORTED == 0 {
+			return nil, ErrNotSorted
+		}
+		if i == 0 {
+			m.k = reader.K
+		} else if reader.K != m.k {
+			return nil, ErrKMismatch
+		}
+		m.stamp[i] = -1
+
+		item := &mergeItem{i: i}
+		ok, err := m.read(item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.h = append(m.h, item)
+		}
+	}
+	heap.Init(&m.h)
+	return m, nil
+}
+
+// read reads the next k-mer of a Reader, ok is false at the end of file.
+func (m *MergeIterator) read(item *mergeItem) (ok bool, err error) {
+	kcode, err := m.readers[item.i].Read()
+	if err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	if item.started && kcode.Code < item.code {
+		return false, ErrNotSorted
+	}
+	item.code, item.started = kcode.Code, true
+	return true, nil
+}
+
+// Next returns the next k-mer and the indexes (in ascending order) of Readers
+// containing it. The slice of indexes is reused in the next call.
+// io.EOF is returned when all k-mers are returned.
+func (m *MergeIterator) Next() (KmerCode, []int, error) {
+	if len(m.h) == 0 {
+		return KmerCode{}, nil, io.EOF
+	}
+
+	code := m.h[0].code
+	m.idx = m.idx[:0]
+	var item *mergeItem
+	var ok bool
+	var err error
+	for len(m.h) > 0 && m.h[0].code == code {
+		item = m.h[0]
+		if m.stamp[item.i] != m.num { // duplicated k-mers in a file
+			m.stamp[item.i] = m.num
+			m.idx = append(m.idx, item.i)
+		}
+
+		ok, err = m.read(item)
+		if err != nil {
+			return KmerCode{}, nil, err
+		}
+		if ok {
+			heap.Fix(&m.h, 0)
+		} else {
+			heap.Pop(&m.h)
+		}
+	}
+	m.num++
+
+	if len(m.idx) > 1 {
+		sort.Ints(m.idx)
+	}
+	return KmerCode{Code: code, K: m.k}, m.idx, nil
+}
+
+// mergeItem holds the current k-mer of a Reader.
+type mergeItem struct {
+	i       int // index of the Reader
+	code    uint64
+	started bool
+}
+
+type mergeHeap []*mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	return h[i].code < h[j].code || (h[i].code == h[j].code && h[i].i < h[j].i)
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
@@ -0,0 +1,125 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestMergeIterator(t *testing.T) {
+	k := 11
+	nfiles := 5
+	bufs := make([]*bytes.Buffer, nfiles)
+	members := make(map[uint64][]int)
+	for i := 0; i < nfiles; i++ {
+		codes := make([]uint64, 1000+rand.Intn(1000))
+		for j := range codes {
+			codes[j] = uint64(rand.Intn(5000))
+		}
+		sort.Sort(CodeSlice(codes))
+
+		bufs[i] = new(bytes.Buffer)
+		writer, err := NewWriter(bufs[i], k, UNIK_SORTED)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, code := range codes {
+			if err = writer.Write(KmerCode{Code: code, K: k}); err != nil {
+				t.Fatal(err)
+			}
+			if j > 0 && code == codes[j-1] { // duplicated k-mers
+				continue
+			}
+			members[code] = append(members[code], i)
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readers := make([]*Reader, nfiles)
+	var err error
+	for i, buf := range bufs {
+		readers[i], err = NewReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := NewMergeIterator(readers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kcode KmerCode
+	var idx []int
+	var prev uint64
+	var n int
+	for {
+		kcode, idx, err = m.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		if n > 0 && kcode.Code <= prev {
+			t.Errorf("k-mers not in ascending order: %d after %d", kcode.Code, prev)
+		}
+		prev = kcode.Code
+		n++
+
+		expected, ok := members[kcode.Code]
+		if !ok {
+			t.Errorf("unexpected k-mer: %d", kcode.Code)
+			continue
+		}
+		if len(idx) != len(expected) {
+			t.Errorf("k-mer %d: readers mismatch: %v vs %v", kcode.Code, idx, expected)
+			continue
+		}
+		for i := range idx {
+			if idx[i] != expected[i] {
+				t.Errorf("k-mer %d: readers mismatch: %v vs %v", kcode.Code, idx, expected)
+				break
+			}
+		}
+	}
+	if n != len(members) {
+		t.Errorf("number of k-mers mismatch: %d vs %d", n, len(members))
+	}
+}
+
+func TestMergeIteratorNotSorted(t *testing.T) {
+	var buf bytes.Buffer
+	writer, _ := NewWriter(&buf, 5, 0)
+	writer.Write(KmerCode{Code: 1, K: 5})
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewMergeIterator(reader); err != ErrNotSorted {
+		t.Errorf("unsorted file should be refused")
+	}
+}
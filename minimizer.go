@@ -0,0 +1,152 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import "errors"
+
+// ErrInvalidMinimizer means invalid minimizer parameters.
+var ErrInvalidMinimizer = errors.New("unikmer: invalid minimizer parameters, window size should be positive and ordering should be MinimizerLexicographic or MinimizerHashed")
+
+// Orderings of k-mers for choosing minimizers.
+const (
+	MinimizerLexicographic uint8 = iota // by k-mer code, i.e., lexicographic order of k-mers
+	MinimizerHashed                     // by hash value of k-mer code, see Hash64
+)
+
+// MinimizerOrderingName returns the name of a minimizer ordering.
+func MinimizerOrderingName(ordering uint8) string {
+	switch ordering {
+	case MinimizerLexicographic:
+		return "lex"
+	case MinimizerHashed:
+		return "hash"
+	}
+	return "unknown"
+}
+
+// minimizerItem is a k-mer in the window of MinimizerIterator.
+type minimizerItem struct {
+	idx  int
+	code uint64
+	key  uint64
+}
+
+// MinimizerIterator iterates minimizers (k <= 32) of a sequence, i.e., the
+// smallest k-mer in every window of w consecutive k-mers, according to the
+// ordering. The leftmost one is chosen for ties, and a minimizer shared by
+// adjacent windows is returned only once. Windows do not span k-mers containing
+// bases other than A/C/G/T/U, and regions with less than w k-mers are ignored.
+type MinimizerIterator struct {
+	iter     *KmerIterator
+	k        int
+	w        int
+	ordering uint8
+
+	q    []minimizerItem // monotonic queue of k-mers in the window, q[h] is the minimum
+	h    int
+	n    int // number of consecutive k-mers
+	prev int // start position of previous k-mer
+
+	idx int // start position of current minimizer
+}
+
+// NewMinimizerIterator returns a MinimizerIterator of sequence s with
+// window size w. For canonical minimizers, canonical k-mers are compared.
+func NewMinimizerIterator(s []byte, k int, w int, ordering uint8, canonical bool, circular bool) (*MinimizerIterator, error) {
+	if w <= 0 || ordering > MinimizerHashed {
+		return nil, ErrInvalidMinimizer
+	}
+	iter, err := NewKmerIterator(s, k, canonical, circular)
+	if err != nil {
+		return nil, err
+	}
+	return &MinimizerIterator{
+		iter:     iter,
+		k:        k,
+		w:        w,
+		ordering: ordering,
+		q:        make([]minimizerItem, 0, w+1),
+		prev:     -1,
+		idx:      -1,
+	}, nil
+}
+
+// Next returns the next minimizer, ok is false when all minimizers are returned.
+func (iter *MinimizerIterator) Next() (kcode KmerCode, ok bool) {
+	var idx int
+	var key uint64
+	var item minimizerItem
+	for {
+		kcode, ok = iter.iter.Next()
+		if !ok {
+			return KmerCode{}, false
+		}
+
+		idx = iter.iter.Index()
+		if idx != iter.prev+1 { // restart after bases other than A/C/G/T/U
+			iter.q = iter.q[:0]
+			iter.h = 0
+			iter.n = 0
+		}
+		iter.prev = idx
+		iter.n++
+
+		if iter.ordering == MinimizerHashed {
+			key = Hash64(kcode.Code)
+		} else {
+			key = kcode.Code
+		}
+
+		for len(iter.q) > iter.h && iter.q[len(iter.q)-1].key > key {
+			iter.q = iter.q[:len(iter.q)-1]
+		}
+		if iter.h > 0 && len(iter.q) == cap(iter.q) { // reuse the space
+			iter.q = iter.q[:copy(iter.q, iter.q[iter.h:])]
+			iter.h = 0
+		}
+		iter.q = append(iter.q, minimizerItem{idx: idx, code: kcode.Code, key: key})
+		for iter.q[iter.h].idx <= idx-iter.w {
+			iter.h++
+		}
+
+		if iter.n < iter.w {
+			continue
+		}
+
+		item = iter.q[iter.h]
+		if item.idx == iter.idx {
+			continue
+		}
+		iter.idx = item.idx
+		return KmerCode{item.code, iter.k}, true
+	}
+}
+
+// Index returns the 0-based start position of current minimizer in sequence.
+func (iter *MinimizerIterator) Index() int {
+	return iter.idx
+}
+
+// Skipped returns the number of k-mers skipped so far because of containing
+// bases other than A/C/G/T/U.
+func (iter *MinimizerIterator) Skipped() int {
+	return iter.iter.Skipped()
+}
@@ -0,0 +1,151 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// slidingMinimizers computes minimizers in the slow way.
+func slidingMinimizers(s []byte, k int, w int, ordering uint8, canonical bool, circular bool) ([]int, []uint64) {
+	idxs, codes2 := slidingKmers(s, k, canonical, circular)
+	key := func(code uint64) uint64 {
+		if ordering == MinimizerHashed {
+			return Hash64(code)
+		}
+		return code
+	}
+
+	var mIdxs []int
+	var mCodes []uint64
+	var start, m int
+	for i := range idxs {
+		if i > 0 && idxs[i] != idxs[i-1]+1 {
+			start = i
+		}
+		if i-start+1 < w {
+			continue
+		}
+		m = i - w + 1
+		for j := m + 1; j <= i; j++ {
+			if key(codes2[j].Code[1]) < key(codes2[m].Code[1]) {
+				m = j
+			}
+		}
+		if len(mIdxs) > 0 && mIdxs[len(mIdxs)-1] == idxs[m] {
+			continue
+		}
+		mIdxs = append(mIdxs, idxs[m])
+		mCodes = append(mCodes, codes2[m].Code[1])
+	}
+	return mIdxs, mCodes
+}
+
+func TestMinimizerIterator(t *testing.T) {
+	for _, k := range []int{1, 5, 15, 21, 32} {
+		for _, w := range []int{1, 2, 5, 10} {
+			for _, ordering := range []uint8{MinimizerLexicographic, MinimizerHashed} {
+				for _, canonical := range []bool{false, true} {
+					for _, circular := range []bool{false, true} {
+						s := randomSeq(500, true)
+						idxs, codes := slidingMinimizers(s, k, w, ordering, canonical, circular)
+
+						iter, err := NewMinimizerIterator(s, k, w, ordering, canonical, circular)
+						if err != nil {
+							t.Fatal(err)
+						}
+						var i int
+						for ; ; i++ {
+							kcode, ok := iter.Next()
+							if !ok {
+								break
+							}
+							if i >= len(idxs) || iter.Index() != idxs[i] || kcode.Code != codes[i] || kcode.K != k {
+								t.Fatalf("k=%d w=%d ordering=%d canonical=%v circular=%v: minimizer mismatch at %d",
+									k, w, ordering, canonical, circular, i)
+							}
+						}
+						if i != len(idxs) {
+							t.Errorf("k=%d w=%d ordering=%d canonical=%v circular=%v: number mismatch: %d vs %d",
+								k, w, ordering, canonical, circular, i, len(idxs))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := NewMinimizerIterator([]byte("ACGT"), 2, 0, MinimizerHashed, false, false); err != ErrInvalidMinimizer {
+		t.Errorf("window size of 0 should not be allowed")
+	}
+}
+
+func TestWriterMinimizer(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, 21, UNIK_SORTED|UNIK_MINIMIZER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.WriteHeader(); err != ErrInvalidMinimizer {
+		t.Errorf("minimizer parameters should be checked")
+	}
+
+	buf.Reset()
+	writer, err = NewWriter(&buf, 21, UNIK_SORTED|UNIK_MINIMIZER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.MinimizerWindow = 10
+	writer.MinimizerOrdering = MinimizerHashed
+	mers := genKmers(21, 100, true)
+	for _, mer := range mers {
+		if err = writer.WriteKmer(mer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Flag&UNIK_MINIMIZER == 0 || reader.MinimizerWindow != 10 || reader.MinimizerOrdering != MinimizerHashed {
+		t.Fatalf("minimizer parameters mismatch")
+	}
+	var n int
+	for {
+		_, err = reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != len(mers) {
+		t.Errorf("number mismatch: %d vs %d", n, len(mers))
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// ErrMmapNotSupported means the file can not be read with MmapReader.
+var ErrMmapNotSupported = errors.New("unikmer: only uncompressed file of fixed-length k-mers (not sorted, counted or indexed) supported by MmapReader")
+
+// MmapReader reads uncompressed binary file of fixed-length k-mers,
+// i.e., not sorted, counted or indexed, via memory mapping.
+// K-mers are accessed like a slice, without copying or decoding the whole file.
+// The number of k-mers and CRC32C in the checksum trailer are validated when mapping.
+type MmapReader struct {
+	Header
+	data  []byte // the mapped file
+	codes []byte // k-mers
+	size  int    // bytes of a k-mer
+	n     int    // number of k-mers
+	i     int    // index of the next k-mer for sequential reading
+}
+
+// NewMmapReader maps a binary file into memory.
+// ErrMmapNotSupported is returned for gzipped, sorted, counted or indexed files,
+// which should be read with Reader.
+func NewMmapReader(file string) (*MmapReader, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	info, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < 2 {
+		return nil, ErrInvalidFileFormat
+	}
+	data, err := mmap(fh, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MmapReader{data: data}
+	if err = m.init(); err != nil {
+		munmap(data)
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *MmapReader) init() error {
+	data := m.data
+	if data[0] == 0x1f && data[1] == 0x8b { // gzipped
+		return ErrMmapNotSupported
+	}
+	if len(data) >= 4 && data[0] == 0x28 && data[1] == 0xb5 && data[2] == 0x2f && data[3] == 0xfd { // zstd
+		return ErrMmapNotSupported
+	}
+
+	r := bytes.NewReader(data)
+	reader := &Reader{r: r}
+	err := reader.readHeader()
+	if err != nil {
+		return err
+	}
+	if reader.Flag&UNIK_BLOOM > 0 {
+		return ErrBloomFilterFile
+	}
+	if reader.sorted || reader.succinct || reader.counted || reader.Flag&UNIK_INDEXED > 0 {
+		return ErrMmapNotSupported
+	}
+	m.Header = reader.Header
+
+	m.codes = data[len(data)-r.Len():]
+	if reader.compact {
+		m.size = reader.bufsize
+	} else {
+		m.size = 8 * m.Words
+	}
+
+	// the bytes are already mapped, so the checksum is cheap to compute
+	var n uint64
+	var crc uint32
+	if reader.Flag&UNIK_CHECKSUM > 0 {
+		if len(m.codes) < checksumTrailerSize {
+			return ErrTruncatedFile
+		}
+		n, crc, err = parseChecksumTrailer(m.codes[len(m.codes)-checksumTrailerSize:])
+		if err != nil {
+			return err
+		}
+		m.codes = m.codes[:len(m.codes)-checksumTrailerSize]
+		if crc32.Checksum(m.codes, crc32cTable) != crc {
+			return ErrChecksumMismatch
+		}
+	}
+
+	if len(m.codes)%m.size != 0 {
+		return ErrBrokenFile
+	}
+	m.n = len(m.codes) / m.size
+	if reader.Flag&UNIK_CHECKSUM > 0 && uint64(m.n) != n {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// Len returns the number of k-mers.
+func (m *MmapReader) Len() int {
+	return m.n
+}
+
+// At returns the i-th k-mer, for files with K <= 32.
+func (m *MmapReader) At(i int) KmerCode {
+	return KmerCode{Code: m.code(i), K: m.K}
+}
+
+func (m *MmapReader) code(i int) uint64 {
+	b := m.codes[i*m.size : (i+1)*m.size]
+	if m.size == 8 {
+		return be.Uint64(b)
+	}
+	var code uint64
+	for _, c := range b {
+		code = code<<8 | uint64(c)
+	}
+	return code
+}
+
+// At2 returns the i-th k-mer, for files with K > 32.
+func (m *MmapReader) At2(i int) KmerCode2 {
+	b := m.codes[i*m.size : (i+1)*m.size]
+	if m.size == 16 {
+		return KmerCode2{Code: [2]uint64{be.Uint64(b[0:8]), be.Uint64(b[8:16])}, K: m.K}
+	}
+	var code [2]uint64
+	for _, c := range b {
+		code[0] = code[0]<<8 | code[1]>>56
+		code[1] = code[1]<<8 | uint64(c)
+	}
+	return KmerCode2{Code: code, K: m.K}
+}
+
+// Codes decodes k-mers (K <= 32) from the start-th one into buf in bulk,
+// and returns the number of decoded k-mers, 0 for the end.
+func (m *MmapReader) Codes(start int, buf []uint64) int {
+	if start >= m.n {
+		return 0
+	}
+	n := m.n - start
+	if n > len(buf) {
+		n = len(buf)
+	}
+	if m.size == 8 {
+		b := m.codes[start*8:]
+		for i := 0; i < n; i++ {
+			buf[i] = be.Uint64(b[i<<3:])
+		}
+		return n
+	}
+	for i := 0; i < n; i++ {
+		buf[i] = m.code(start + i)
+	}
+	return n
+}
+
+// Read reads the next KmerCode in order, for files with K <= 32.
+func (m *MmapReader) Read() (KmerCode, error) {
+	if m.Words != 1 {
+		return KmerCode{}, ErrWordsMismatch
+	}
+	if m.i >= m.n {
+		return KmerCode{}, io.EOF
+	}
+	m.i++
+	return m.At(m.i - 1), nil
+}
+
+// Read2 reads the next KmerCode2 in order, for files with K > 32.
+func (m *MmapReader) Read2() (KmerCode2, error) {
+	if m.Words != 2 {
+		return KmerCode2{}, ErrWordsMismatch
+	}
+	if m.i >= m.n {
+		return KmerCode2{}, io.EOF
+	}
+	m.i++
+	return m.At2(m.i - 1), nil
+}
+
+// Reader returns a Reader reading k-mers in order from the mapped file,
+// so it can be used wherever a Reader is needed.
+func (m *MmapReader) Reader() *Reader {
+	return &Reader{Header: m.Header, mm: m}
+}
+
+// Close unmaps the file, k-mers should not be accessed after closing.
+func (m *MmapReader) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	err := munmap(m.data)
+	m.data, m.codes, m.n = nil, nil, 0
+	return err
+}
@@ -0,0 +1,143 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMmapReader(t *testing.T) {
+	for _, k := range []int{5, 21, 31, 32, 41, 64} {
+		for _, flag := range []uint32{0, UNIK_COMPACT} {
+			mers := genKmers(k, 1001, false)
+			file := fmt.Sprintf("t.mmap.k%d.unik", k)
+			if err := write(mers, file, flag); err != nil {
+				t.Fatal(err)
+			}
+
+			func() {
+				defer os.Remove(file)
+
+				m, err := NewMmapReader(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer m.Close()
+
+				if m.Len() != len(mers) {
+					t.Fatalf("k=%d flag=%d: number mismatch: %d vs %d", k, flag, m.Len(), len(mers))
+				}
+				var mer []byte
+				for i := range mers {
+					if k > 32 {
+						mer = m.At2(i).Bytes()
+					} else {
+						mer = m.At(i).Bytes()
+					}
+					if !bytes.Equal(mer, mers[i]) {
+						t.Fatalf("k=%d flag=%d: data mismatch at %d", k, flag, i)
+					}
+				}
+				if k > 32 {
+					return
+				}
+
+				// bulk
+				buf := make([]uint64, 100)
+				var i, n int
+				for {
+					n = m.Codes(i, buf)
+					if n == 0 {
+						break
+					}
+					for _, code := range buf[:n] {
+						if code != m.At(i).Code {
+							t.Fatalf("k=%d flag=%d: bulk data mismatch at %d", k, flag, i)
+						}
+						i++
+					}
+				}
+				if i != len(mers) {
+					t.Errorf("k=%d flag=%d: bulk number mismatch: %d vs %d", k, flag, i, len(mers))
+				}
+
+				// Reader
+				reader := m.Reader()
+				i = 0
+				for {
+					kcode, err := reader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						t.Fatal(err)
+					}
+					if !bytes.Equal(kcode.Bytes(), mers[i]) {
+						t.Fatalf("k=%d flag=%d: data mismatch at %d", k, flag, i)
+					}
+					i++
+				}
+				if i != len(mers) {
+					t.Errorf("k=%d flag=%d: number mismatch: %d vs %d", k, flag, i, len(mers))
+				}
+			}()
+		}
+	}
+}
+
+func TestMmapReaderNotSupported(t *testing.T) {
+	file := "t.mmap.sorted.unik"
+	if err := write(genKmers(21, 100, true), file, UNIK_SORTED); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file)
+
+	if _, err := NewMmapReader(file); err != ErrMmapNotSupported {
+		t.Errorf("ErrMmapNotSupported expected for sorted file, got: %v", err)
+	}
+}
+
+func TestMmapReaderCorrupted(t *testing.T) {
+	file := "t.mmap.corrupted.unik"
+	if err := write(genKmers(21, 100, false), file, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file)
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flip a bit of the last k-mer, the number of k-mers is unchanged
+	data[len(data)-checksumTrailerSize-1] ^= 1
+	if err = ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewMmapReader(file); err != ErrChecksumMismatch {
+		t.Errorf("corrupted file not detected: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import "errors"
+
+// ErrInvalidAlphabet means invalid alphabet of amino acids.
+var ErrInvalidAlphabet = errors.New("unikmer: invalid amino acid alphabet")
+
+// ErrIllegalResidue means that residue other than the 20 standard amino acids is detected.
+var ErrIllegalResidue = errors.New("unikmer: illegal amino acid residue")
+
+// ErrProteinKOverflow means K > 12 for protein k-mers.
+var ErrProteinKOverflow = errors.New("unikmer: K-mer size (1-12) of protein overflow")
+
+// MaxProteinK is the maximum K of protein k-mers, every residue takes 5 bits.
+const MaxProteinK = 12
+
+// bits of a residue
+const proteinBits = 5
+
+// Alphabets of amino acids. In reduced alphabets, residues of a group share the
+// same code and are decoded to the first residue of the group.
+const (
+	// AlphabetProtein is the alphabet of the 20 standard amino acids.
+	AlphabetProtein uint8 = iota
+	// AlphabetMurphy15 is the 15-letter alphabet of Murphy et al. (2000).
+	AlphabetMurphy15
+	// AlphabetMurphy10 is the 10-letter alphabet of Murphy et al. (2000).
+	AlphabetMurphy10
+	// AlphabetDayhoff6 is the 6-letter alphabet of Dayhoff.
+	AlphabetDayhoff6
+)
+
+// names and groups of residues of alphabets
+var alphabetNames = []string{"protein", "murphy15", "murphy10", "dayhoff6"}
+
+var alphabetGroups = [][]string{
+	{"A", "C", "D", "E", "F", "G", "H", "I", "K", "L", "M", "N", "P", "Q", "R", "S", "T", "V", "W", "Y"},
+	{"LVIM", "C", "A", "G", "S", "T", "P", "FY", "W", "E", "D", "N", "Q", "KR", "H"},
+	{"LVIM", "C", "A", "G", "ST", "P", "FYW", "EDNQ", "KR", "H"},
+	{"AGPST", "C", "DENQ", "FWY", "HKR", "ILMV"},
+}
+
+// residue2code maps residues to codes for every alphabet, 32 for illegal residues.
+var residue2code [][256]uint64
+
+// code2residue maps codes to residues for every alphabet.
+var code2residue [][]byte
+
+// MaxProteinCode is the maxinum interger of protein k-mers for all Ks.
+var MaxProteinCode []uint64
+
+func init() {
+	MaxProteinCode = make([]uint64, MaxProteinK+1)
+	for i := 1; i <= MaxProteinK; i++ {
+		MaxProteinCode[i] = 1<<uint(i*proteinBits) - 1
+	}
+
+	residue2code = make([][256]uint64, len(alphabetGroups))
+	code2residue = make([][]byte, len(alphabetGroups))
+	for a, groups := range alphabetGroups {
+		for i := range residue2code[a] {
+			residue2code[a][i] = 32
+		}
+		code2residue[a] = make([]byte, len(groups))
+		for c, group := range groups {
+			code2residue[a][c] = group[0]
+			for i := 0; i < len(group); i++ {
+				residue2code[a][group[i]] = uint64(c)
+				residue2code[a][group[i]+'a'-'A'] = uint64(c)
+			}
+		}
+	}
+}
+
+// AlphabetName returns the name of an alphabet.
+func AlphabetName(alphabet uint8) string {
+	if int(alphabet) >= len(alphabetNames) {
+		return "unknown"
+	}
+	return alphabetNames[alphabet]
+}
+
+// AlphabetSize returns the number of letters of an alphabet, 0 for invalid alphabet.
+func AlphabetSize(alphabet uint8) int {
+	if int(alphabet) >= len(alphabetGroups) {
+		return 0
+	}
+	return len(alphabetGroups[alphabet])
+}
+
+// EncodeProtein converts a protein k-mer (K <= 12) to 5-bit codes of residues
+// in the given alphabet. Residues other than the 20 standard amino acids,
+// e.g., X and the stop codon *, are illegal.
+func EncodeProtein(kmer []byte, alphabet uint8) (code uint64, err error) {
+	if len(kmer) == 0 || len(kmer) > MaxProteinK {
+		return 0, ErrProteinKOverflow
+	}
+	if int(alphabet) >= len(residue2code) {
+		return 0, ErrInvalidAlphabet
+	}
+	table := &residue2code[alphabet]
+
+	var v uint64
+	for _, b := range kmer {
+		v = table[b]
+		if v > 31 {
+			return code, ErrIllegalResidue
+		}
+		code = code<<proteinBits | v
+	}
+	return code, nil
+}
+
+// DecodeProtein converts the code to a protein k-mer, residues of reduced
+// alphabets are represented by the first residue of their groups.
+func DecodeProtein(code uint64, k int, alphabet uint8) []byte {
+	if k <= 0 || k > MaxProteinK {
+		panic(ErrProteinKOverflow)
+	}
+	if int(alphabet) >= len(code2residue) {
+		panic(ErrInvalidAlphabet)
+	}
+	if code > MaxProteinCode[k] {
+		panic(ErrCodeOverflow)
+	}
+	residues := code2residue[alphabet]
+	kmer := make([]byte, k)
+	var v uint64
+	for i := 0; i < k; i++ {
+		v = code & 31
+		if v >= uint64(len(residues)) {
+			panic(ErrCodeOverflow)
+		}
+		kmer[k-1-i] = residues[v]
+		code >>= proteinBits
+	}
+	return kmer
+}
+
+// NewProteinKmerCode returns a KmerCode from a protein k-mer.
+func NewProteinKmerCode(kmer []byte, alphabet uint8) (KmerCode, error) {
+	code, err := EncodeProtein(kmer, alphabet)
+	if err != nil {
+		return KmerCode{}, err
+	}
+	return KmerCode{code, len(kmer)}, nil
+}
+
+// MaxKOfBitmapProteinKmerSet is the maximum K of protein KmerSet in bitmap,
+// which takes 32^K bits (4 MB for K = 5).
+const MaxKOfBitmapProteinKmerSet = 5
+
+// NewProteinKmerSet creates a KmerSet for protein k-mers of length k.
+// For K <= MaxKOfBitmapProteinKmerSet, k-mers are saved in a bitmap.
+// Otherwise, a map with an initial size is used.
+func NewProteinKmerSet(k int, size int) (KmerSet, error) {
+	if k <= 0 || k > MaxProteinK {
+		return nil, ErrProteinKOverflow
+	}
+	if k <= MaxKOfBitmapProteinKmerSet {
+		return &bitmapKmerSet{bits: make([]uint64, (uint64(1)<<uint(k*proteinBits)+63)>>6)}, nil
+	}
+	if size < 0 {
+		size = 0
+	}
+	return mapKmerSet(make(map[uint64]struct{}, size)), nil
+}
+
+// ProteinKmerIterator iterates k-mers (K <= 12) of a protein sequence with
+// rolling encoding. K-mers containing illegal residues, e.g., X and *,
+// are skipped.
+type ProteinKmerIterator struct {
+	s     []byte
+	k     int
+	table *[256]uint64
+	mask  uint64
+
+	j    int // index of the next residue to add
+	n    int // number of consecutive legal residues
+	code uint64
+
+	idx int
+	num int
+}
+
+// NewProteinKmerIterator returns a ProteinKmerIterator of sequence s.
+func NewProteinKmerIterator(s []byte, k int, alphabet uint8) (*ProteinKmerIterator, error) {
+	if k <= 0 || k > MaxProteinK {
+		return nil, ErrProteinKOverflow
+	}
+	if int(alphabet) >= len(residue2code) {
+		return nil, ErrInvalidAlphabet
+	}
+	return &ProteinKmerIterator{s: s, k: k, table: &residue2code[alphabet], mask: MaxProteinCode[k]}, nil
+}
+
+// Next returns the next k-mer, ok is false when all k-mers are returned.
+func (iter *ProteinKmerIterator) Next() (kcode KmerCode, ok bool) {
+	var v uint64
+	for iter.j < len(iter.s) {
+		v = iter.table[iter.s[iter.j]]
+		iter.j++
+		if v > 31 { // restart
+			iter.n = 0
+			continue
+		}
+		iter.code = (iter.code<<proteinBits | v) & iter.mask
+		iter.n++
+		if iter.n < iter.k {
+			continue
+		}
+
+		iter.idx = iter.j - iter.k
+		iter.num++
+		return KmerCode{iter.code, iter.k}, true
+	}
+	return KmerCode{}, false
+}
+
+// Index returns the 0-based index of current k-mer in sequence.
+func (iter *ProteinKmerIterator) Index() int {
+	return iter.idx
+}
+
+// Skipped returns the number of k-mers skipped so far because of containing
+// illegal residues.
+func (iter *ProteinKmerIterator) Skipped() int {
+	return skippedWindows(iter.j, iter.k, iter.num)
+}
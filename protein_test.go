@@ -0,0 +1,210 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+var aminoAcids = []byte("ACDEFGHIKLMNPQRSTVWY")
+
+func TestEncodeProtein(t *testing.T) {
+	for k := 1; k <= MaxProteinK; k++ {
+		for i := 0; i < 1000; i++ {
+			kmer := make([]byte, k)
+			for j := range kmer {
+				kmer[j] = aminoAcids[rand.Intn(len(aminoAcids))]
+			}
+			code, err := EncodeProtein(kmer, AlphabetProtein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kmer2 := DecodeProtein(code, k, AlphabetProtein); !bytes.Equal(kmer, kmer2) {
+				t.Fatalf("encode/decode error: %s vs %s", kmer, kmer2)
+			}
+			code2, _ := EncodeProtein(bytes.ToLower(kmer), AlphabetProtein)
+			if code2 != code {
+				t.Fatalf("lower case residues should be supported: %s", kmer)
+			}
+		}
+	}
+
+	if _, err := EncodeProtein([]byte("ACDEFGHIKLMNP"), AlphabetProtein); err != ErrProteinKOverflow {
+		t.Errorf("K should be checked")
+	}
+	for _, kmer := range []string{"ACX", "AC*", "ACB"} {
+		if _, err := EncodeProtein([]byte(kmer), AlphabetProtein); err != ErrIllegalResidue {
+			t.Errorf("illegal residue should be detected: %s", kmer)
+		}
+	}
+	if _, err := EncodeProtein([]byte("ACD"), 100); err != ErrInvalidAlphabet {
+		t.Errorf("alphabet should be checked")
+	}
+}
+
+func TestReducedAlphabet(t *testing.T) {
+	tests := []struct {
+		alphabet uint8
+		a, b     string // k-mers of the same reduced k-mer
+		c        string // reduced k-mer
+	}{
+		{AlphabetMurphy15, "LFKS", "MYRS", "LFKS"},
+		{AlphabetMurphy10, "VWQT", "IFES", "LFES"},
+		{AlphabetDayhoff6, "PEWM", "ANYL", "ADFI"},
+	}
+	for _, test := range tests {
+		a, err := EncodeProtein([]byte(test.a), test.alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := EncodeProtein([]byte(test.b), test.alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != b {
+			t.Errorf("%s: %s and %s should be the same", AlphabetName(test.alphabet), test.a, test.b)
+		}
+		if c := DecodeProtein(a, len(test.a), test.alphabet); string(c) != test.c {
+			t.Errorf("%s: decoding error: %s vs %s", AlphabetName(test.alphabet), c, test.c)
+		}
+	}
+	if AlphabetSize(AlphabetMurphy10) != 10 || AlphabetSize(AlphabetDayhoff6) != 6 {
+		t.Errorf("alphabet size error")
+	}
+}
+
+func TestProteinKmerIterator(t *testing.T) {
+	s := make([]byte, 1000)
+	for i := range s {
+		if rand.Intn(50) == 0 {
+			s[i] = 'X'
+		} else {
+			s[i] = aminoAcids[rand.Intn(len(aminoAcids))]
+		}
+	}
+	for _, k := range []int{1, 3, 7, 12} {
+		for _, alphabet := range []uint8{AlphabetProtein, AlphabetMurphy10} {
+			iter, err := NewProteinKmerIterator(s, k, alphabet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var nSkipped int
+			for i := 0; i <= len(s)-k; i++ {
+				code, err := EncodeProtein(s[i:i+k], alphabet)
+				if err == ErrIllegalResidue {
+					nSkipped++
+					continue
+				}
+				kcode, ok := iter.Next()
+				if !ok {
+					t.Fatalf("k=%d: missing k-mer at %d", k, i)
+				}
+				if kcode.Code != code || kcode.K != k || iter.Index() != i {
+					t.Fatalf("k=%d: k-mer mismatch at %d", k, i)
+				}
+			}
+			if _, ok := iter.Next(); ok {
+				t.Errorf("k=%d: unexpected k-mer", k)
+			}
+			if iter.Skipped() != nSkipped {
+				t.Errorf("k=%d: skipped number mismatch: %d vs %d", k, iter.Skipped(), nSkipped)
+			}
+		}
+	}
+}
+
+func TestProteinKmerSet(t *testing.T) {
+	for _, k := range []int{3, 5, 8} {
+		m, err := NewProteinKmerSet(k, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Sorted() != (k <= MaxKOfBitmapProteinKmerSet) {
+			t.Errorf("k=%d: unexpected backend", k)
+		}
+		m.Add(MaxProteinCode[k])
+		m.Add(0)
+		if !m.Contains(MaxProteinCode[k]) || m.Len() != 2 {
+			t.Errorf("k=%d: KmerSet error", k)
+		}
+	}
+}
+
+func TestWriterProtein(t *testing.T) {
+	mers := []string{"ACDEFGHIKLMN", "MNPQRSTVWYAC", "WWWWWWWWWWWW"}
+	for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED} {
+		var buf bytes.Buffer
+		writer, err := NewWriter(&buf, 12, flag|UNIK_PROTEIN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mer := range mers {
+			if err = writer.WriteKmer([]byte(mer)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		reader, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.Flag&UNIK_PROTEIN == 0 || reader.Alphabet != AlphabetProtein {
+			t.Fatalf("flag=%d: alphabet mismatch", flag)
+		}
+		var i int
+		for ; ; i++ {
+			kcode, err := reader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatal(err)
+			}
+			if mer := DecodeProtein(kcode.Code, kcode.K, reader.Alphabet); string(mer) != mers[i] {
+				t.Errorf("flag=%d: k-mer mismatch: %s vs %s", flag, mer, mers[i])
+			}
+		}
+		if i != len(mers) {
+			t.Errorf("flag=%d: number mismatch: %d vs %d", flag, i, len(mers))
+		}
+	}
+
+	writer, _ := NewWriter(&bytes.Buffer{}, 13, UNIK_PROTEIN)
+	if err := writer.WriteHeader(); err != ErrProteinKOverflow {
+		t.Errorf("K of protein k-mers should be checked")
+	}
+	writer, _ = NewWriter(&bytes.Buffer{}, 5, UNIK_PROTEIN|UNIK_SUCCINCT)
+	if err := writer.Flush(); err != ErrSuccinctUnsupported {
+		t.Errorf("succinct format should not support protein k-mers")
+	}
+
+	h := Header{Flag: UNIK_PROTEIN, Alphabet: AlphabetMurphy10}
+	if h.SameAlphabet(Header{}) || h.SameAlphabet(Header{Flag: UNIK_PROTEIN}) ||
+		!h.SameAlphabet(Header{Flag: UNIK_PROTEIN, Alphabet: AlphabetMurphy10}) {
+		t.Errorf("SameAlphabet error")
+	}
+}
@@ -21,14 +21,19 @@
 package unikmer
 
 import (
+	"bufio"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"math"
 )
 
 // MainVersion is the main version number.
-const MainVersion uint8 = 2
+// Files of v2 (without checksum trailer) are still readable.
+const MainVersion uint8 = 3
 
 // MinorVersion is the minor version number.
 const MinorVersion uint8 = 0
@@ -45,6 +50,16 @@ var ErrBrokenFile = errors.New("unikmer: broken file")
 // ErrKMismatch means K size mismatch.
 var ErrKMismatch = errors.New("unikmer: K mismatch")
 
+// ErrWordsMismatch means the word width of KmerCode does not match the file,
+// i.e., calling Read/Write for files with K > 32, or Read2/Write2 for K <= 32.
+var ErrWordsMismatch = errors.New("unikmer: word width of k-mer code mismatch")
+
+// ErrMetaTooBig means the metadata is too big to save.
+var ErrMetaTooBig = errors.New("unikmer: metadata too big")
+
+// ErrCountOverflow means the count of a k-mer is bigger than the max value of uint32.
+var ErrCountOverflow = errors.New("unikmer: count overflow")
+
 var be = binary.BigEndian
 
 // Header contains metadata
@@ -52,19 +67,133 @@ type Header struct {
 	MainVersion  uint8
 	MinorVersion uint8
 	K            int
+	Words        int // number of uint64 words of a k-mer code, 1 for K <= 32, 2 for K <= 64
 	Flag         uint32
 	Number       int64 // -1 for unknown
+
+	// parameters of sketch, only for files with flag UNIK_SKETCH,
+	// they are saved right after Number.
+	SketchSize  int // s of bottom-s MinHash, 0 for FracMinHash
+	SketchScale int // scale of FracMinHash, 0 for MinHash
+
+	// parameters of minimizer, only for files with flag UNIK_MINIMIZER,
+	// they are saved after parameters of sketch.
+	MinimizerWindow   int   // number of consecutive k-mers in a window
+	MinimizerOrdering uint8 // MinimizerLexicographic or MinimizerHashed
+
+	// parameters of syncmer, only for files with flag UNIK_SYNCMER,
+	// they are saved after parameters of minimizer.
+	SyncmerType   uint8 // SyncmerClosed or SyncmerOpen
+	SyncmerS      int   // length of s-mer
+	SyncmerOffset int   // offset of the smallest s-mer for open syncmers
+
+	// spaced seed, e.g., "1101101", only for files with flag UNIK_SPACED,
+	// K is the weight of the seed. It's saved after parameters of syncmer.
+	SpacedSeed string
+
+	// alphabet of amino acids, only for files with flag UNIK_PROTEIN,
+	// it's saved after the spaced seed.
+	Alphabet uint8
+
+	// metadata in key-value pairs, e.g., source files and command line,
+	// saved in JSON after the fixed header with flag UNIK_META.
+	Meta map[string]string
 }
 
+// maxMetaSize is the maximum size of metadata in bytes.
+const maxMetaSize = 1 << 24
+
 const (
-	// UNIK_COMPACT means Kmers are serialized in fix-length (n = int((K + 3) / 4) ) of byte array.
+	// UNIK_COMPACT means Kmers are serialized in fix-length (n = int((K + 3) / 4), or
+	// n = int((5 * K + 7) / 8) for protein k-mers) of byte array.
 	UNIK_COMPACT = 1 << iota
 	// UNIK_CANONICAL means only canonical Kmers kept.
 	UNIK_CANONICAL
 	// UNIK_SORTED means Kmers are sorted
-	UNIK_SORTED // when sorted, the serialization structure is very different
+	UNIK_SORTED // when sorted, the serialization structure is very different (only for K <= 32)
+	// UNIK_COUNT means every Kmer is followed by its count (abundance) in varint.
+	UNIK_COUNT
+	// UNIK_SKETCH means Kmers are from a MinHash or FracMinHash sketch.
+	UNIK_SKETCH
+	// UNIK_BLOOM means the file is a Bloom filter of Kmers, see BloomFilter.
+	UNIK_BLOOM
+	// UNIK_INDEXED means Kmers are saved in blocks, with a block index at the end of file, see Index.
+	UNIK_INDEXED
+	// UNIK_META means a metadata block (uint32 length followed by JSON) follows the fixed header.
+	// It's set automatically by Writer when Meta is not empty.
+	UNIK_META
+	// UNIK_CHECKSUM means the file ends with a trailer of number of Kmers and CRC32C of data.
+	// It's set automatically by Writer.
+	UNIK_CHECKSUM
+	// UNIK_SUCCINCT means sorted and unique Kmers (K <= 32) are saved in a bitmap or
+	// Elias–Fano encoding, whichever is smaller, see SuccinctSet. UNIK_SORTED is also set.
+	UNIK_SUCCINCT
+	// UNIK_MINIMIZER means Kmers are minimizers of sequences, see MinimizerIterator.
+	UNIK_MINIMIZER
+	// UNIK_SYNCMER means Kmers are closed or open syncmers, see Syncmer.
+	UNIK_SYNCMER
+	// UNIK_SPACED means Kmers are spaced k-mers of a spaced seed, see SpacedSeed.
+	UNIK_SPACED
+	// UNIK_PROTEIN means Kmers are protein k-mers (K <= 12) in an alphabet of amino acids, see EncodeProtein.
+	UNIK_PROTEIN
 )
 
+// allFlags contains all known flags, files with other bits set are
+// created by newer versions and rejected.
+const allFlags = UNIK_PROTEIN<<1 - 1
+
+// wordsOfK returns the number of uint64 words needed for a k-mer.
+func wordsOfK(k int) int {
+	if k <= 32 {
+		return 1
+	}
+	return 2
+}
+
+// bytesOfCode returns the number of bytes of a k-mer code in compact format.
+func bytesOfCode(k int, flag uint32) int {
+	if flag&UNIK_PROTEIN > 0 {
+		return (k*proteinBits + 7) / 8
+	}
+	return (k + 3) / 4
+}
+
+// SameSampling checks whether k-mers of two files are sampled with the same
+// scheme and parameters, i.e., both with all k-mers, minimizers or syncmers.
+func (h Header) SameSampling(h2 Header) bool {
+	const sampling = UNIK_MINIMIZER | UNIK_SYNCMER
+	if h.Flag&sampling != h2.Flag&sampling {
+		return false
+	}
+	if h.Flag&UNIK_MINIMIZER > 0 &&
+		(h.MinimizerWindow != h2.MinimizerWindow || h.MinimizerOrdering != h2.MinimizerOrdering) {
+		return false
+	}
+	if h.Flag&UNIK_SYNCMER > 0 &&
+		(h.SyncmerType != h2.SyncmerType || h.SyncmerS != h2.SyncmerS || h.SyncmerOffset != h2.SyncmerOffset) {
+		return false
+	}
+	return true
+}
+
+// SameSpacedSeed checks whether k-mers of two files are both contiguous
+// k-mers or both spaced k-mers of the same spaced seed.
+func (h Header) SameSpacedSeed(h2 Header) bool {
+	if h.Flag&UNIK_SPACED != h2.Flag&UNIK_SPACED {
+		return false
+	}
+	return h.Flag&UNIK_SPACED == 0 || h.SpacedSeed == h2.SpacedSeed
+}
+
+// SameAlphabet checks whether k-mers of two files are both DNA k-mers
+// or both protein k-mers of the same alphabet.
+func (h Header) SameAlphabet(h2 Header) bool {
+	if h.Flag&UNIK_PROTEIN != h2.Flag&UNIK_PROTEIN {
+		return false
+	}
+	return h.Flag&UNIK_PROTEIN == 0 || h.Alphabet == h2.Alphabet
+}
+
 func (h Header) String() string {
 	return fmt.Sprintf("unikmer binary k-mer data file v%d.%d with K=%d and Flag=%d",
 		h.MainVersion, h.MinorVersion, h.K, h.Flag)
@@ -79,19 +208,60 @@ type Reader struct {
 	compact bool // saving KmerCode in variable-length byte array.
 	bufsize int
 
-	sorted bool
-	prev   *KmerCode
-	buf2   []byte
-	offset uint64
+	sorted    bool
+	prev      *KmerCode
+	prevCount uint32
+	buf2      []byte
+	offset    uint64
+
+	counted bool // every KmerCode is followed by its count.
+	br      io.ByteReader
+
+	indexed      bool // k-mers are saved in blocks.
+	remaining    int  // number of k-mers left in current block
+	eof          bool
+	rs           io.ReadSeeker
+	bufr         *bufio.Reader
+	index        *Index
+	pending      *KmerCode // k-mer read ahead by Seek
+	pendingCount uint32
+
+	mm *MmapReader // reading from a memory-mapped file, see MmapReader.Reader
+
+	cr    *checksumReader // for files with flag UNIK_CHECKSUM
+	nRead int64
+
+	succinct bool // k-mers are saved in SuccinctSet.
+	ss       *SuccinctSet
+	ssIter   *succinctIterator
 }
 
 // NewReader returns a Reader.
+// If r is an io.ReadSeeker, e.g., *os.File, it's buffered internally,
+// and the block index of indexed file could be used for random access.
 func NewReader(r io.Reader) (reader *Reader, err error) {
 	reader = &Reader{r: r}
 	err = reader.readHeader()
 	if err != nil {
 		return nil, err
 	}
+	if reader.Flag&UNIK_BLOOM > 0 {
+		return nil, ErrBloomFilterFile
+	}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		reader.rs = rs
+		reader.bufr = bufio.NewReader(rs)
+		reader.r = reader.bufr
+	}
+	if reader.Flag&UNIK_CHECKSUM > 0 {
+		reader.cr = newChecksumReader(reader.r)
+		reader.r = bufio.NewReader(reader.cr)
+	}
+	if br, ok := reader.r.(io.ByteReader); ok {
+		reader.br = br
+	} else {
+		reader.br = &byteReader{r: r}
+	}
 	return reader, nil
 }
 
@@ -120,47 +290,241 @@ func (reader *Reader) readHeader() (err error) {
 	if err != nil {
 		return err
 	}
-	// check compatibility？
-	if (meta[0] == 0 && meta[1] == 0) ||
-		MainVersion != meta[0] {
+	// check compatibility
+	if meta[0] < 2 {
 		return fmt.Errorf("unikmer: .unik format compatibility error, please recreate with newest version")
 	}
+	if meta[0] > MainVersion {
+		return fmt.Errorf("unikmer: .unik format v%d.%d not supported, please update unikmer", meta[0], meta[1])
+	}
 	reader.MainVersion = meta[0]
 	reader.MinorVersion = meta[1]
 
 	reader.K = int(meta[2])
 
+	// word width is saved since v2.1, files of older versions are all single-word.
+	reader.Words = int(meta[3])
+	if reader.Words == 0 {
+		reader.Words = 1
+	}
+	if reader.K == 0 || reader.K > MaxK2 || reader.Words != wordsOfK(reader.K) {
+		return ErrInvalidFileFormat
+	}
+
 	err = binary.Read(r, be, &reader.Flag)
 	if err != nil {
 		return err
 	}
+	if reader.Flag&^allFlags > 0 {
+		return ErrInvalidFileFormat
+	}
 
-	reader.buf = make([]byte, 8)
+	reader.buf = make([]byte, 8*reader.Words)
 
 	if reader.Flag&UNIK_COMPACT > 0 {
 		reader.compact = true
-		reader.bufsize = int((reader.K + 3) / 4)
+		reader.bufsize = bytesOfCode(reader.K, reader.Flag)
 	}
-	if reader.Flag&UNIK_SORTED > 0 {
+	if reader.Flag&UNIK_SUCCINCT > 0 {
+		if reader.Words != 1 || reader.Flag&(UNIK_COUNT|UNIK_INDEXED) > 0 {
+			return ErrInvalidFileFormat
+		}
+		reader.succinct = true
+	} else if reader.Flag&UNIK_SORTED > 0 && reader.Words == 1 {
 		reader.sorted = true
 		reader.buf2 = make([]byte, 17)
 	}
+	if reader.Flag&UNIK_COUNT > 0 {
+		reader.counted = true
+	}
+	if reader.Flag&UNIK_INDEXED > 0 {
+		reader.indexed = true
+	}
 
 	err = binary.Read(r, be, &reader.Number)
 	if err != nil {
 		return err
 	}
+
+	if reader.Flag&UNIK_SKETCH > 0 {
+		var params [2]uint64
+		err = binary.Read(r, be, &params)
+		if err != nil {
+			return err
+		}
+		reader.SketchSize = int(params[0])
+		reader.SketchScale = int(params[1])
+		if (reader.SketchSize > 0) == (reader.SketchScale > 0) {
+			return ErrInvalidFileFormat
+		}
+	}
+
+	if reader.Flag&UNIK_MINIMIZER > 0 {
+		var params [2]uint64
+		err = binary.Read(r, be, &params)
+		if err != nil {
+			return err
+		}
+		if params[0] == 0 || params[0] > math.MaxInt32 || params[1] > uint64(MinimizerHashed) {
+			return ErrInvalidFileFormat
+		}
+		reader.MinimizerWindow = int(params[0])
+		reader.MinimizerOrdering = uint8(params[1])
+	}
+
+	if reader.Flag&UNIK_SYNCMER > 0 {
+		var params [3]uint64
+		err = binary.Read(r, be, &params)
+		if err != nil {
+			return err
+		}
+		if params[0] > uint64(SyncmerOpen) || params[1] == 0 || params[1] > uint64(reader.K) ||
+			params[2] > uint64(reader.K)-params[1] {
+			return ErrInvalidFileFormat
+		}
+		reader.SyncmerType = uint8(params[0])
+		reader.SyncmerS = int(params[1])
+		reader.SyncmerOffset = int(params[2])
+	}
+
+	if reader.Flag&UNIK_SPACED > 0 {
+		var params [2]uint64
+		err = binary.Read(r, be, &params)
+		if err != nil {
+			return err
+		}
+		if params[0] > MaxSpan {
+			return ErrInvalidFileFormat
+		}
+		var seed *SpacedSeed
+		seed, err = NewSpacedSeedFromMask(int(params[0]), params[1])
+		if err != nil || seed.Weight != reader.K {
+			return ErrInvalidFileFormat
+		}
+		reader.SpacedSeed = seed.String()
+	}
+
+	if reader.Flag&UNIK_PROTEIN > 0 {
+		var alphabet uint64
+		err = binary.Read(r, be, &alphabet)
+		if err != nil {
+			return err
+		}
+		if alphabet >= uint64(len(alphabetGroups)) || reader.K > MaxProteinK || reader.succinct {
+			return ErrInvalidFileFormat
+		}
+		reader.Alphabet = uint8(alphabet)
+	}
+
+	if reader.Flag&UNIK_META > 0 {
+		var size uint32
+		err = binary.Read(r, be, &size)
+		if err != nil {
+			return err
+		}
+		if size > maxMetaSize {
+			return ErrInvalidFileFormat
+		}
+		data := make([]byte, size)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(data, &reader.Meta)
+		if err != nil {
+			return ErrInvalidFileFormat
+		}
+	}
 	return nil
 }
 
+// byteReader makes an io.Reader an io.ByteReader.
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (br *byteReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(br.r, br.buf[:])
+	return br.buf[0], err
+}
+
+// readCount reads the varint-encoded count following a k-mer.
+func (reader *Reader) readCount() (uint32, error) {
+	c, err := binary.ReadUvarint(reader.br)
+	if err != nil {
+		if err == io.EOF {
+			return 0, ErrBrokenFile
+		}
+		return 0, err
+	}
+	if c > math.MaxUint32 {
+		return 0, ErrCountOverflow
+	}
+	return uint32(c), nil
+}
+
 // Read reads one KmerCode.
+// For files with flag UNIK_COUNT, the count is discarded.
 func (reader *Reader) Read() (KmerCode, error) {
+	kcode, _, err := reader.ReadWithCount()
+	return kcode, err
+}
+
+// ReadWithCount reads one KmerCode and its count.
+// The count is 0 for files without flag UNIK_COUNT.
+// For files with flag UNIK_CHECKSUM, the checksum is validated at the end of file.
+func (reader *Reader) ReadWithCount() (KmerCode, uint32, error) {
+	kcode, count, err := reader.readWithCount()
+	if reader.cr != nil {
+		err = reader.checkEOF(err)
+	}
+	return kcode, count, err
+}
+
+func (reader *Reader) readWithCount() (KmerCode, uint32, error) {
+	if reader.Words != 1 {
+		return KmerCode{}, 0, ErrWordsMismatch
+	}
+	if reader.mm != nil {
+		kcode, err := reader.mm.Read()
+		return kcode, 0, err
+	}
+	if reader.pending != nil {
+		c := *reader.pending
+		reader.pending = nil
+		return c, reader.pendingCount, nil
+	}
 	var err error
+	if reader.succinct {
+		if reader.ssIter == nil {
+			if _, err = reader.SuccinctSet(); err != nil {
+				return KmerCode{}, 0, err
+			}
+		}
+		code, ok := reader.ssIter.next()
+		if !ok {
+			return KmerCode{}, 0, io.EOF
+		}
+		return KmerCode{Code: code, K: reader.K}, 0, nil
+	}
+	var count uint32
+	if reader.indexed {
+		if reader.eof {
+			return KmerCode{}, 0, io.EOF
+		}
+		if reader.remaining == 0 {
+			if err = reader.nextBlock(); err != nil {
+				return KmerCode{}, 0, err
+			}
+		}
+		reader.remaining--
+	}
 	if reader.sorted {
 		if reader.prev != nil {
 			c := *reader.prev
 			reader.prev = nil
-			return c, nil
+			return c, reader.prevCount, nil
 		}
 
 		buf2 := reader.buf2
@@ -170,51 +534,134 @@ func (reader *Reader) Read() (KmerCode, error) {
 		var nReaded int
 		nReaded, err = io.ReadFull(r, buf2[0:1])
 		if err != nil {
-			return KmerCode{}, err
+			return KmerCode{}, 0, err
 		}
 
 		ctrlByte := buf2[0]
 		if ctrlByte&128 > 0 { // last one
 			nReaded, err = io.ReadFull(r, buf2[0:8])
 			if err != nil {
-				return KmerCode{}, err
+				return KmerCode{}, 0, err
+			}
+			if reader.counted {
+				count, err = reader.readCount()
+				if err != nil {
+					return KmerCode{}, 0, err
+				}
 			}
-			return KmerCode{Code: be.Uint64(buf2[0:8]), K: reader.K}, nil
+			return KmerCode{Code: be.Uint64(buf2[0:8]), K: reader.K}, count, nil
 		}
 
-		// parse control byte
+		// parse control byte, only the lowest 6 bits are used
+		if ctrlByte&127 >= 64 {
+			return KmerCode{}, 0, ErrBrokenFile
+		}
 		encodedBytes := ctrlByte2ByteLengths[ctrlByte]
 		nEncodedBytes := int(encodedBytes[0] + encodedBytes[1])
 
 		// read encoded bytes
 		nReaded, err = io.ReadFull(r, buf2[0:nEncodedBytes])
 		if err != nil {
-			return KmerCode{}, err
+			return KmerCode{}, 0, err
 		}
 		if nReaded < nEncodedBytes {
-			return KmerCode{}, ErrBrokenFile
+			return KmerCode{}, 0, ErrBrokenFile
 		}
 
 		decodedVals, nDecoded := Uint64s(ctrlByte, buf2[0:nEncodedBytes])
 		if nDecoded == 0 {
-			return KmerCode{}, ErrBrokenFile
+			return KmerCode{}, 0, ErrBrokenFile
+		}
+
+		if reader.counted {
+			count, err = reader.readCount()
+			if err != nil {
+				return KmerCode{}, 0, err
+			}
+			reader.prevCount, err = reader.readCount()
+			if err != nil {
+				return KmerCode{}, 0, err
+			}
 		}
 
 		code := decodedVals[0] + reader.offset
 		reader.prev = &KmerCode{Code: code + decodedVals[1], K: reader.K}
 		reader.offset = code + decodedVals[1]
 
-		return KmerCode{Code: code, K: reader.K}, nil
+		return KmerCode{Code: code, K: reader.K}, count, nil
 	} else if reader.compact {
 		_, err = io.ReadFull(reader.r, reader.buf[8-reader.bufsize:])
 	} else {
 		_, err = io.ReadFull(reader.r, reader.buf)
 	}
 	if err != nil {
-		return KmerCode{}, err
+		return KmerCode{}, 0, err
+	}
+	if reader.counted {
+		count, err = reader.readCount()
+		if err != nil {
+			return KmerCode{}, 0, err
+		}
+	}
+
+	return KmerCode{Code: be.Uint64(reader.buf), K: reader.K}, count, nil
+}
+
+// Read2 reads one KmerCode2, for files with K > 32.
+// For files with flag UNIK_COUNT, the count is discarded.
+func (reader *Reader) Read2() (KmerCode2, error) {
+	kcode, _, err := reader.ReadWithCount2()
+	return kcode, err
+}
+
+// ReadWithCount2 reads one KmerCode2 and its count, for files with K > 32.
+// The count is 0 for files without flag UNIK_COUNT.
+// For files with flag UNIK_CHECKSUM, the checksum is validated at the end of file.
+func (reader *Reader) ReadWithCount2() (KmerCode2, uint32, error) {
+	kcode, count, err := reader.readWithCount2()
+	if reader.cr != nil {
+		err = reader.checkEOF(err)
+	}
+	return kcode, count, err
+}
+
+func (reader *Reader) readWithCount2() (KmerCode2, uint32, error) {
+	if reader.Words != 2 {
+		return KmerCode2{}, 0, ErrWordsMismatch
+	}
+	if reader.mm != nil {
+		kcode, err := reader.mm.Read2()
+		return kcode, 0, err
+	}
+	var err error
+	if reader.indexed {
+		if reader.eof {
+			return KmerCode2{}, 0, io.EOF
+		}
+		if reader.remaining == 0 {
+			if err = reader.nextBlock(); err != nil {
+				return KmerCode2{}, 0, err
+			}
+		}
+		reader.remaining--
+	}
+	if reader.compact {
+		_, err = io.ReadFull(reader.r, reader.buf[16-reader.bufsize:])
+	} else {
+		_, err = io.ReadFull(reader.r, reader.buf)
+	}
+	if err != nil {
+		return KmerCode2{}, 0, err
+	}
+	var count uint32
+	if reader.counted {
+		count, err = reader.readCount()
+		if err != nil {
+			return KmerCode2{}, 0, err
+		}
 	}
 
-	return KmerCode{Code: be.Uint64(reader.buf), K: reader.K}, nil
+	return KmerCode2{Code: [2]uint64{be.Uint64(reader.buf[0:8]), be.Uint64(reader.buf[8:16])}, K: reader.K}, count, nil
 }
 
 // Writer writes KmerCode.
@@ -227,35 +674,82 @@ type Writer struct {
 	buf     []byte
 	bufsize int
 
-	sorted bool //
-	prev   *KmerCode
-	buf2   []byte
-	offset uint64
+	sorted    bool //
+	prev      *KmerCode
+	prevCount uint32
+	buf2      []byte
+	offset    uint64
+
+	counted bool // every KmerCode is followed by its count.
+	buf3    []byte
+
+	idx *writerIndex // for files with flag UNIK_INDEXED
+
+	cw       *checksumWriter // for files with flag UNIK_CHECKSUM
+	nWritten int64
+
+	succinct bool     // k-mers are saved in SuccinctSet in Flush.
+	codes    []uint64 // k-mers for SuccinctSet
 }
 
 // NewWriter creates a Writer.
+// For K > 32, k-mers should be written with Write2.
+// Flush should be called at the end, which writes the checksum trailer.
 func NewWriter(w io.Writer, k int, flag uint32) (*Writer, error) {
-	if k == 0 || k > 32 {
-		return nil, ErrKOverflow
+	if k == 0 || k > MaxK2 {
+		return nil, ErrKOverflow2
+	}
+	flag |= UNIK_CHECKSUM
+	if flag&UNIK_SUCCINCT > 0 {
+		if k > 32 || flag&(UNIK_COUNT|UNIK_INDEXED) > 0 {
+			return nil, ErrSuccinctUnsupported
+		}
+		flag |= UNIK_SORTED
+		flag &^= UNIK_COMPACT
 	}
 
 	writer := &Writer{
-		Header: Header{MainVersion: MainVersion, MinorVersion: MinorVersion, K: k, Flag: flag, Number: -1},
+		Header: Header{MainVersion: MainVersion, MinorVersion: MinorVersion, K: k, Words: wordsOfK(k), Flag: flag, Number: -1},
 		w:      w,
 	}
 
-	writer.buf = make([]byte, 8)
+	writer.buf = make([]byte, 8*writer.Words)
 	if writer.Flag&UNIK_COMPACT > 0 {
 		writer.compact = true
-		writer.bufsize = int(k+3) / 4
 	}
-	if writer.Flag&UNIK_SORTED > 0 {
+	if writer.Flag&UNIK_SUCCINCT > 0 {
+		writer.succinct = true
+	} else if writer.Flag&UNIK_SORTED > 0 && writer.Words == 1 {
 		writer.sorted = true
 		writer.buf2 = make([]byte, 16)
 	}
+	if writer.Flag&UNIK_COUNT > 0 {
+		writer.counted = true
+		writer.buf3 = make([]byte, binary.MaxVarintLen32)
+	}
+	writer.cw = &checksumWriter{w: w, crc: crc32.New(crc32cTable)}
+	writer.w = writer.cw
+	if writer.Flag&UNIK_INDEXED > 0 {
+		writer.idx = &writerIndex{dst: &countingWriter{w: writer.cw}, blockSize: DefaultIndexBlockSize}
+		writer.w = writer.idx.dst
+	}
 	return writer, nil
 }
 
+// SetIndexBlockSize sets the number of k-mers in a block for files with flag
+// UNIK_INDEXED, it should be even for sorted files.
+// It should be called before writing any k-mer.
+func (writer *Writer) SetIndexBlockSize(n int) error {
+	if writer.idx == nil {
+		return ErrNotIndexed
+	}
+	if n <= 0 || (writer.sorted && n&1 == 1) {
+		return ErrInvalidIndexBlockSize
+	}
+	writer.idx.blockSize = n
+	return nil
+}
+
 // WriteHeader writes file header
 func (writer *Writer) WriteHeader() (err error) {
 	if writer.wroteHeader {
@@ -268,11 +762,16 @@ func (writer *Writer) WriteHeader() (err error) {
 		return err
 	}
 
-	err = binary.Write(w, be, [4]uint8{writer.MainVersion, MinorVersion, uint8(writer.K), 0})
+	err = binary.Write(w, be, [4]uint8{writer.MainVersion, MinorVersion, uint8(writer.K), uint8(writer.Words)})
 	if err != nil {
 		return err
 	}
 
+	if len(writer.Meta) > 0 {
+		writer.Flag |= UNIK_META
+	} else {
+		writer.Flag &^= UNIK_META
+	}
 	err = binary.Write(w, be, writer.Flag)
 	if err != nil {
 		return err
@@ -283,12 +782,116 @@ func (writer *Writer) WriteHeader() (err error) {
 		return err
 	}
 
+	if writer.Flag&UNIK_SKETCH > 0 {
+		if (writer.SketchSize > 0) == (writer.SketchScale > 0) {
+			return ErrInvalidSketch
+		}
+		err = binary.Write(w, be, [2]uint64{uint64(writer.SketchSize), uint64(writer.SketchScale)})
+		if err != nil {
+			return err
+		}
+	}
+
+	if writer.Flag&UNIK_MINIMIZER > 0 {
+		if writer.MinimizerWindow <= 0 || writer.MinimizerOrdering > MinimizerHashed {
+			return ErrInvalidMinimizer
+		}
+		err = binary.Write(w, be, [2]uint64{uint64(writer.MinimizerWindow), uint64(writer.MinimizerOrdering)})
+		if err != nil {
+			return err
+		}
+	}
+
+	if writer.Flag&UNIK_SYNCMER > 0 {
+		if writer.K > 32 || writer.SyncmerType > SyncmerOpen || writer.SyncmerS <= 0 || writer.SyncmerS > writer.K ||
+			writer.SyncmerOffset < 0 || writer.SyncmerOffset > writer.K-writer.SyncmerS {
+			return ErrInvalidSyncmer
+		}
+		err = binary.Write(w, be, [3]uint64{uint64(writer.SyncmerType), uint64(writer.SyncmerS), uint64(writer.SyncmerOffset)})
+		if err != nil {
+			return err
+		}
+	}
+
+	if writer.Flag&UNIK_SPACED > 0 {
+		var seed *SpacedSeed
+		seed, err = NewSpacedSeed(writer.SpacedSeed)
+		if err != nil {
+			return err
+		}
+		if seed.Weight != writer.K {
+			return ErrKMismatch
+		}
+		err = binary.Write(w, be, [2]uint64{uint64(seed.Span), seed.Mask})
+		if err != nil {
+			return err
+		}
+	}
+
+	if writer.Flag&UNIK_PROTEIN > 0 {
+		if writer.K > MaxProteinK {
+			return ErrProteinKOverflow
+		}
+		if int(writer.Alphabet) >= len(alphabetGroups) {
+			return ErrInvalidAlphabet
+		}
+		if writer.succinct {
+			return ErrSuccinctUnsupported
+		}
+		err = binary.Write(w, be, uint64(writer.Alphabet))
+		if err != nil {
+			return err
+		}
+	}
+	if writer.compact { // the flag UNIK_PROTEIN may be set after creating the writer
+		writer.bufsize = bytesOfCode(writer.K, writer.Flag)
+	}
+
+	if writer.Flag&UNIK_META > 0 {
+		var data []byte
+		data, err = json.Marshal(writer.Meta)
+		if err != nil {
+			return err
+		}
+		if len(data) > maxMetaSize {
+			return ErrMetaTooBig
+		}
+		err = binary.Write(w, be, uint32(len(data)))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+
 	writer.wroteHeader = true
+	if writer.cw != nil { // checksum of data after header
+		writer.cw.crc.Reset()
+	}
+	if writer.idx != nil { // k-mers are written to blocks
+		writer.w = &writer.idx.block
+	}
 	return nil
 }
 
-// WriteKmer writes one k-mer.
+// WriteKmer writes one k-mer, which is a protein k-mer for files with flag UNIK_PROTEIN.
 func (writer *Writer) WriteKmer(mer []byte) error {
+	if writer.Flag&UNIK_PROTEIN > 0 {
+		kcode, err := NewProteinKmerCode(mer, writer.Alphabet)
+		if err != nil {
+			return err
+		}
+		return writer.Write(kcode)
+	}
+	if writer.Words == 2 {
+		kcode, err := NewKmerCode2(mer)
+		if err != nil {
+			return err
+		}
+		return writer.Write2(kcode)
+	}
 	kcode, err := NewKmerCode(mer)
 	if err != nil {
 		return err
@@ -296,12 +899,38 @@ func (writer *Writer) WriteKmer(mer []byte) error {
 	return writer.Write(kcode)
 }
 
+// writeCount writes the count in varint.
+func (writer *Writer) writeCount(count uint32) (err error) {
+	n := binary.PutUvarint(writer.buf3, uint64(count))
+	_, err = writer.w.Write(writer.buf3[:n])
+	return err
+}
+
 // Write writes one KmerCode.
+// For files with flag UNIK_COUNT, the count is 1.
 func (writer *Writer) Write(kcode KmerCode) (err error) {
+	return writer.WriteWithCount(kcode, 1)
+}
+
+// WriteWithCount writes one KmerCode and its count.
+// The count is ignored for files without flag UNIK_COUNT.
+func (writer *Writer) WriteWithCount(kcode KmerCode, count uint32) (err error) {
+	if writer.Words != 1 {
+		return ErrWordsMismatch
+	}
 	if writer.K != kcode.K {
 		return ErrKMismatch
 	}
 
+	if writer.succinct { // written in Flush
+		if len(writer.codes) > 0 && kcode.Code <= writer.codes[len(writer.codes)-1] {
+			return ErrNotSortedUnique
+		}
+		writer.codes = append(writer.codes, kcode.Code)
+		writer.nWritten++
+		return nil
+	}
+
 	// lazily write header
 	if !writer.wroteHeader {
 		err = writer.WriteHeader()
@@ -310,10 +939,18 @@ func (writer *Writer) Write(kcode KmerCode) (err error) {
 		}
 		writer.wroteHeader = true
 	}
+	if writer.idx != nil {
+		err = writer.newBlockIfNeeded([2]uint64{0, kcode.Code})
+		if err != nil {
+			return err
+		}
+	}
+	writer.nWritten++
 
 	if writer.sorted {
 		if writer.prev == nil { // write it later
 			writer.prev = &kcode
+			writer.prevCount = count
 			return nil
 		}
 
@@ -324,6 +961,17 @@ func (writer *Writer) Write(kcode KmerCode) (err error) {
 
 		err = binary.Write(writer.w, be, ctrlByte)
 		err = binary.Write(writer.w, be, writer.buf2[0:nEncodedByte])
+		if err != nil {
+			return err
+		}
+		if writer.counted {
+			err = writer.writeCount(writer.prevCount)
+			if err != nil {
+				return err
+			}
+			return writer.writeCount(count)
+		}
+		return nil
 	} else if writer.compact {
 		be.PutUint64(writer.buf, kcode.Code)
 		err = binary.Write(writer.w, be, writer.buf[8-writer.bufsize:])
@@ -334,20 +982,104 @@ func (writer *Writer) Write(kcode KmerCode) (err error) {
 	if err != nil {
 		return err
 	}
+	if writer.counted {
+		return writer.writeCount(count)
+	}
 	return nil
 }
 
-// Flush write the last k-mer
-func (writer *Writer) Flush() (err error) {
-	if !writer.sorted || writer.prev == nil {
-		return nil
+// Write2 writes one KmerCode2, for files with K > 32.
+// For files with flag UNIK_COUNT, the count is 1.
+func (writer *Writer) Write2(kcode KmerCode2) (err error) {
+	return writer.WriteWithCount2(kcode, 1)
+}
+
+// WriteWithCount2 writes one KmerCode2 and its count, for files with K > 32.
+// The count is ignored for files without flag UNIK_COUNT.
+func (writer *Writer) WriteWithCount2(kcode KmerCode2, count uint32) (err error) {
+	if writer.Words != 2 {
+		return ErrWordsMismatch
+	}
+	if writer.K != kcode.K {
+		return ErrKMismatch
+	}
+
+	// lazily write header
+	if !writer.wroteHeader {
+		err = writer.WriteHeader()
+		if err != nil {
+			return err
+		}
+	}
+	if writer.idx != nil {
+		err = writer.newBlockIfNeeded(kcode.Code)
+		if err != nil {
+			return err
+		}
+	}
+	writer.nWritten++
+
+	be.PutUint64(writer.buf[0:8], kcode.Code[0])
+	be.PutUint64(writer.buf[8:16], kcode.Code[1])
+	if writer.compact {
+		_, err = writer.w.Write(writer.buf[16-writer.bufsize:])
+	} else {
+		_, err = writer.w.Write(writer.buf)
 	}
-	// write last k-mer
-	err = binary.Write(writer.w, be, uint8(128))
-	err = binary.Write(writer.w, be, writer.prev.Code)
 	if err != nil {
 		return err
 	}
-	writer.prev = nil
+	if writer.counted {
+		return writer.writeCount(count)
+	}
 	return nil
 }
+
+// Flush write the last k-mer, the block index for files with flag UNIK_INDEXED,
+// and the checksum trailer. Nothing is done if neither the header nor any k-mer
+// has been written, so WriteHeader should be called for a file with no k-mers.
+func (writer *Writer) Flush() (err error) {
+	if !writer.wroteHeader && !writer.succinct {
+		return nil
+	}
+	if writer.succinct && !writer.wroteHeader {
+		if writer.Flag&UNIK_PROTEIN > 0 {
+			return ErrSuccinctUnsupported
+		}
+		var s *SuccinctSet
+		s, err = NewSuccinctSet(writer.K, writer.codes)
+		if err != nil {
+			return err
+		}
+		writer.codes = nil
+		writer.Number = int64(s.Len())
+		if err = writer.WriteHeader(); err != nil {
+			return err
+		}
+		if err = s.writeTo(writer.w); err != nil {
+			return err
+		}
+	}
+	if writer.sorted && writer.prev != nil {
+		// write last k-mer
+		err = binary.Write(writer.w, be, uint8(128))
+		err = binary.Write(writer.w, be, writer.prev.Code)
+		if err != nil {
+			return err
+		}
+		if writer.counted {
+			err = writer.writeCount(writer.prevCount)
+			if err != nil {
+				return err
+			}
+		}
+		writer.prev = nil
+	}
+	if writer.idx != nil {
+		err = writer.writeIndex()
+		if err != nil {
+			return err
+		}
+	}
+	return writer.writeChecksum()
+}
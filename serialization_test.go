@@ -23,6 +23,7 @@ package unikmer
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"math/rand"
@@ -90,6 +91,196 @@ func TestWriter(t *testing.T) {
 	}
 }
 
+func TestWriter2(t *testing.T) {
+	var file string
+
+	var mers, mers2 [][]byte
+	var err error
+
+	for _, k := range []int{32, 33, 41, 51, 63, 64} {
+		for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED} {
+			func(flag uint32) {
+				mers = genKmers(k, 10001, flag&UNIK_SORTED > 0)
+
+				file = fmt.Sprintf("t.k%d.unik", k)
+
+				err = write(mers, file, flag)
+				if err != nil {
+					t.Error(err)
+				}
+				defer func() {
+					err = os.Remove(file)
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+
+				mers2, err = read(file)
+				if err != nil {
+					t.Error(err)
+				}
+
+				if len(mers2) != len(mers) {
+					t.Errorf("write and read: number err")
+				}
+				for i := 0; i < len(mers); i++ {
+					if !bytes.Equal(mers[i], mers2[i]) {
+						t.Errorf("write and read: data mismatch. %d: %s vs %s", i, mers[i], mers2[i])
+					}
+				}
+			}(flag)
+		}
+	}
+}
+
+func TestWriterWithCount(t *testing.T) {
+	for _, k := range []int{21, 31, 51} {
+		for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_SORTED} {
+			for _, n := range []int{10000, 10001} {
+				flag |= UNIK_COUNT
+				mers := genKmers(k, n, true)
+				counts := make([]uint32, n)
+				for i := range counts {
+					counts[i] = uint32(rand.Intn(1 << uint(rand.Intn(32))))
+				}
+
+				var buf bytes.Buffer
+				writer, err := NewWriter(&buf, k, flag)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, mer := range mers {
+					if k > 32 {
+						kcode, _ := NewKmerCode2(mer)
+						err = writer.WriteWithCount2(kcode, counts[i])
+					} else {
+						kcode, _ := NewKmerCode(mer)
+						err = writer.WriteWithCount(kcode, counts[i])
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err = writer.Flush(); err != nil {
+					t.Fatal(err)
+				}
+
+				// hide ReadByte of bytes.Buffer
+				reader, err := NewReader(struct{ io.Reader }{&buf})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var kcode KmerCode
+				var kcode2 KmerCode2
+				var mer []byte
+				var count uint32
+				var i int
+				for ; ; i++ {
+					if k > 32 {
+						kcode2, count, err = reader.ReadWithCount2()
+					} else {
+						kcode, count, err = reader.ReadWithCount()
+					}
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						t.Fatal(err)
+					}
+					if k > 32 {
+						mer = kcode2.Bytes()
+					} else {
+						mer = kcode.Bytes()
+					}
+					if i >= n || !bytes.Equal(mer, mers[i]) || count != counts[i] {
+						t.Fatalf("k=%d flag=%d: data mismatch at %d", k, flag, i)
+					}
+				}
+				if i != n {
+					t.Errorf("k=%d flag=%d: number mismatch: %d vs %d", k, flag, i, n)
+				}
+			}
+		}
+	}
+}
+
+func TestWriterMeta(t *testing.T) {
+	meta := map[string]string{"source": "a.fa,b.fa", "command": "unikmer count -k 21"}
+	for _, flag := range []uint32{0, UNIK_SORTED, UNIK_SORTED | UNIK_INDEXED} {
+		mers := genKmers(21, 1001, true)
+
+		var buf bytes.Buffer
+		writer, err := NewWriter(&buf, 21, flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Meta = meta
+		for _, mer := range mers {
+			if err = writer.WriteKmer(mer); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		reader, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.Flag&UNIK_META == 0 || len(reader.Meta) != len(meta) {
+			t.Fatalf("flag=%d: metadata missing", flag)
+		}
+		for key, value := range meta {
+			if reader.Meta[key] != value {
+				t.Errorf("flag=%d: metadata mismatch: %s", flag, key)
+			}
+		}
+
+		var i int
+		for ; ; i++ {
+			_, err = reader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatal(err)
+			}
+		}
+		if i != len(mers) {
+			t.Errorf("flag=%d: number mismatch: %d vs %d", flag, i, len(mers))
+		}
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	v2 := func(version [4]uint8, flag uint32) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, be, Magic)
+		binary.Write(&buf, be, version)
+		binary.Write(&buf, be, flag)
+		binary.Write(&buf, be, int64(2))
+		binary.Write(&buf, be, [2]uint64{1, 2})
+		return buf.Bytes()
+	}
+
+	// files of v2 have no checksum trailer
+	n, err := readAll(v2([4]uint8{2, 1, 21, 1}, UNIK_CANONICAL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("number mismatch: %d vs %d", n, 2)
+	}
+
+	if _, err = readAll(v2([4]uint8{MainVersion + 1, 0, 21, 1}, 0)); err == nil {
+		t.Errorf("file of newer version not detected")
+	}
+	if _, err = readAll(v2([4]uint8{2, 1, 21, 1}, allFlags+1)); err != ErrInvalidFileFormat {
+		t.Errorf("unknown flag not detected: %v", err)
+	}
+}
+
 func write(mers [][]byte, file string, flag uint32) error {
 	w, err := os.Create(file)
 	if err != nil {
@@ -136,6 +327,22 @@ func read(file string) ([][]byte, error) {
 	// fmt.Println(reader.Header)
 
 	mers := make([][]byte, 0, 1000)
+	if reader.K > 32 {
+		var kcode KmerCode2
+		for {
+			kcode, err = reader.Read2()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, err
+			}
+
+			mers = append(mers, kcode.Bytes())
+		}
+		return mers, nil
+	}
+
 	var kcode KmerCode
 	for {
 		kcode, err = reader.Read()
@@ -0,0 +1,276 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrSketchMismatch means sketches of different types or parameters are compared.
+var ErrSketchMismatch = errors.New("unikmer: sketch type or parameters mismatch")
+
+// ErrInvalidSketch means invalid sketch parameters.
+var ErrInvalidSketch = errors.New("unikmer: invalid sketch parameters, either size or scale should be positive")
+
+// hashSeed is xored with k-mer codes before hashing, so that the k-mer
+// of poly-A (code 0) does not always have the minimum hash value.
+const hashSeed uint64 = 0x9e3779b97f4a7c15
+
+// Hash64 returns the hash value of a k-mer code (k <= 32),
+// with the finalizer of MurmurHash3, which is a bijection.
+func Hash64(code uint64) uint64 {
+	code ^= hashSeed
+	code ^= code >> 33
+	code *= 0xff51afd7ed558ccd
+	code ^= code >> 33
+	code *= 0xc4ceb9fe1a85ec53
+	code ^= code >> 33
+	return code
+}
+
+// Hash2 returns the hash value of a two-word k-mer code (k > 32).
+func Hash2(code [2]uint64) uint64 {
+	return Hash64(code[1] ^ Hash64(code[0]))
+}
+
+// Sketch is a bottom-s MinHash or FracMinHash (scaled MinHash) sketch of
+// a k-mer set. K-mer codes are kept along with their hash values, so the
+// sketch can be saved as a normal binary file.
+type Sketch struct {
+	K     int
+	Size  int // s of bottom-s MinHash, 0 for FracMinHash
+	Scale int // scale of FracMinHash, 0 for MinHash
+
+	maxHash uint64               // for FracMinHash
+	m       map[uint64][2]uint64 // hash -> code, code[0] is 0 for k <= 32
+	h       hashHeap             // max-heap of hashes for MinHash
+}
+
+// NewMinHashSketch creates a bottom-s MinHash sketch.
+func NewMinHashSketch(k int, size int) (*Sketch, error) {
+	if k <= 0 || k > MaxK2 {
+		return nil, ErrKOverflow2
+	}
+	if size <= 0 {
+		return nil, ErrInvalidSketch
+	}
+	return &Sketch{
+		K:    k,
+		Size: size,
+		m:    make(map[uint64][2]uint64, size),
+		h:    make(hashHeap, 0, size),
+	}, nil
+}
+
+// NewFracMinHashSketch creates a FracMinHash sketch, in which k-mers with
+// hash values smaller than 1/scale of the max hash value are kept.
+func NewFracMinHashSketch(k int, scale int) (*Sketch, error) {
+	if k <= 0 || k > MaxK2 {
+		return nil, ErrKOverflow2
+	}
+	if scale <= 0 {
+		return nil, ErrInvalidSketch
+	}
+	return &Sketch{
+		K:       k,
+		Scale:   scale,
+		maxHash: math.MaxUint64 / uint64(scale),
+		m:       make(map[uint64][2]uint64, 1024),
+	}, nil
+}
+
+// NewSketchFromHeader creates an empty sketch with parameters from header of
+// a binary file with flag UNIK_SKETCH.
+func NewSketchFromHeader(h Header) (*Sketch, error) {
+	if h.Flag&UNIK_SKETCH == 0 {
+		return nil, ErrInvalidSketch
+	}
+	if h.SketchSize > 0 {
+		return NewMinHashSketch(h.K, h.SketchSize)
+	}
+	return NewFracMinHashSketch(h.K, h.SketchScale)
+}
+
+// Add adds a k-mer code (k <= 32).
+func (s *Sketch) Add(code uint64) {
+	s.add(Hash64(code), [2]uint64{0, code})
+}
+
+// Add2 adds a two-word k-mer code (k > 32).
+func (s *Sketch) Add2(code [2]uint64) {
+	s.add(Hash2(code), code)
+}
+
+func (s *Sketch) add(hash uint64, code [2]uint64) {
+	if s.Scale > 0 {
+		if hash <= s.maxHash {
+			s.m[hash] = code
+		}
+		return
+	}
+
+	if _, ok := s.m[hash]; ok {
+		return
+	}
+	if len(s.h) < s.Size {
+		heap.Push(&s.h, hash)
+		s.m[hash] = code
+		return
+	}
+	if hash < s.h[0] {
+		delete(s.m, s.h[0])
+		s.h[0] = hash
+		heap.Fix(&s.h, 0)
+		s.m[hash] = code
+	}
+}
+
+// Len returns the number of k-mers in the sketch.
+func (s *Sketch) Len() int {
+	return len(s.m)
+}
+
+// Hashes returns sorted hash values.
+func (s *Sketch) Hashes() []uint64 {
+	hashes := make([]uint64, 0, len(s.m))
+	for hash := range s.m {
+		hashes = append(hashes, hash)
+	}
+	sort.Sort(CodeSlice(hashes))
+	return hashes
+}
+
+// Codes returns sorted k-mer codes (k <= 32).
+func (s *Sketch) Codes() []uint64 {
+	codes := make([]uint64, 0, len(s.m))
+	for _, code := range s.m {
+		codes = append(codes, code[1])
+	}
+	sort.Sort(CodeSlice(codes))
+	return codes
+}
+
+// Codes2 returns sorted two-word k-mer codes (k > 32).
+func (s *Sketch) Codes2() [][2]uint64 {
+	codes := make([][2]uint64, 0, len(s.m))
+	for _, code := range s.m {
+		codes = append(codes, code)
+	}
+	sort.Sort(CodeSlice2(codes))
+	return codes
+}
+
+// compatible checks whether two sketches are comparable.
+func (s *Sketch) compatible(o *Sketch) error {
+	if s.K != o.K {
+		return ErrKMismatch
+	}
+	if s.Size != o.Size || s.Scale != o.Scale {
+		return ErrSketchMismatch
+	}
+	return nil
+}
+
+// Inter returns the number of shared hash values of two sketches.
+func (s *Sketch) Inter(o *Sketch) (int, error) {
+	if err := s.compatible(o); err != nil {
+		return 0, err
+	}
+	a, b := s.m, o.m
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	var n int
+	var ok bool
+	for hash := range a {
+		if _, ok = b[hash]; ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Jaccard estimates the Jaccard index of the two k-mer sets.
+// For MinHash, it's the fraction of shared ones in the bottom-s hash values
+// of the union. For FracMinHash, it's the Jaccard index of the two sketches.
+func (s *Sketch) Jaccard(o *Sketch) (float64, error) {
+	if err := s.compatible(o); err != nil {
+		return 0, err
+	}
+
+	if s.Scale > 0 {
+		inter, _ := s.Inter(o)
+		union := len(s.m) + len(o.m) - inter
+		if union == 0 {
+			return 0, nil
+		}
+		return float64(inter) / float64(union), nil
+	}
+
+	// merge sorted hash values
+	a, b := s.Hashes(), o.Hashes()
+	var i, j, n, inter int
+	for n < s.Size && (i < len(a) || j < len(b)) {
+		if j == len(b) || (i < len(a) && a[i] < b[j]) {
+			i++
+		} else if i == len(a) || b[j] < a[i] {
+			j++
+		} else {
+			inter++
+			i++
+			j++
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return float64(inter) / float64(n), nil
+}
+
+// MashDistance computes Mash distance from Jaccard index.
+func MashDistance(jaccard float64, k int) float64 {
+	if jaccard <= 0 {
+		return 1
+	}
+	if jaccard >= 1 {
+		return 0
+	}
+	return -1 / float64(k) * math.Log(2*jaccard/(1+jaccard))
+}
+
+// hashHeap is a max-heap of hash values.
+type hashHeap []uint64
+
+func (h hashHeap) Len() int            { return len(h) }
+func (h hashHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h hashHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hashHeap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+func (h *hashHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
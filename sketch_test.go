@@ -0,0 +1,140 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// twoSets returns two random k-mer sets with a Jaccard index of 1/3.
+func twoSets(n int) ([]uint64, []uint64) {
+	codes := make([]uint64, 3*n)
+	seen := make(map[uint64]struct{}, 3*n)
+	var code uint64
+	for i := range codes {
+		for {
+			code = rand.Uint64() & MaxCode[21]
+			if _, ok := seen[code]; !ok {
+				break
+			}
+		}
+		seen[code] = struct{}{}
+		codes[i] = code
+	}
+	return codes[:2*n], codes[n:]
+}
+
+func TestSketchJaccard(t *testing.T) {
+	a, b := twoSets(30000)
+	for _, minhash := range []bool{true, false} {
+		var s1, s2 *Sketch
+		var err error
+		if minhash {
+			s1, err = NewMinHashSketch(21, 2000)
+			s2, _ = NewMinHashSketch(21, 2000)
+		} else {
+			s1, err = NewFracMinHashSketch(21, 20)
+			s2, _ = NewFracMinHashSketch(21, 20)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, code := range a {
+			s1.Add(code)
+			s1.Add(code) // duplicated k-mers
+		}
+		for _, code := range b {
+			s2.Add(code)
+		}
+		if minhash && (s1.Len() != 2000 || s2.Len() != 2000) {
+			t.Errorf("MinHash: unexpected sketch size: %d, %d", s1.Len(), s2.Len())
+		}
+
+		j, err := s1.Jaccard(s2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(j-1.0/3) > 0.05 {
+			t.Errorf("minhash=%v: Jaccard estimate %f far from 1/3", minhash, j)
+		}
+	}
+
+	s1, _ := NewMinHashSketch(21, 100)
+	s2, _ := NewFracMinHashSketch(21, 100)
+	if _, err := s1.Jaccard(s2); err != ErrSketchMismatch {
+		t.Errorf("sketches of different types should not be compared")
+	}
+}
+
+func TestSketchHeader(t *testing.T) {
+	s, _ := NewFracMinHashSketch(21, 100)
+	a, _ := twoSets(10000)
+	for _, code := range a {
+		s.Add(code)
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, s.K, UNIK_SORTED|UNIK_SKETCH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.SketchScale = s.Scale
+	codes := s.Codes()
+	writer.Number = int64(len(codes))
+	for _, code := range codes {
+		if err = writer.Write(KmerCode{Code: code, K: s.K}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := NewSketchFromHeader(reader.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kcode KmerCode
+	for {
+		kcode, err = reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		s2.Add(kcode.Code)
+	}
+	if s2.Scale != 100 || s2.Len() != s.Len() {
+		t.Errorf("sketch mismatch after writing and reading")
+	}
+	if j, _ := s.Jaccard(s2); j != 1 {
+		t.Errorf("sketch mismatch after writing and reading")
+	}
+}
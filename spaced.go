@@ -0,0 +1,216 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// ErrInvalidSpacedSeed means invalid spaced seed.
+var ErrInvalidSpacedSeed = errors.New("unikmer: invalid spaced seed, it should only contain 0 and 1, start and end with 1, with a length of at most 64 and at most 32 ones")
+
+// MaxSpan is the maximum length of spaced seeds.
+const MaxSpan = 64
+
+// SpacedSeed is a spaced seed (gapped k-mer pattern), e.g., "1101101",
+// where 1s are care positions and 0s are don't-care positions.
+// Only bases at care positions are encoded, so K of k-mers is the weight,
+// i.e., the number of care positions.
+type SpacedSeed struct {
+	Span   int    // length of the seed
+	Weight int    // number of care positions
+	Mask   uint64 // bit i is 1 for care position i
+
+	pos []int // care positions
+}
+
+// NewSpacedSeed parses a spaced seed in string, e.g., "1101101".
+func NewSpacedSeed(seed string) (*SpacedSeed, error) {
+	if len(seed) == 0 || len(seed) > MaxSpan {
+		return nil, ErrInvalidSpacedSeed
+	}
+	var mask uint64
+	for i := 0; i < len(seed); i++ {
+		switch seed[i] {
+		case '1':
+			mask |= 1 << uint(i)
+		case '0':
+		default:
+			return nil, ErrInvalidSpacedSeed
+		}
+	}
+	return NewSpacedSeedFromMask(len(seed), mask)
+}
+
+// NewSpacedSeedFromMask creates a spaced seed from the span and mask.
+func NewSpacedSeedFromMask(span int, mask uint64) (*SpacedSeed, error) {
+	if span <= 0 || span > MaxSpan || (span < 64 && mask>>uint(span) != 0) ||
+		mask&1 == 0 || mask>>uint(span-1)&1 == 0 || bits.OnesCount64(mask) > 32 {
+		return nil, ErrInvalidSpacedSeed
+	}
+	seed := &SpacedSeed{Span: span, Weight: bits.OnesCount64(mask), Mask: mask}
+	seed.pos = make([]int, 0, seed.Weight)
+	for i := 0; i < span; i++ {
+		if mask>>uint(i)&1 == 1 {
+			seed.pos = append(seed.pos, i)
+		}
+	}
+	return seed, nil
+}
+
+// String returns the seed in string of 0s and 1s.
+func (seed *SpacedSeed) String() string {
+	s := make([]byte, seed.Span)
+	for i := range s {
+		s[i] = '0' + byte(seed.Mask>>uint(i)&1)
+	}
+	return string(s)
+}
+
+// Encode encodes bases at care positions of a k-mer with the length of span.
+func (seed *SpacedSeed) Encode(mer []byte) (KmerCode, error) {
+	if len(mer) != seed.Span {
+		return KmerCode{}, ErrKMismatch
+	}
+	kmer := make([]byte, seed.Weight)
+	for i, p := range seed.pos {
+		kmer[i] = mer[p]
+	}
+	return NewKmerCode(kmer)
+}
+
+// Expand returns the k-mer with the length of span from the code of bases
+// at care positions, don't-care positions are filled with 'N'.
+func (seed *SpacedSeed) Expand(code uint64) []byte {
+	kmer := Decode(code, seed.Weight)
+	mer := make([]byte, seed.Span)
+	for i := range mer {
+		mer[i] = 'N'
+	}
+	for i, p := range seed.pos {
+		mer[p] = kmer[i]
+	}
+	return mer
+}
+
+// SpacedKmerIterator iterates spaced k-mers of a sequence, i.e., bases at
+// care positions of every window with the length of span. Windows containing
+// bases other than A/C/G/T/U are skipped, even at don't-care positions.
+// The reverse complement spaced k-mer is encoded from the reverse complement
+// of the window, and the smaller one is the canonical k-mer.
+type SpacedKmerIterator struct {
+	s         []byte
+	seed      *SpacedSeed
+	canonical bool
+
+	end int // index of the last base to add, exclusive
+	j   int // index of the next base to add
+	n   int // number of consecutive valid bases in the window
+
+	fcode uint64
+	rcode uint64
+
+	idx int
+	num int
+}
+
+// NewSpacedKmerIterator returns a SpacedKmerIterator of sequence s.
+// For circular genome, windows spanning the end and start of sequence are
+// also returned.
+func NewSpacedKmerIterator(s []byte, seed *SpacedSeed, canonical bool, circular bool) (*SpacedKmerIterator, error) {
+	if seed == nil {
+		return nil, ErrInvalidSpacedSeed
+	}
+	iter := &SpacedKmerIterator{s: s, seed: seed, canonical: canonical}
+	iter.end = len(s)
+	if circular && len(s) >= seed.Span {
+		iter.end += seed.Span - 1
+	}
+	return iter, nil
+}
+
+// Next returns the next spaced k-mer, ok is false when all k-mers are returned.
+func (iter *SpacedKmerIterator) Next() (kcode KmerCode, ok bool) {
+	l := len(iter.s)
+	span := iter.seed.Span
+	var b byte
+	var i, start, last int
+	for iter.j < iter.end {
+		if iter.j < l {
+			b = iter.s[iter.j]
+		} else {
+			b = iter.s[iter.j-l]
+		}
+		iter.j++
+
+		if nucl2bit[b] > 3 { // restart
+			iter.n = 0
+			continue
+		}
+		iter.n++
+		if iter.n < span {
+			continue
+		}
+
+		start = iter.j - span
+		last = start + span - 1
+		iter.fcode, iter.rcode = 0, 0
+		for _, p := range iter.seed.pos {
+			i = start + p
+			if i >= l {
+				i -= l
+			}
+			iter.fcode = iter.fcode<<2 | nucl2bit[iter.s[i]]
+
+			i = last - p
+			if i >= l {
+				i -= l
+			}
+			iter.rcode = iter.rcode<<2 | (nucl2bit[iter.s[i]] ^ 3)
+		}
+
+		iter.idx = start
+		iter.num++
+		if iter.canonical && iter.rcode < iter.fcode {
+			return KmerCode{iter.rcode, iter.seed.Weight}, true
+		}
+		return KmerCode{iter.fcode, iter.seed.Weight}, true
+	}
+	return KmerCode{}, false
+}
+
+// Codes returns codes of current spaced k-mer in the sequence and of the
+// reverse complement of the window.
+func (iter *SpacedKmerIterator) Codes() (fcode uint64, rcode uint64) {
+	return iter.fcode, iter.rcode
+}
+
+// Index returns the 0-based start position of current window in sequence.
+func (iter *SpacedKmerIterator) Index() int {
+	return iter.idx
+}
+
+// Skipped returns the number of windows skipped so far because of containing
+// bases other than A/C/G/T/U.
+func (iter *SpacedKmerIterator) Skipped() int {
+	return skippedWindows(iter.j, iter.seed.Span, iter.num)
+}
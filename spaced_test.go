@@ -0,0 +1,205 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// spacedKmer projects bases at care positions of a window.
+func spacedKmer(seed string, window []byte) []byte {
+	kmer := make([]byte, 0, len(seed))
+	for i := 0; i < len(seed); i++ {
+		if seed[i] == '1' {
+			kmer = append(kmer, window[i])
+		}
+	}
+	return kmer
+}
+
+// revCompSeq returns the reverse complement of a DNA sequence of A/C/G/T.
+func revCompSeq(s []byte) []byte {
+	rc := make([]byte, len(s))
+	for i, b := range s {
+		rc[len(s)-1-i] = bit2base[nucl2bit[b]^3]
+	}
+	return rc
+}
+
+func TestSpacedSeed(t *testing.T) {
+	for _, s := range []string{"", "0110", "1100", "1a1", "1" + string(bytes.Repeat([]byte("0"), 63)) + "1"} {
+		if _, err := NewSpacedSeed(s); err != ErrInvalidSpacedSeed {
+			t.Errorf("invalid seed not detected: %s", s)
+		}
+	}
+
+	seed, err := NewSpacedSeed("1101001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed.Span != 7 || seed.Weight != 4 || seed.String() != "1101001" {
+		t.Errorf("seed parsing error: %d %d %s", seed.Span, seed.Weight, seed)
+	}
+	seed2, err := NewSpacedSeedFromMask(seed.Span, seed.Mask)
+	if err != nil || seed2.String() != seed.String() {
+		t.Errorf("seed from mask error: %s", seed2)
+	}
+
+	kcode, err := seed.Encode([]byte("ACGTACG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kcode.String() != "ACTG" {
+		t.Errorf("encoding error: %s", kcode)
+	}
+	if string(seed.Expand(kcode.Code)) != "ACNTNNG" {
+		t.Errorf("expanding error: %s", seed.Expand(kcode.Code))
+	}
+	if _, err = seed.Encode([]byte("ACTG")); err != ErrKMismatch {
+		t.Errorf("length of k-mer should be checked")
+	}
+}
+
+func TestSpacedKmerIterator(t *testing.T) {
+	bases := []byte("ACGTN")
+	s := make([]byte, 2000)
+	for i := range s {
+		if rand.Intn(100) == 0 {
+			s[i] = 'N'
+		} else {
+			s[i] = bases[rand.Intn(4)]
+		}
+	}
+	s2 := append(append([]byte{}, s...), s...)
+
+	for _, seedStr := range []string{"1", "111", "11011", "1100101", "110100110010101111", "11101101110110111011011101101111"} {
+		seed, err := NewSpacedSeed(seedStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		span := seed.Span
+		for _, canonical := range []bool{false, true} {
+			for _, circular := range []bool{false, true} {
+				iter, err := NewSpacedKmerIterator(s, seed, canonical, circular)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				n := len(s) - span + 1
+				if circular {
+					n = len(s)
+				}
+				var i, nValid int
+				var kmer, rkmer []byte
+				for i = 0; i < n; i++ {
+					window := s2[i : i+span]
+					if bytes.IndexByte(window, 'N') >= 0 {
+						continue
+					}
+					nValid++
+
+					kcode, ok := iter.Next()
+					if !ok {
+						t.Fatalf("%s: missing k-mer at %d", seedStr, i)
+					}
+					if iter.Index() != i {
+						t.Fatalf("%s: index mismatch: %d vs %d", seedStr, iter.Index(), i)
+					}
+
+					kmer = spacedKmer(seedStr, window)
+					rkmer = spacedKmer(seedStr, revCompSeq(window))
+					fcode, rcode := iter.Codes()
+					if string(Decode(fcode, seed.Weight)) != string(kmer) ||
+						string(Decode(rcode, seed.Weight)) != string(rkmer) {
+						t.Fatalf("%s: k-mer mismatch at %d", seedStr, i)
+					}
+					if canonical && rcode < fcode {
+						fcode = rcode
+					}
+					if kcode.Code != fcode || kcode.K != seed.Weight {
+						t.Fatalf("%s: canonical=%v: k-mer mismatch at %d", seedStr, canonical, i)
+					}
+				}
+				if _, ok := iter.Next(); ok {
+					t.Errorf("%s: unexpected k-mer", seedStr)
+				}
+				if iter.Skipped() != n-nValid {
+					t.Errorf("%s: skipped number mismatch: %d vs %d", seedStr, iter.Skipped(), n-nValid)
+				}
+			}
+		}
+	}
+
+	if _, err := NewSpacedKmerIterator(s, nil, false, false); err != ErrInvalidSpacedSeed {
+		t.Errorf("nil seed should be checked")
+	}
+}
+
+func TestWriterSpacedSeed(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, 5, UNIK_SPACED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.SpacedSeed = "1101001"
+	if err = writer.WriteHeader(); err != ErrKMismatch {
+		t.Errorf("weight of spaced seed should be checked")
+	}
+
+	buf.Reset()
+	writer, err = NewWriter(&buf, 4, UNIK_SPACED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.SpacedSeed = "1101001"
+	if err = writer.WriteKmer([]byte("ACTG")); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Flag&UNIK_SPACED == 0 || reader.SpacedSeed != "1101001" {
+		t.Fatalf("spaced seed mismatch: %s", reader.SpacedSeed)
+	}
+	kcode, err := reader.Read()
+	if err != nil || kcode.String() != "ACTG" {
+		t.Fatalf("k-mer mismatch")
+	}
+
+	h := reader.Header
+	if !h.SameSpacedSeed(writer.Header) {
+		t.Errorf("spaced seeds should be the same")
+	}
+	h.SpacedSeed = "1011001"
+	if h.SameSpacedSeed(writer.Header) {
+		t.Errorf("different spaced seeds should be incompatible")
+	}
+	if (Header{Flag: UNIK_SPACED, SpacedSeed: "11011"}).SameSpacedSeed(Header{}) {
+		t.Errorf("spaced k-mers and contiguous k-mers should be incompatible")
+	}
+}
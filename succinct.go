@@ -0,0 +1,407 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+// ErrSuccinctUnsupported means the flag UNIK_SUCCINCT is used with K > 32,
+// or with flag UNIK_COUNT or UNIK_INDEXED.
+var ErrSuccinctUnsupported = errors.New("unikmer: succinct format only supports DNA k-mers with K <= 32, and not counted or indexed")
+
+// ErrNotSortedUnique means k-mers are not sorted in ascending order or not unique.
+var ErrNotSortedUnique = errors.New("unikmer: k-mers not sorted or not unique")
+
+// Encodings of SuccinctSet.
+const (
+	SuccinctBitmap    uint8 = iota // a bit for every possible k-mer
+	SuccinctEliasFano              // Elias–Fano encoding of sorted k-mers
+)
+
+// maxKOfSuccinctBitmap is the maximum K for bitmap, which takes 4^K bits.
+const maxKOfSuccinctBitmap = 16
+
+// Layout of file with flag UNIK_SUCCINCT:
+//
+//   header
+//   encoding (uint8), number of k-mers (uint64)
+//   for bitmap: 4^K bits in ceil(4^K/64) uint64s
+//   for Elias–Fano: number of lower bits l (uint8),
+//       lower l bits of all k-mers in ceil(n*l/64) uint64s,
+//       upper bits in unary in ceil((n + 2^(2K-l))/64) uint64s
+//
+// The encoding with smaller size is chosen by K and the number of k-mers.
+
+// SuccinctSet is a static set of sorted k-mers (K <= 32) in a bitmap or
+// Elias–Fano encoding, supporting rank and select.
+type SuccinctSet struct {
+	K        int
+	Encoding uint8
+	n        int
+
+	// bitmap, or upper bits for Elias–Fano
+	bits  []uint64
+	ranks []int // number of ones before every word of bits
+
+	// lower bits for Elias–Fano
+	l     uint
+	lower []uint64
+}
+
+// NewSuccinctSet creates a SuccinctSet from sorted and unique k-mer codes,
+// the encoding with smaller size is chosen.
+func NewSuccinctSet(k int, codes []uint64) (*SuccinctSet, error) {
+	if k <= 0 || k > 32 {
+		return nil, ErrKOverflow
+	}
+	for i := 1; i < len(codes); i++ {
+		if codes[i] <= codes[i-1] {
+			return nil, ErrNotSortedUnique
+		}
+	}
+	if len(codes) > 0 && k < 32 && codes[len(codes)-1]>>uint(k<<1) > 0 {
+		return nil, ErrCodeOverflow
+	}
+
+	s := &SuccinctSet{K: k, n: len(codes)}
+	l := efLowerBits(k, len(codes))
+	if k <= maxKOfSuccinctBitmap && uint64(1)<<uint(k<<1) < uint64(len(codes))*uint64(l+2) {
+		s.Encoding = SuccinctBitmap
+		s.bits = make([]uint64, bitmapWords(k))
+		for _, code := range codes {
+			s.bits[code>>6] |= 1 << (code & 63)
+		}
+	} else {
+		s.Encoding = SuccinctEliasFano
+		s.l = l
+		s.lower = make([]uint64, (uint64(len(codes))*uint64(l)+63)>>6)
+		s.bits = make([]uint64, efUpperWords(k, len(codes), l))
+		mask := uint64(1)<<l - 1
+		for i, code := range codes {
+			s.setLower(i, code&mask)
+			p := code>>l + uint64(i)
+			s.bits[p>>6] |= 1 << (p & 63)
+		}
+	}
+	s.buildRanks()
+	return s, nil
+}
+
+// efLowerBits returns the number of lower bits of Elias–Fano encoding,
+// i.e., floor(log2(4^k/n)).
+func efLowerBits(k int, n int) uint {
+	if n <= 1 {
+		return uint(k << 1)
+	}
+	l := k<<1 - bits.Len64(uint64(n-1))
+	if l < 0 {
+		return 0
+	}
+	return uint(l)
+}
+
+func bitmapWords(k int) int {
+	return int((uint64(1)<<uint(k<<1) + 63) >> 6)
+}
+
+func efUpperWords(k int, n int, l uint) int {
+	return int((uint64(n) + uint64(1)<<(uint(k<<1)-l) + 63) >> 6)
+}
+
+func (s *SuccinctSet) setLower(i int, v uint64) {
+	if s.l == 0 {
+		return
+	}
+	p := uint64(i) * uint64(s.l)
+	w, o := p>>6, p&63
+	s.lower[w] |= v << o
+	if o+uint64(s.l) > 64 {
+		s.lower[w+1] |= v >> (64 - o)
+	}
+}
+
+func (s *SuccinctSet) getLower(i int) uint64 {
+	if s.l == 0 {
+		return 0
+	}
+	p := uint64(i) * uint64(s.l)
+	w, o := p>>6, p&63
+	v := s.lower[w] >> o
+	if o+uint64(s.l) > 64 {
+		v |= s.lower[w+1] << (64 - o)
+	}
+	return v & (uint64(1)<<s.l - 1)
+}
+
+func (s *SuccinctSet) buildRanks() {
+	s.ranks = make([]int, len(s.bits)+1)
+	for i, w := range s.bits {
+		s.ranks[i+1] = s.ranks[i] + bits.OnesCount64(w)
+	}
+}
+
+// select1 returns the position of the i-th (0-based) one in bits.
+func (s *SuccinctSet) select1(i int) uint64 {
+	w := sort.Search(len(s.bits), func(j int) bool { return s.ranks[j+1] > i })
+	return uint64(w)<<6 + uint64(selectInWord(s.bits[w], i-s.ranks[w]))
+}
+
+// select0 returns the position of the i-th (0-based) zero in bits.
+func (s *SuccinctSet) select0(i int) uint64 {
+	w := sort.Search(len(s.bits), func(j int) bool { return (j+1)<<6-s.ranks[j+1] > i })
+	return uint64(w)<<6 + uint64(selectInWord(^s.bits[w], i-(w<<6-s.ranks[w])))
+}
+
+// selectInWord returns the position of the i-th (0-based) one in a word.
+func selectInWord(w uint64, i int) int {
+	for ; i > 0; i-- {
+		w &= w - 1
+	}
+	return bits.TrailingZeros64(w)
+}
+
+// Len returns the number of k-mers.
+func (s *SuccinctSet) Len() int {
+	return s.n
+}
+
+// Select returns the i-th (0-based) smallest k-mer code.
+func (s *SuccinctSet) Select(i int) uint64 {
+	if i < 0 || i >= s.n {
+		panic("unikmer: index out of range")
+	}
+	if s.Encoding == SuccinctBitmap {
+		return s.select1(i)
+	}
+	return (s.select1(i)-uint64(i))<<s.l | s.getLower(i)
+}
+
+// Rank returns the number of k-mers smaller than code.
+func (s *SuccinctSet) Rank(code uint64) int {
+	if s.Encoding == SuccinctBitmap {
+		if code >= uint64(len(s.bits))<<6 {
+			return s.n
+		}
+		w := code >> 6
+		return s.ranks[w] + bits.OnesCount64(s.bits[w]&(1<<(code&63)-1))
+	}
+
+	high := code >> s.l
+	if high >= uint64(len(s.bits))<<6-uint64(s.n) { // larger than all
+		return s.n
+	}
+	// k-mers with smaller upper bits
+	var i int
+	if high > 0 {
+		i = int(s.select0(int(high-1)) - (high - 1))
+	}
+	// k-mers with the same upper bits
+	for ; i < s.n && s.Select(i) < code; i++ {
+	}
+	return i
+}
+
+// Contains checks whether a k-mer exists.
+func (s *SuccinctSet) Contains(code uint64) bool {
+	if s.Encoding == SuccinctBitmap {
+		return code < uint64(len(s.bits))<<6 && s.bits[code>>6]&(1<<(code&63)) > 0
+	}
+	i := s.Rank(code)
+	return i < s.n && s.Select(i) == code
+}
+
+// Codes returns all k-mer codes in ascending order.
+func (s *SuccinctSet) Codes() []uint64 {
+	codes := make([]uint64, 0, s.n)
+	iter := s.iterator()
+	for {
+		code, ok := iter.next()
+		if !ok {
+			break
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// succinctIterator iterates k-mers of SuccinctSet sequentially.
+type succinctIterator struct {
+	s    *SuccinctSet
+	i    int // index of next k-mer
+	w    int // index of current word
+	word uint64
+}
+
+func (s *SuccinctSet) iterator() *succinctIterator {
+	iter := &succinctIterator{s: s}
+	if len(s.bits) > 0 {
+		iter.word = s.bits[0]
+	}
+	return iter
+}
+
+func (iter *succinctIterator) next() (uint64, bool) {
+	s := iter.s
+	if iter.i >= s.n {
+		return 0, false
+	}
+	for iter.word == 0 {
+		iter.w++
+		iter.word = s.bits[iter.w]
+	}
+	p := uint64(iter.w)<<6 + uint64(bits.TrailingZeros64(iter.word))
+	iter.word &= iter.word - 1
+	i := iter.i
+	iter.i++
+	if s.Encoding == SuccinctBitmap {
+		return p, true
+	}
+	return (p-uint64(i))<<s.l | s.getLower(i), true
+}
+
+// writeTo writes the encoding, number of k-mers and the data.
+func (s *SuccinctSet) writeTo(w io.Writer) (err error) {
+	if err = binary.Write(w, be, s.Encoding); err != nil {
+		return err
+	}
+	if err = binary.Write(w, be, uint64(s.n)); err != nil {
+		return err
+	}
+	if s.Encoding == SuccinctEliasFano {
+		if err = binary.Write(w, be, uint8(s.l)); err != nil {
+			return err
+		}
+		if err = binary.Write(w, be, s.lower); err != nil {
+			return err
+		}
+	}
+	return binary.Write(w, be, s.bits)
+}
+
+// SuccinctSet returns all k-mers of file with flag UNIK_SUCCINCT,
+// which supports rank and select.
+func (reader *Reader) SuccinctSet() (*SuccinctSet, error) {
+	if reader.ss != nil {
+		return reader.ss, nil
+	}
+	if !reader.succinct {
+		return nil, ErrInvalidFileFormat
+	}
+	s, err := readSuccinctSet(reader.r, reader.K)
+	if err != nil {
+		return nil, err
+	}
+	reader.ss = s
+	reader.ssIter = s.iterator()
+	return s, nil
+}
+
+// maxSuccinctN is the maximum number of k-mers in a SuccinctSet, so sizes of
+// arrays computed from it do not overflow.
+const maxSuccinctN = 1 << 56
+
+// readWords reads n uint64 words. Memory is allocated along with reading,
+// so a corrupted n leads to ErrBrokenFile instead of a huge allocation.
+func readWords(r io.Reader, n uint64) ([]uint64, error) {
+	const chunk = 1 << 16
+	size := n
+	if size > chunk {
+		size = chunk
+	}
+	words := make([]uint64, 0, size)
+	buf := make([]uint64, size)
+	for uint64(len(words)) < n {
+		if size = n - uint64(len(words)); size > chunk {
+			size = chunk
+		}
+		if err := binary.Read(r, be, buf[:size]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, ErrBrokenFile
+			}
+			return nil, err
+		}
+		words = append(words, buf[:size]...)
+	}
+	return words, nil
+}
+
+// readSuccinctSet reads SuccinctSet written by writeTo.
+func readSuccinctSet(r io.Reader, k int) (*SuccinctSet, error) {
+	var encoding uint8
+	var n uint64
+	if err := binary.Read(r, be, &encoding); err != nil {
+		if err == io.EOF {
+			return nil, ErrBrokenFile
+		}
+		return nil, err
+	}
+	if err := binary.Read(r, be, &n); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBrokenFile
+		}
+		return nil, err
+	}
+	if k < 32 && n > uint64(1)<<uint(k<<1) {
+		return nil, ErrInvalidFileFormat
+	}
+	if n > maxSuccinctN {
+		return nil, ErrBrokenFile
+	}
+
+	var err error
+	s := &SuccinctSet{K: k, Encoding: encoding, n: int(n)}
+	switch encoding {
+	case SuccinctBitmap:
+		if k > maxKOfSuccinctBitmap {
+			return nil, ErrInvalidFileFormat
+		}
+		s.bits, err = readWords(r, uint64(bitmapWords(k)))
+	case SuccinctEliasFano:
+		var l uint8
+		if err = binary.Read(r, be, &l); err != nil {
+			return nil, err
+		}
+		if int(l) > k<<1 || l != uint8(efLowerBits(k, s.n)) {
+			return nil, ErrInvalidFileFormat
+		}
+		s.l = uint(l)
+		s.lower, err = readWords(r, (n*uint64(l)+63)>>6)
+		if err != nil {
+			return nil, err
+		}
+		s.bits, err = readWords(r, uint64(efUpperWords(k, s.n, s.l)))
+	default:
+		return nil, ErrInvalidFileFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.buildRanks()
+	if s.ranks[len(s.bits)] != s.n {
+		return nil, ErrBrokenFile
+	}
+	return s, nil
+}
@@ -0,0 +1,191 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+)
+
+// genUniqueCodes generates n unique k-mer codes in ascending order.
+func genUniqueCodes(k int, n int) []uint64 {
+	max := uint64(1)<<uint(k<<1) - 1
+	if k == 32 {
+		max = ^uint64(0)
+	}
+	m := make(map[uint64]struct{}, n)
+	for len(m) < n && uint64(len(m)) <= max {
+		m[rand.Uint64()&max] = struct{}{}
+	}
+	codes := make([]uint64, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+	sort.Sort(CodeSlice(codes))
+	return codes
+}
+
+func TestSuccinctSet(t *testing.T) {
+	for _, k := range []int{1, 3, 5, 8, 11, 15, 21, 31, 32} {
+		for _, n := range []int{0, 1, 2, 100, 10000} {
+			codes := genUniqueCodes(k, n)
+			s, err := NewSuccinctSet(k, codes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Len() != len(codes) {
+				t.Fatalf("k=%d n=%d: number mismatch: %d vs %d", k, n, s.Len(), len(codes))
+			}
+			if k <= 5 && n == 10000 && s.Encoding != SuccinctBitmap {
+				t.Errorf("k=%d n=%d: bitmap expected for dense set", k, n)
+			}
+			if k >= 21 && s.Encoding != SuccinctEliasFano {
+				t.Errorf("k=%d n=%d: Elias–Fano expected for sparse set", k, n)
+			}
+
+			all := s.Codes()
+			for i, code := range codes {
+				if s.Select(i) != code || all[i] != code {
+					t.Fatalf("k=%d n=%d: select mismatch at %d", k, n, i)
+				}
+				if s.Rank(code) != i {
+					t.Fatalf("k=%d n=%d: rank mismatch at %d: %d", k, n, i, s.Rank(code))
+				}
+				if !s.Contains(code) {
+					t.Fatalf("k=%d n=%d: k-mer missing: %d", k, n, code)
+				}
+			}
+			for i := 0; i < 1000; i++ {
+				code := genUniqueCodes(k, 1)[0]
+				j := sort.Search(len(codes), func(j int) bool { return codes[j] >= code })
+				if s.Rank(code) != j {
+					t.Fatalf("k=%d n=%d: rank mismatch of %d: %d vs %d", k, n, code, s.Rank(code), j)
+				}
+				if s.Contains(code) != (j < len(codes) && codes[j] == code) {
+					t.Fatalf("k=%d n=%d: contains mismatch of %d", k, n, code)
+				}
+			}
+		}
+	}
+
+	if _, err := NewSuccinctSet(21, []uint64{2, 1}); err != ErrNotSortedUnique {
+		t.Errorf("unsorted k-mers not detected")
+	}
+}
+
+func TestWriterSuccinct(t *testing.T) {
+	var file string
+
+	var mers, mers2 [][]byte
+	var err error
+
+	for _, k := range []int{1, 5, 9, 13, 17, 23, 31} {
+		for _, n := range []int{10, 10001} {
+			func() {
+				mers = genKmers(k, n, true)
+				mers = uniqKmers(mers)
+
+				file = fmt.Sprintf("t.k%d.unik", k)
+
+				err = write(mers, file, UNIK_SUCCINCT)
+				if err != nil {
+					t.Error(err)
+				}
+				defer func() {
+					err = os.Remove(file)
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+
+				mers2, err = read(file)
+				if err != nil {
+					t.Error(err)
+				}
+
+				if len(mers2) != len(mers) {
+					t.Errorf("write and read: number err")
+				}
+				for i := 0; i < len(mers); i++ {
+					if !bytes.Equal(mers[i], mers2[i]) {
+						t.Errorf("write and read: data mismatch. %d: %s vs %s", i, mers[i], mers2[i])
+					}
+				}
+			}()
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err = NewWriter(&buf, 41, UNIK_SUCCINCT); err != ErrSuccinctUnsupported {
+		t.Errorf("K > 32 not refused")
+	}
+	writer, err := NewWriter(&buf, 21, UNIK_SUCCINCT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kcode, _ := NewKmerCode([]byte("ACGTACGTACGTACGTACGTA"))
+	writer.Write(kcode)
+	if err = writer.Write(kcode); err != ErrNotSortedUnique {
+		t.Errorf("duplicated k-mers not detected")
+	}
+}
+
+func TestReadSuccinctSetCorrupted(t *testing.T) {
+	data := func(encoding uint8, n uint64, l uint8) []byte {
+		var buf bytes.Buffer
+		buf.WriteByte(encoding)
+		binary.Write(&buf, be, n)
+		if encoding == SuccinctEliasFano {
+			buf.WriteByte(l)
+		}
+		buf.Write(make([]byte, 64))
+		return buf.Bytes()
+	}
+
+	for _, n := range []uint64{1 << 40, 1 << 62, ^uint64(0)} {
+		_, err := readSuccinctSet(bytes.NewReader(data(SuccinctEliasFano, n, uint8(efLowerBits(32, int(n))))), 32)
+		if err != ErrBrokenFile {
+			t.Errorf("n=%d: corrupted data not detected: %v", n, err)
+		}
+	}
+	if _, err := readSuccinctSet(bytes.NewReader(data(SuccinctBitmap, 10, 0)), 9); err != ErrBrokenFile {
+		t.Errorf("truncated bitmap not detected: %v", err)
+	}
+}
+
+func uniqKmers(mers [][]byte) [][]byte {
+	if len(mers) == 0 {
+		return mers
+	}
+	j := 0
+	for i := 1; i < len(mers); i++ {
+		if !bytes.Equal(mers[i], mers[j]) {
+			j++
+			mers[j] = mers[i]
+		}
+	}
+	return mers[:j+1]
+}
@@ -0,0 +1,126 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import "errors"
+
+// ErrInvalidSyncmer means invalid syncmer parameters.
+var ErrInvalidSyncmer = errors.New("unikmer: invalid syncmer parameters, s should be in range [1, K], and offset in range [0, K-s] for open syncmers")
+
+// Types of syncmers.
+const (
+	SyncmerClosed uint8 = iota // the smallest s-mer is at the start or end of the k-mer
+	SyncmerOpen                // the smallest s-mer is at the given offset of the k-mer
+)
+
+// SyncmerTypeName returns the name of a syncmer type.
+func SyncmerTypeName(t uint8) string {
+	switch t {
+	case SyncmerClosed:
+		return "closed"
+	case SyncmerOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Syncmer decides whether a k-mer (k <= 32) is a syncmer, according to
+// the position of its smallest s-mer, where s-mers are compared by hash values
+// and the leftmost one is chosen for ties. Unlike minimizers, the decision
+// only depends on the k-mer itself, so syncmers are conserved as long as the
+// k-mers are not mutated.
+type Syncmer struct {
+	K      int
+	S      int
+	Type   uint8 // SyncmerClosed or SyncmerOpen
+	Offset int   // offset of the smallest s-mer for open syncmers
+
+	mask uint64
+}
+
+// NewSyncmer creates a Syncmer. The offset is ignored for closed syncmers.
+func NewSyncmer(k int, s int, t uint8, offset int) (*Syncmer, error) {
+	if k <= 0 || k > 32 {
+		return nil, ErrKOverflow
+	}
+	if s <= 0 || s > k || t > SyncmerOpen || (t == SyncmerOpen && (offset < 0 || offset > k-s)) {
+		return nil, ErrInvalidSyncmer
+	}
+	if t == SyncmerClosed {
+		offset = 0
+	}
+	return &Syncmer{K: k, S: s, Type: t, Offset: offset, mask: MaxCode[s]}, nil
+}
+
+// Is checks whether the k-mer is a syncmer.
+func (sm *Syncmer) Is(code uint64) bool {
+	n := sm.K - sm.S // position of the last s-mer
+	var p int
+	var h, min uint64
+	for i := 0; i <= n; i++ {
+		h = Hash64(code >> uint((n-i)<<1) & sm.mask)
+		if i == 0 || h < min {
+			min, p = h, i
+		}
+	}
+	if sm.Type == SyncmerClosed {
+		return p == 0 || p == n
+	}
+	return p == sm.Offset
+}
+
+// SyncmerIterator iterates syncmers (k <= 32) of a sequence, the rules of
+// k-mers are the same with KmerIterator. For canonical k-mers, the canonical
+// k-mer is checked, so the same syncmers are returned for both strands.
+type SyncmerIterator struct {
+	iter *KmerIterator
+	sm   *Syncmer
+}
+
+// NewSyncmerIterator returns a SyncmerIterator of sequence s.
+func NewSyncmerIterator(s []byte, sm *Syncmer, canonical bool, circular bool) (*SyncmerIterator, error) {
+	iter, err := NewKmerIterator(s, sm.K, canonical, circular)
+	if err != nil {
+		return nil, err
+	}
+	return &SyncmerIterator{iter: iter, sm: sm}, nil
+}
+
+// Next returns the next syncmer, ok is false when all syncmers are returned.
+func (iter *SyncmerIterator) Next() (kcode KmerCode, ok bool) {
+	for {
+		kcode, ok = iter.iter.Next()
+		if !ok || iter.sm.Is(kcode.Code) {
+			return kcode, ok
+		}
+	}
+}
+
+// Index returns the 0-based start position of current syncmer in sequence.
+func (iter *SyncmerIterator) Index() int {
+	return iter.iter.Index()
+}
+
+// Skipped returns the number of k-mers skipped so far because of containing
+// bases other than A/C/G/T/U.
+func (iter *SyncmerIterator) Skipped() int {
+	return iter.iter.Skipped()
+}
@@ -0,0 +1,266 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// readTestGenome reads at most n bases of the first sequence in a gzipped FASTA file.
+func readTestGenome(t *testing.T, file string, n int) []byte {
+	fh, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	gr, err := gzip.NewReader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gr.Close()
+
+	s := make([]byte, 0, n)
+	scanner := bufio.NewScanner(gr)
+	var started bool
+	for scanner.Scan() && len(s) < n {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) > 0 && line[0] == '>' {
+			if started {
+				break
+			}
+			started = true
+			continue
+		}
+		s = append(s, line...)
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s) > n {
+		s = s[:n]
+	}
+	return s
+}
+
+func TestSyncmer(t *testing.T) {
+	for _, k := range []int{5, 15, 21, 31, 32} {
+		for _, s := range []int{1, 3, 11, k} {
+			if s > k {
+				continue
+			}
+			for _, typ := range []uint8{SyncmerClosed, SyncmerOpen} {
+				offset := (k - s) / 2
+				sm, err := NewSyncmer(k, s, typ, offset)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 1000; i++ {
+					mer := randomSeq(k, false)
+					kcode, _ := NewKmerCode(mer)
+
+					// the slow way
+					p := 0
+					var min uint64
+					for j := 0; j+s <= k; j++ {
+						scode, _ := NewKmerCode(mer[j : j+s])
+						if h := Hash64(scode.Code); j == 0 || h < min {
+							min, p = h, j
+						}
+					}
+					var is bool
+					if typ == SyncmerClosed {
+						is = p == 0 || p == k-s
+					} else {
+						is = p == offset
+					}
+					if sm.Is(kcode.Code) != is {
+						t.Fatalf("k=%d s=%d type=%d: wrong result for %s", k, s, typ, mer)
+					}
+				}
+			}
+		}
+	}
+
+	for _, params := range [][3]int{{21, 0, 0}, {21, 22, 0}, {21, 11, 11}, {21, 11, -1}, {33, 11, 0}} {
+		if _, err := NewSyncmer(params[0], params[1], SyncmerOpen, params[2]); err == nil {
+			t.Errorf("invalid parameters should not be allowed: %v", params)
+		}
+	}
+}
+
+// syncmerPositions returns positions of syncmers or minimizers of a sequence.
+func syncmerPositions(t *testing.T, s []byte, k int, sm *Syncmer, w int) map[int]struct{} {
+	type iterator interface {
+		Next() (KmerCode, bool)
+		Index() int
+	}
+	var iter iterator
+	var err error
+	if sm != nil {
+		iter, err = NewSyncmerIterator(s, sm, true, false)
+	} else {
+		iter, err = NewMinimizerIterator(s, k, w, MinimizerHashed, true, false)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[int]struct{}, len(s)/4)
+	for {
+		if _, ok := iter.Next(); !ok {
+			break
+		}
+		m[iter.Index()] = struct{}{}
+	}
+	return m
+}
+
+func TestSyncmerDensity(t *testing.T) {
+	s := readTestGenome(t, "testdata/Ecoli-MG1655.fasta.gz", 1000000)
+	if len(s) < 100000 {
+		t.Fatalf("too short test genome: %d", len(s))
+	}
+	n := float64(len(s) - 21 + 1)
+	for _, typ := range []uint8{SyncmerClosed, SyncmerOpen} {
+		for _, s0 := range []int{9, 11, 15} {
+			sm, _ := NewSyncmer(21, s0, typ, (21-s0)/2)
+			density := float64(len(syncmerPositions(t, s, 21, sm, 0))) / n
+			expected := 1 / float64(21-s0+1)
+			if typ == SyncmerClosed {
+				expected *= 2
+			}
+			if math.Abs(density-expected)/expected > 0.1 {
+				t.Errorf("type=%d s=%d: density %.4f deviates from the expected %.4f", typ, s0, density, expected)
+			}
+		}
+	}
+}
+
+func TestSyncmerConservation(t *testing.T) {
+	s := readTestGenome(t, "testdata/Ecoli-MG1655.fasta.gz", 1000000)
+	k, s0 := 21, 11
+
+	// mutate 1% bases
+	s2 := make([]byte, len(s))
+	copy(s2, s)
+	mutated := make([]bool, len(s))
+	r := rand.New(rand.NewSource(11))
+	for i := 0; i < len(s)/100; i++ {
+		p := r.Intn(len(s))
+		s2[p] = "ACGT"[(nucl2bit[s2[p]]+uint64(r.Intn(3))+1)&3]
+		mutated[p] = true
+	}
+	intact := func(p int) bool {
+		for i := p; i < p+k; i++ {
+			if mutated[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	sm, _ := NewSyncmer(k, s0, SyncmerClosed, 0)
+	// minimizers with the same density (2/(w+1)) as closed syncmers
+	w := k - s0
+
+	for _, name := range []string{"syncmer", "minimizer"} {
+		sm1 := sm
+		if name == "minimizer" {
+			sm1 = nil
+		}
+		pos := syncmerPositions(t, s, k, sm1, w)
+		pos2 := syncmerPositions(t, s2, k, sm1, w)
+
+		var nIntact, nConserved int
+		for p := range pos {
+			if !intact(p) {
+				continue
+			}
+			nIntact++
+			if _, ok := pos2[p]; ok {
+				nConserved++
+			}
+		}
+		frac := float64(nConserved) / float64(nIntact)
+		t.Logf("%s: %d of %d selected k-mers not mutated are conserved (%.4f)", name, nConserved, nIntact, frac)
+
+		if name == "syncmer" && nConserved != nIntact {
+			t.Errorf("syncmers of k-mers not mutated should all be conserved: %d vs %d", nConserved, nIntact)
+		}
+		if name == "minimizer" && nConserved == nIntact {
+			t.Errorf("minimizers are expected to be less conserved than syncmers")
+		}
+	}
+}
+
+func TestWriterSyncmer(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, 21, UNIK_SYNCMER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.SyncmerType = SyncmerOpen
+	writer.SyncmerS = 11
+	writer.SyncmerOffset = 11
+	if err = writer.WriteHeader(); err != ErrInvalidSyncmer {
+		t.Errorf("syncmer parameters should be checked")
+	}
+
+	buf.Reset()
+	writer, err = NewWriter(&buf, 21, UNIK_SYNCMER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.SyncmerType = SyncmerOpen
+	writer.SyncmerS = 11
+	writer.SyncmerOffset = 5
+	if err = writer.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.SyncmerType != SyncmerOpen || reader.SyncmerS != 11 || reader.SyncmerOffset != 5 {
+		t.Fatalf("syncmer parameters mismatch")
+	}
+
+	h := reader.Header
+	if !h.SameSampling(writer.Header) {
+		t.Errorf("sampling should be the same")
+	}
+	h.SyncmerOffset = 4
+	if h.SameSampling(writer.Header) {
+		t.Errorf("different syncmer offsets should be incompatible")
+	}
+	if (Header{Flag: UNIK_MINIMIZER, MinimizerWindow: 10}).SameSampling(Header{}) {
+		t.Errorf("minimizers and all k-mers should be incompatible")
+	}
+}
@@ -1,7 +1,7 @@
 #!/bin/sh
 f=$1;
 
-echo -e "K\tplain\tplain.gz\t.unik\t.unik.cpt\t.unik.sort\t.unik.ungz\t.unik.cpt.ungz\tplain.gz(%)\t.unik(%)\t.unik.cpt(%)\t.unik.sort(%)\t.unik.ungz(%)\t.unik.cpt.ungz(%)"
+echo -e "K\tplain\tplain.gz\t.unik\t.unik.cpt\t.unik.sort\t.unik.ungz\t.unik.cpt.ungz\t.unik.zst\t.unik.sort.zst\tplain.gz(%)\t.unik(%)\t.unik.cpt(%)\t.unik.sort(%)\t.unik.ungz(%)\t.unik.cpt.ungz(%)\t.unik.zst(%)\t.unik.sort.zst(%)"
 seq 1 2 32 \
     | rush -v f=$f -k ' \
         unikmer count -K -k {} {f} -o {f}.k{}; \
@@ -9,6 +9,8 @@ seq 1 2 32 \
         unikmer count -K -k {} {f} -o {f}.k{}.s -c -s; \
         unikmer count -K -k {} {f} -o {f}.k{}.C -C; \
         unikmer count -K -k {} {f} -o {f}.k{}.cC -c -C; \
+        unikmer count -K -k {} {f} -o {f}.k{}.z --codec zstd; \
+        unikmer count -K -k {} {f} -o {f}.k{}.sz -c -s --codec zstd; \
         unikmer view {f}.k{}.unik > {f}.k{}.unik.plain; \
         gzip -c -k {f}.k{}.unik.plain > {f}.k{}.unik.plain.gz; \
           \
@@ -19,7 +21,9 @@ seq 1 2 32 \
         s=$(ls -l {f}.k{}.s.unik | cut -d " " -f 5); \
         C=$(ls -l {f}.k{}.C.unik | cut -d " " -f 5); \
         cC=$(ls -l {f}.k{}.cC.unik | cut -d " " -f 5); \
-        echo -e "{}\t$p\t$gp\t$o\t$c\t$s\t$C\t$cC" \
+        z=$(ls -l {f}.k{}.z.unik | cut -d " " -f 5); \
+        sz=$(ls -l {f}.k{}.sz.unik | cut -d " " -f 5); \
+        echo -e "{}\t$p\t$gp\t$o\t$c\t$s\t$C\t$cC\t$z\t$sz" \
         ' \
     | csvtk -H -t mutate2 -L 1 -e '$3/$2*100' \
     | csvtk -H -t mutate2 -L 1 -e '$4/$2*100' \
@@ -27,5 +31,7 @@ seq 1 2 32 \
     | csvtk -H -t mutate2 -L 1 -e '$6/$2*100' \
     | csvtk -H -t mutate2 -L 1 -e '$7/$2*100' \
     | csvtk -H -t mutate2 -L 1 -e '$8/$2*100' \
+    | csvtk -H -t mutate2 -L 1 -e '$9/$2*100' \
+    | csvtk -H -t mutate2 -L 1 -e '$10/$2*100' \
 
 ls $f.k* | rush '/bin/rm {}'
@@ -0,0 +1,134 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrInvalidGeneticCode means the genetic code table is not supported.
+var ErrInvalidGeneticCode = errors.New("unikmer: invalid genetic code table")
+
+// ErrInvalidFrame means the frame is not one of 1, 2, 3, -1, -2 and -3.
+var ErrInvalidFrame = errors.New("unikmer: invalid frame, available: 1, 2, 3, -1, -2, -3")
+
+// Frames are the six frames for translation, negative values are frames
+// of the reverse complement sequence.
+var Frames = []int{1, 2, 3, -1, -2, -3}
+
+// geneticCodes are amino acids of codons in the order of TTT, TTC, TTA,
+// TTG, TCT, ..., GGG, as the genetic code tables of NCBI, '*' for stop codons.
+var geneticCodes = map[int]string{
+	1:  "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // standard
+	2:  "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSS**VVVVAAAADDEEGGGG", // vertebrate mitochondrial
+	3:  "FFLLSSSSYY**CCWWTTTTPPPPHHQQRRRRIIMMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // yeast mitochondrial
+	4:  "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // mold, protozoan, mycoplasma
+	5:  "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSSSVVVVAAAADDEEGGGG", // invertebrate mitochondrial
+	6:  "FFLLSSSSYYQQCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // ciliate, dasycladacean, hexamita nuclear
+	9:  "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNNKSSSSVVVVAAAADDEEGGGG", // echinoderm, flatworm mitochondrial
+	10: "FFLLSSSSYY**CCCWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // euplotid nuclear
+	11: "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // bacterial, archaeal and plant plastid
+	12: "FFLLSSSSYY**CC*WLLLSPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // alternative yeast nuclear
+	13: "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSGGVVVVAAAADDEEGGGG", // ascidian mitochondrial
+	14: "FFLLSSSSYYY*CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNNKSSSSVVVVAAAADDEEGGGG", // alternative flatworm mitochondrial
+	16: "FFLLSSSSYY*LCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // chlorophycean mitochondrial
+	21: "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNNKSSSSVVVVAAAADDEEGGGG", // trematode mitochondrial
+	22: "FFLLSS*SYY*LCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // scenedesmus obliquus mitochondrial
+	23: "FF*LSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // thraustochytrium mitochondrial
+	24: "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSSKVVVVAAAADDEEGGGG", // rhabdopleuridae mitochondrial
+	25: "FFLLSSSSYY**CCGWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // candidate division SR1 and gracilibacteria
+	26: "FFLLSSSSYY**CC*WLLLAPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", // pachysolen tannophilus nuclear
+}
+
+// GeneticCodeIDs returns IDs of supported genetic code tables in ascending order.
+func GeneticCodeIDs() []int {
+	ids := make([]int, 0, len(geneticCodes))
+	for id := range geneticCodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// GeneticCode is a genetic code table for translating codons to amino acids.
+type GeneticCode struct {
+	ID int
+
+	aa [64]byte // amino acids of codons in 2-bit codes of A/C/G/T
+}
+
+// NewGeneticCode returns the genetic code table of an NCBI ID, e.g., 1 for the
+// standard code and 11 for bacteria.
+func NewGeneticCode(id int) (*GeneticCode, error) {
+	table, ok := geneticCodes[id]
+	if !ok {
+		return nil, ErrInvalidGeneticCode
+	}
+	gc := &GeneticCode{ID: id}
+	tcag := [4]int{2, 1, 3, 0} // positions of A, C, G, T in the order of TCAG
+	for i := 0; i < 64; i++ {
+		gc.aa[i] = table[tcag[i>>4]<<4|tcag[i>>2&3]<<2|tcag[i&3]]
+	}
+	return gc, nil
+}
+
+// codon returns the amino acid of three 2-bit codes of bases,
+// 'X' for codons containing bases other than A/C/G/T/U.
+func (gc *GeneticCode) codon(a, b, c uint64) byte {
+	if a > 3 || b > 3 || c > 3 {
+		return 'X'
+	}
+	return gc.aa[a<<4|b<<2|c]
+}
+
+// Translate translates a DNA sequence in a frame, i.e., 1, 2, 3 for the
+// sequence starting from the first, second and third base, and -1, -2, -3 for
+// the reverse complement sequence. Stop codons are translated to '*', and
+// codons containing bases other than A/C/G/T/U are translated to 'X'.
+// Incomplete codons at the end are ignored.
+func (gc *GeneticCode) Translate(s []byte, frame int) ([]byte, error) {
+	if frame == 0 || frame > 3 || frame < -3 {
+		return nil, ErrInvalidFrame
+	}
+	var n int
+	if frame > 0 {
+		n = (len(s) - frame + 1) / 3
+	} else {
+		n = (len(s) + frame + 1) / 3
+	}
+	if n <= 0 {
+		return []byte{}, nil
+	}
+
+	p := make([]byte, n)
+	if frame > 0 {
+		for i, j := frame-1, 0; j < n; i, j = i+3, j+1 {
+			p[j] = gc.codon(nucl2bit[s[i]], nucl2bit[s[i+1]], nucl2bit[s[i+2]])
+		}
+		return p, nil
+	}
+	// codons of the reverse complement sequence, ending at i
+	for i, j := len(s)+frame, 0; j < n; i, j = i-3, j+1 {
+		p[j] = gc.codon(nucl2bit[s[i]]^3, nucl2bit[s[i-1]]^3, nucl2bit[s[i-2]]^3)
+	}
+	return p, nil
+}
@@ -0,0 +1,91 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	gc, err := NewGeneticCode(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := []byte("ATGGCCTAA")
+	expected := map[int]string{1: "MA*", 2: "WP", 3: "GL", -1: "LGH", -2: "*A", -3: "RP"}
+	for _, frame := range Frames {
+		p, err := gc.Translate(s, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != expected[frame] {
+			t.Errorf("frame %d: %s vs %s", frame, p, expected[frame])
+		}
+	}
+	for _, frame := range []int{0, 4, -4} {
+		if _, err = gc.Translate(s, frame); err != ErrInvalidFrame {
+			t.Errorf("frame %d should be invalid", frame)
+		}
+	}
+	if p, _ := gc.Translate([]byte("ATGNCCuaa"), 1); string(p) != "MX*" {
+		t.Errorf("translating degenerate bases error: %s", p)
+	}
+	if p, _ := gc.Translate([]byte("AT"), -1); len(p) != 0 {
+		t.Errorf("translating short sequence error: %s", p)
+	}
+
+	// alternative genetic codes
+	for id, expected := range map[int]string{2: "*P", 4: "RP", 13: "GP"} {
+		gc2, err := NewGeneticCode(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, _ := gc2.Translate(s, -3); string(p) != expected {
+			t.Errorf("table %d: %s vs %s", id, p, expected)
+		}
+	}
+	gc4, _ := NewGeneticCode(4)
+	if p, _ := gc4.Translate([]byte("TGA"), 1); string(p) != "W" {
+		t.Errorf("table 4: TGA should be translated to W")
+	}
+	if _, err = NewGeneticCode(7); err != ErrInvalidGeneticCode {
+		t.Errorf("invalid genetic code should be detected")
+	}
+	if ids := GeneticCodeIDs(); ids[0] != 1 || len(ids) != len(geneticCodes) {
+		t.Errorf("genetic code IDs error: %v", ids)
+	}
+
+	// frames of reverse complement sequence
+	s = make([]byte, 1000)
+	for i := range s {
+		s[i] = bit2base[rand.Intn(4)]
+	}
+	rc := revCompSeq(s)
+	for _, frame := range []int{1, 2, 3} {
+		p, _ := gc.Translate(s, -frame)
+		p2, _ := gc.Translate(rc, frame)
+		if !bytes.Equal(p, p2) {
+			t.Errorf("frame %d: translation of reverse complement sequence mismatch", -frame)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// bloomCmd represents
+var bloomCmd = &cobra.Command{
+	Use:   "bloom",
+	Short: "build Bloom filter from binary files",
+	Long: `build Bloom filter from binary files
+
+The Bloom filter is saved with the same header as binary files,
+with extension of ".bloom", and it can be queried with "unikmer grep".
+
+Attentions:
+  1. The 'canonical' flags of all files should be consistent.
+  2. The number of k-mers is needed to compute the filter size. It's read
+     from the header of sorted files, or counted by reading files in advance,
+     which is not possible for stdin. So please give -n/--num for stdin.
+  3. The Bloom filter file is not compressed, for its bits are random.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		var files []string
+		infileList := getFlagString(cmd, "infile-list")
+		if infileList != "" {
+			files, err = getListFromFile(infileList)
+			checkError(err)
+		} else {
+			files = getFileList(args)
+		}
+
+		checkFiles(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-prefix")
+		fpr := getFlagPositiveFloat64(cmd, "false-positive-rate")
+		if fpr >= 1 {
+			checkError(fmt.Errorf("value of -p/--false-positive-rate should be in range (0, 1)"))
+		}
+		num := getFlagNonNegativeInt(cmd, "num")
+
+		// number of k-mers
+		var n int64
+		if num > 0 {
+			n = int64(num)
+		} else {
+			if opt.Verbose {
+				log.Infof("counting k-mers")
+			}
+			for _, file := range files {
+				if isStdin(file) {
+					checkError(fmt.Errorf("please give the number of k-mers (-n/--num) for stdin"))
+				}
+				n += numKmersInFile(file)
+			}
+			if opt.Verbose {
+				log.Infof("%d k-mers found", n)
+			}
+		}
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var kcode unikmer.KmerCode
+		var kcode2 unikmer.KmerCode2
+		var bf *unikmer.BloomFilter
+		var k int = -1
+		var canonical bool
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+			}
+
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if k == -1 {
+					k = reader.K
+					canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+
+					var mode uint32
+					if canonical {
+						mode |= unikmer.UNIK_CANONICAL
+					}
+					bf, err = unikmer.NewBloomFilter(k, mode, n, fpr)
+					checkError(err)
+					setKmerScheme(&bf.Header, reader.Header)
+				} else if k != reader.K {
+					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
+					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				} else {
+					checkKmerScheme(file, reader.Header, bf.Header)
+				}
+
+				for {
+					if k > 32 {
+						kcode2, err = reader.Read2()
+					} else {
+						kcode, err = reader.Read()
+					}
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+
+					if k > 32 {
+						bf.Add2(kcode2)
+					} else {
+						bf.Add(kcode)
+					}
+				}
+			}()
+		}
+
+		if !isStdout(outFile) {
+			outFile += extBloomFile
+		}
+		outfh, gw, w, err := outStream(outFile, false, opt.CompressionLevel, opt.Codec)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		_, err = bf.WriteTo(outfh)
+		checkError(err)
+
+		if opt.Verbose {
+			log.Infof("%d k-mers added, %d bits and %d hash functions used, expected false positive rate: %g",
+				bf.Number, bf.NumBits, bf.NumHashes, bf.FPR())
+		}
+	},
+}
+
+// numKmersInFile returns the number of k-mers in a binary file, from the header
+// of sorted file or by reading the file.
+func numKmersInFile(file string) int64 {
+	infh, r, _, err := inStream(file)
+	checkError(err)
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	checkError(err)
+
+	if reader.Flag&unikmer.UNIK_SORTED > 0 && reader.Number >= 0 {
+		return reader.Number
+	}
+
+	var n int64
+	for {
+		if reader.K > 32 {
+			_, err = reader.Read2()
+		} else {
+			_, err = reader.Read()
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+		}
+		n++
+	}
+	return n
+}
+
+func init() {
+	RootCmd.AddCommand(bloomCmd)
+
+	bloomCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	bloomCmd.Flags().Float64P("false-positive-rate", "p", 0.01, "false positive rate")
+	bloomCmd.Flags().IntP("num", "n", 0, "number of k-mers, 0 for computing from input files")
+}
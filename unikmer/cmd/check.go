@@ -0,0 +1,171 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd represents
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "check integrity of binary files",
+	Long: `check integrity of binary files
+
+All k-mers are decoded, and for files with checksum trailer (created
+by unikmer v0.7.0 or later), the number of k-mers and CRC32C of data are
+validated, so truncated or corrupted files are detected.
+
+Output (tab-delimited):
+  file, k, number of k-mers, whether having checksum trailer, status.
+  The status is "ok" or the error message. The exit status is 1 if
+  any file fails.
+
+Tips:
+  1. Use big value of '-j' to check lots of files in parallel.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		var files []string
+		infileList := getFlagString(cmd, "infile-list")
+		if infileList != "" {
+			files, err = getListFromFile(infileList)
+			checkError(err)
+		} else {
+			files = getFileList(args)
+		}
+
+		checkFiles(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		onlyErr := getFlagBool(cmd, "only-error")
+
+		results := make([]checkResult, len(files))
+
+		var wg sync.WaitGroup
+		token := make(chan int, opt.NumCPUs)
+		for i, file := range files {
+			token <- 1
+			wg.Add(1)
+			go func(i int, file string) {
+				defer func() {
+					wg.Done()
+					<-token
+				}()
+				results[i] = checkBinaryFile(file)
+			}(i, file)
+		}
+		wg.Wait()
+
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
+		checkError(err)
+
+		var nFailed int
+		outfh.WriteString("file\tk\tnumber\tchecksum\tstatus\n")
+		for i, result := range results {
+			status := "ok"
+			if result.err != nil {
+				status = result.err.Error()
+				nFailed++
+			} else if onlyErr {
+				continue
+			}
+			checksum := "no"
+			if result.checksum {
+				checksum = "yes"
+			}
+			outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%s\t%s\n", files[i], result.k, result.number, checksum, status))
+		}
+
+		outfh.Flush()
+		if gw != nil {
+			gw.Close()
+		}
+		w.Close()
+
+		if opt.Verbose {
+			log.Infof("%d files checked, %d failed", len(files), nFailed)
+		}
+		if nFailed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+type checkResult struct {
+	k        int
+	number   int64
+	checksum bool
+	err      error
+}
+
+// checkBinaryFile reads all k-mers of a binary file, the checksum is validated
+// by unikmer.Reader at the end of file.
+func checkBinaryFile(file string) (result checkResult) {
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		result.err = err
+		return
+	}
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	if err != nil {
+		result.err = err
+		return
+	}
+	result.k = reader.K
+	result.checksum = reader.Flag&unikmer.UNIK_CHECKSUM > 0
+
+	for {
+		if reader.K > 32 {
+			_, err = reader.Read2()
+		} else {
+			_, err = reader.Read()
+		}
+		if err != nil {
+			if err != io.EOF {
+				result.err = err
+			}
+			return
+		}
+		result.number++
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
+	checkCmd.Flags().BoolP("only-error", "e", false, "only output files failing the check")
+}
@@ -0,0 +1,561 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// classifyCmd represents
+var classifyCmd = &cobra.Command{
+	Use:   "classify",
+	Short: "classify reads with k-mer sets of multiple labels",
+	Long: `classify reads with k-mer sets of multiple labels
+
+K-mers of every read are searched in all labeled k-mer sets (binary files),
+e.g., species-specific k-mers created by "unikmer diff", and the read is
+assigned to the label with the most hits.
+
+Labels:
+  Label of a binary file is its base name without extension of ".unik",
+  or given by -l/--labels in the same order of files. Files with the same
+  label are merged.
+
+Rules of assignment, the label with the most hits is chosen, and
+  1. the read is "unclassified" if the hits < -m/--min-hits,
+     or the fraction of hit k-mers < -f/--min-frac.
+  2. the read is "ambiguous" if multiple labels share the most hits.
+
+Output (-o/--out-prefix):
+  1. <prefix>.tsv, per-read result with columns:
+       read, length, kmers, status, label, hits, fraction
+     Status is one of "classified", "ambiguous" and "unclassified".
+     For ambiguous reads, all labels with the most hits are listed.
+     Hits of all labels are appended with -a/--all.
+  2. <prefix>.summary.tsv, number of reads of every label, and also
+     ambiguous and unclassified reads.
+  If the prefix is "-", per-read result is written to stdout and summary
+  to stderr.
+
+Attentions:
+  1. The K and 'canonical' flags of all files should be consistent.
+     Both strands of reads are searched if the flag 'canonical' is off.
+  2. Only k <= 32 supported.
+  3. K-mers containing bases other than A/C/G/T/U (e.g., N) are skipped.
+
+Tips:
+  1. Increasing threads number (-j/--threads) to accelerate computation,
+     reads are processed in parallel with the output order kept.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+		seq.ValidateSeq = false
+
+		var err error
+
+		var files []string
+		infileList := getFlagString(cmd, "infile-list")
+		if infileList != "" {
+			files, err = getListFromFile(infileList)
+			checkError(err)
+		} else {
+			files = getFileList(args)
+		}
+
+		checkFiles(extDataFile, files...)
+		for _, file := range files {
+			if isStdin(file) {
+				checkError(fmt.Errorf("stdin not supported, please give me .unik files"))
+			}
+		}
+
+		outFile := getFlagString(cmd, "out-prefix")
+		readFiles := getFlagStringSlice(cmd, "reads")
+		labelNames := getFlagStringSlice(cmd, "labels")
+		minHits := getFlagNonNegativeInt(cmd, "min-hits")
+		minFrac := getFlagNonNegativeFloat64(cmd, "min-frac")
+		all := getFlagBool(cmd, "all")
+
+		if len(readFiles) == 0 {
+			checkError(fmt.Errorf("flag -r/--reads needed"))
+		}
+		checkFiles("", readFiles...)
+		if minFrac > 1 {
+			checkError(fmt.Errorf("value of -f/--min-frac should be in range [0, 1]"))
+		}
+		if len(labelNames) > 0 && len(labelNames) != len(files) {
+			checkError(fmt.Errorf("number of labels (%d) and binary files (%d) do not match", len(labelNames), len(files)))
+		}
+
+		// -----------------------------------------------------------------------
+
+		// labels
+		labels := make([]string, 0, len(files))
+		labelIdx := make(map[string]int, len(files))
+		fileLabels := make([]int, len(files))
+		var label string
+		var idx int
+		var ok bool
+		for i, file := range files {
+			if len(labelNames) > 0 {
+				label = labelNames[i]
+			} else {
+				label = strings.TrimSuffix(filepath.Base(file), extDataFile)
+			}
+			if label == classifyAmbiguous || label == classifyUnclassified || label == "" {
+				checkError(fmt.Errorf("invalid label for file %s: '%s'", file, label))
+			}
+			if idx, ok = labelIdx[label]; !ok {
+				idx = len(labels)
+				labelIdx[label] = idx
+				labels = append(labels, label)
+			}
+			fileLabels[i] = idx
+		}
+
+		// k-mer -> labels
+		m := make(map[uint64][]int, mapInitSize)
+
+		var k int = -1
+		var canonical bool
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var kcode unikmer.KmerCode
+		var labelsOfKmer []int
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("reading file (%d/%d): %s", i+1, nfiles, file)
+			}
+			idx = fileLabels[i]
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+				if reader.K > 32 {
+					checkError(fmt.Errorf("k > 32 not supported: %s", file))
+				}
+				checkDNA(file, reader.Header)
+
+				if k == -1 {
+					k = reader.K
+					canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+					if opt.Verbose {
+						if canonical {
+							log.Infof("flag of canonical is on")
+						} else {
+							log.Infof("flag of canonical is off")
+						}
+					}
+				} else if k != reader.K {
+					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
+					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				}
+
+				for {
+					kcode, err = reader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+
+					labelsOfKmer = m[kcode.Code]
+					// files of the same label are adjacent in most cases
+					if len(labelsOfKmer) > 0 && labelsOfKmer[len(labelsOfKmer)-1] == idx {
+						continue
+					}
+					if len(labelsOfKmer) > 1 && intsContain(labelsOfKmer, idx) {
+						continue
+					}
+					m[kcode.Code] = append(labelsOfKmer, idx)
+				}
+			}()
+		}
+		if opt.Verbose {
+			log.Infof("%d labels, %d k-mers loaded", len(labels), len(m))
+		}
+
+		// -----------------------------------------------------------------------
+
+		var outFile2 string
+		if !isStdout(outFile) {
+			outFile2 = outFile + ".summary.tsv"
+			outFile += ".tsv"
+		}
+
+		outfh, gw, w, err := outStream(outFile, false, opt.CompressionLevel, opt.Codec)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		outfh.WriteString("read\tlength\tkmers\tstatus\tlabel\thits\tfraction")
+		if all {
+			outfh.WriteString("\tall_hits")
+		}
+		outfh.WriteString("\n")
+
+		// counts of reads of labels, ambiguous and unclassified
+		nLabels := len(labels)
+		counts := make([]int64, nLabels+2)
+
+		chIn := make(chan *classifyChunk, opt.NumCPUs)
+		chOut := make(chan *classifyChunk, opt.NumCPUs)
+
+		// workers
+		var wg sync.WaitGroup
+		for t := 0; t < opt.NumCPUs; t++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c := &classifier{
+					k:         k,
+					canonical: canonical,
+					m:         m,
+					labels:    labels,
+					minHits:   minHits,
+					minFrac:   minFrac,
+					all:       all,
+					hits:      make([]int, nLabels),
+					stamps:    make([]int, nLabels),
+				}
+				for chunk := range chIn {
+					c.classify(chunk)
+					chOut <- chunk
+				}
+			}()
+		}
+
+		// collector, keeping the order of reads
+		var nSkipped int
+		done := make(chan int)
+		go func() {
+			buf := make(map[int]*classifyChunk, opt.NumCPUs)
+			var id int
+			var chunk *classifyChunk
+			var ok bool
+			var i int
+			var n int64
+			for chunk = range chOut {
+				buf[chunk.id] = chunk
+				for {
+					if chunk, ok = buf[id]; !ok {
+						break
+					}
+					outfh.Write(chunk.buf.Bytes())
+					for i, n = range chunk.counts {
+						counts[i] += n
+					}
+					nSkipped += chunk.skipped
+					delete(buf, id)
+					id++
+				}
+			}
+			done <- 1
+		}()
+
+		// reader
+		var record *fastx.Record
+		var fastxReader *fastx.Reader
+		var id int
+		chunk := newClassifyChunk(id, nLabels)
+		for _, file := range readFiles {
+			if opt.Verbose {
+				log.Infof("reading sequence file: %s", file)
+			}
+			fastxReader, err = fastx.NewDefaultReader(file)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+
+				chunk.records = append(chunk.records, record.Clone())
+				if len(chunk.records) == classifyChunkSize {
+					chIn <- chunk
+					id++
+					chunk = newClassifyChunk(id, nLabels)
+				}
+			}
+		}
+		if len(chunk.records) > 0 {
+			chIn <- chunk
+		}
+		close(chIn)
+		wg.Wait()
+		close(chOut)
+		<-done
+
+		// -----------------------------------------------------------------------
+
+		var nReads int64
+		for _, n := range counts {
+			nReads += n
+		}
+		if opt.Verbose {
+			log.Infof("%d reads processed", nReads)
+			if nSkipped > 0 {
+				log.Infof("%d k-mers containing non-ACGT bases skipped", nSkipped)
+			}
+		}
+
+		var outfh2 *bufio.Writer
+		if outFile2 == "" {
+			outfh2 = bufio.NewWriter(os.Stderr)
+			defer outfh2.Flush()
+		} else {
+			var gw2 io.WriteCloser
+			var w2 *os.File
+			outfh2, gw2, w2, err = outStream(outFile2, false, opt.CompressionLevel, opt.Codec)
+			checkError(err)
+			defer func() {
+				outfh2.Flush()
+				if gw2 != nil {
+					gw2.Close()
+				}
+				w2.Close()
+			}()
+		}
+
+		writeCount := func(label string, n int64) {
+			var pct float64
+			if nReads > 0 {
+				pct = float64(n) / float64(nReads) * 100
+			}
+			outfh2.WriteString(fmt.Sprintf("%s\t%d\t%.4f\n", label, n, pct))
+		}
+		outfh2.WriteString("label\treads\tpercentage\n")
+		for i, label := range labels {
+			writeCount(label, counts[i])
+		}
+		writeCount(classifyAmbiguous, counts[nLabels])
+		writeCount(classifyUnclassified, counts[nLabels+1])
+	},
+}
+
+const (
+	classifyClassified   = "classified"
+	classifyAmbiguous    = "ambiguous"
+	classifyUnclassified = "unclassified"
+)
+
+// classifyChunkSize is the number of reads in a chunk processed by a worker.
+const classifyChunkSize = 1000
+
+type classifyChunk struct {
+	id      int
+	records []*fastx.Record
+
+	buf     bytes.Buffer // formatted result
+	counts  []int64      // counts of reads of labels, ambiguous and unclassified
+	skipped int
+}
+
+func newClassifyChunk(id int, nLabels int) *classifyChunk {
+	return &classifyChunk{
+		id:      id,
+		records: make([]*fastx.Record, 0, classifyChunkSize),
+		counts:  make([]int64, nLabels+2),
+	}
+}
+
+// classifier holds the k-mer index and working space of a worker.
+type classifier struct {
+	k         int
+	canonical bool
+	m         map[uint64][]int
+	labels    []string
+	minHits   int
+	minFrac   float64
+	all       bool
+
+	hits   []int
+	stamps []int // index of the last k-mer hitting a label, for avoiding double counting
+}
+
+func (c *classifier) classify(chunk *classifyChunk) {
+	var iter *unikmer.KmerIterator
+	var kcode unikmer.KmerCode
+	var ok bool
+	var err error
+	var nKmers, best, nBest, i, h int
+	var status string
+	var frac float64
+	var first bool
+	nLabels := len(c.labels)
+	buf := &chunk.buf
+	for _, record := range chunk.records {
+		for i = range c.hits {
+			c.hits[i] = 0
+			c.stamps[i] = -1
+		}
+
+		nKmers = 0
+		if len(record.Seq.Seq) >= c.k {
+			iter, err = unikmer.NewKmerIterator(record.Seq.Seq, c.k, c.canonical, false)
+			checkError(err)
+			for {
+				kcode, ok = iter.Next()
+				if !ok {
+					break
+				}
+				c.hit(c.m[kcode.Code], nKmers)
+				if !c.canonical {
+					c.hit(c.m[kcode.RevComp().Code], nKmers)
+				}
+				nKmers++
+			}
+			chunk.skipped += iter.Skipped()
+		}
+
+		best, nBest = 0, 0
+		for _, h = range c.hits {
+			if h > best {
+				best, nBest = h, 1
+			} else if h == best {
+				nBest++
+			}
+		}
+		if nKmers > 0 {
+			frac = float64(best) / float64(nKmers)
+		} else {
+			frac = 0
+		}
+
+		buf.Write(record.ID)
+		buf.WriteString("\t" + strconv.Itoa(len(record.Seq.Seq)) + "\t" + strconv.Itoa(nKmers) + "\t")
+
+		if best == 0 || best < c.minHits || frac < c.minFrac {
+			status = classifyUnclassified
+			chunk.counts[nLabels+1]++
+		} else if nBest > 1 {
+			status = classifyAmbiguous
+			chunk.counts[nLabels]++
+		} else {
+			status = classifyClassified
+		}
+		buf.WriteString(status + "\t")
+
+		if status == classifyUnclassified {
+			buf.WriteString("-")
+		} else {
+			first = true
+			for i, h = range c.hits {
+				if h != best {
+					continue
+				}
+				if status == classifyClassified {
+					chunk.counts[i]++
+				}
+				if !first {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(c.labels[i])
+				first = false
+			}
+		}
+		buf.WriteString(fmt.Sprintf("\t%d\t%.4f", best, frac))
+
+		if c.all {
+			buf.WriteByte('\t')
+			first = true
+			for i, h = range c.hits {
+				if h == 0 {
+					continue
+				}
+				if !first {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(c.labels[i] + ":" + strconv.Itoa(h))
+				first = false
+			}
+			if first {
+				buf.WriteString("-")
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	chunk.records = nil
+}
+
+// hit increases hits of labels, a k-mer is counted once for a label.
+func (c *classifier) hit(labels []int, i int) {
+	for _, idx := range labels {
+		if c.stamps[idx] == i {
+			continue
+		}
+		c.stamps[idx] = i
+		c.hits[idx]++
+	}
+}
+
+func intsContain(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RootCmd.AddCommand(classifyCmd)
+
+	classifyCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	classifyCmd.Flags().StringSliceP("reads", "r", []string{}, `(gzipped) FASTA/Q files of reads, "-" for stdin (multiple values delimted by comma supported)`)
+	classifyCmd.Flags().StringSliceP("labels", "l", []string{}, `labels of binary files in the same order (multiple values delimted by comma supported)`)
+	classifyCmd.Flags().IntP("min-hits", "m", 1, "minimum number of hit k-mers of the assigned label")
+	classifyCmd.Flags().Float64P("min-frac", "f", 0, "minimum fraction of hit k-mers of the assigned label")
+	classifyCmd.Flags().BoolP("all", "a", false, "show more information: extra column of hits of all labels")
+}
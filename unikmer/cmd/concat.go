@@ -63,7 +63,7 @@ Attentions:
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -79,6 +79,7 @@ Attentions:
 		var r *os.File
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
+		var kcode2 unikmer.KmerCode2
 		var k int = -1
 		var canonical bool
 		var flag int
@@ -104,15 +105,37 @@ Attentions:
 					if opt.Compact {
 						mode |= unikmer.UNIK_COMPACT
 					}
+					if opt.Index {
+						mode |= unikmer.UNIK_INDEXED
+					}
 					if canonical {
 						mode |= unikmer.UNIK_CANONICAL
 					}
 					writer, err = unikmer.NewWriter(outfh, k, mode)
 					checkError(err)
+					writer.Meta = opt.newMeta()
+					setKmerScheme(&writer.Header, reader.Header)
 				} else if k != reader.K {
 					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
 				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
 					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				} else {
+					checkKmerScheme(file, reader.Header, writer.Header)
+				}
+
+				if k > 32 {
+					for {
+						kcode2, err = reader.Read2()
+						if err != nil {
+							if err == io.EOF {
+								break
+							}
+							checkError(err)
+						}
+
+						writer.Write2(kcode2) // not need to check err
+					}
+					return flagContinue
 				}
 
 				for {
@@ -137,6 +160,7 @@ Attentions:
 			}
 		}
 
+		checkError(writer.WriteHeader()) // in case of no k-mers
 		checkError(writer.Flush())
 	},
 }
@@ -22,12 +22,11 @@ package cmd
 
 import (
 	"fmt"
-	"io"
+	"math"
 	"runtime"
 	"sort"
 
 	"github.com/shenwei356/bio/seq"
-	"github.com/shenwei356/bio/seqio/fastx"
 	"github.com/shenwei356/unikmer"
 	"github.com/spf13/cobra"
 )
@@ -38,6 +37,63 @@ var countCmd = &cobra.Command{
 	Short: "count k-mers from FASTA/Q sequences",
 	Long: `count k-mers from FASTA/Q sequences
 
+Attention:
+  1. K-mers containing bases other than A/C/G/T/U (e.g., N) are skipped,
+     use --verbose to see the number of skipped k-mers.
+  2. With -a/--abundance, count of every k-mer is saved in the binary file,
+     which can be shown by "unikmer view".
+  3. -m/--min-abundance and -M/--max-abundance can be used to filter
+     k-mers by counts, e.g., '-m 2' removes k-mers appearing only once,
+     which are mostly from sequencing errors.
+  4. With -W/--minimizer-window (k <= 32), only minimizers, i.e., the
+     smallest k-mer of every W consecutive k-mers are kept, k-mers are
+     compared in lexicographic order or by hash values (--minimizer-order).
+     The window size and ordering are saved in the binary file.
+  5. With -S/--syncmer-s (k <= 32), only syncmers are kept, i.e., k-mers
+     whose smallest s-mer (by hash value) is at the start or end (closed
+     syncmers), or at the position of --syncmer-offset (open syncmers).
+     Unlike minimizers, syncmers do not depend on neighbouring k-mers,
+     so they are better conserved under mutations.
+     The type and parameters are saved in the binary file.
+  6. With --spaced-seed, e.g., "110110111" (k <= 32 ones, and length <= 64),
+     only bases at care positions (1s) of every window are kept, and k is
+     the weight (number of 1s) of the seed. The seed is saved in the binary
+     file and checked by "unikmer grep/locate/inter/union/diff".
+  7. With --alphabet (k <= 12), input sequences are proteins, residues are
+     encoded with 5 bits, residues other than the 20 standard amino acids
+     (e.g., X and *) are skipped. Reduced alphabets map groups of similar
+     residues to the same letter, i.e., the first one of the group:
+       protein    20 standard amino acids
+       murphy15   LVIM C A G S T P FY W E D N Q KR H
+       murphy10   LVIM C A G ST P FYW EDNQ KR H
+       dayhoff6   AGPST C DENQ FWY HKR ILMV
+     The alphabet is saved in the binary file, and only files of the same
+     alphabet can be operated by "unikmer inter/union/diff".
+  8. With -t/--translate, DNA sequences are translated in six frames with
+     the genetic code table (-g/--genetic-code), and protein k-mers are
+     counted in the alphabet of --alphabet ("protein" for "dna"). Stop codons
+     and codons containing bases other than A/C/G/T/U split k-mers. So k-mers
+     from genomes and proteomes (with the same alphabet) can be compared
+     directly. Genetic code tables (NCBI IDs):
+       1   standard                  13  ascidian mito
+       2   vertebrate mito           14  alternative flatworm mito
+       3   yeast mito                16  chlorophycean mito
+       4   mold, mycoplasma          21  trematode mito
+       5   invertebrate mito         22  scenedesmus obliquus mito
+       6   ciliate nuclear           23  thraustochytrium mito
+       9   echinoderm mito           24  rhabdopleuridae mito
+       10  euplotid nuclear          25  candidate division SR1
+       11  bacterial and plastid     26  pachysolen tannophilus nuclear
+       12  alternative yeast nuclear
+
+Tips:
+  1. For big genomes or lots of reads (k <= 32), use --max-mem along with
+     -s/--sort to limit the memory usage, sorted chunks are saved to
+     temporary files in --tmp-dir and then merged. It does not work with
+     counting k-mer abundances.
+  2. For k <= 14, k-mers are saved in a bitmap instead of a hash table,
+     which is faster and the output is always sorted.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -56,21 +112,36 @@ var countCmd = &cobra.Command{
 		}
 
 		outFile := getFlagString(cmd, "out-prefix")
-		circular := getFlagBool(cmd, "circular")
-		k := getFlagPositiveInt(cmd, "kmer-len")
-		if k > 32 {
-			checkError(fmt.Errorf("k > 32 not supported"))
-		}
+		scheme := getKmerScheme(cmd)
+		k := scheme.k
 
 		checkFiles("", files...)
 
-		canonical := getFlagBool(cmd, "canonical")
 		sortKmers := getFlagBool(cmd, "sort")
+		maxMem, tmpDir := getExternalSortFlags(cmd)
+
+		withCount := getFlagBool(cmd, "abundance")
+		minCount := getFlagPositiveInt(cmd, "min-abundance")
+		maxCount := getFlagNonNegativeInt(cmd, "max-abundance")
+		if maxCount > 0 && maxCount < minCount {
+			checkError(fmt.Errorf("value of -M/--max-abundance should not be smaller than -m/--min-abundance"))
+		}
+		// k-mers can not be written on the fly when counts are needed.
+		counting := withCount || minCount > 1 || maxCount > 0
+
+		var m unikmer.KmerSet
+		if k <= 32 && !counting {
+			m = newKmerSet(k, scheme.protein)
+			// k-mers in bitmap are sorted for free
+			if m.Sorted() {
+				sortKmers = true
+			}
+		}
 
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -84,134 +155,109 @@ var countCmd = &cobra.Command{
 		if opt.Compact {
 			mode |= unikmer.UNIK_COMPACT
 		}
-		if canonical {
+		if opt.Index {
+			mode |= unikmer.UNIK_INDEXED
+		}
+		if scheme.canonical {
 			mode |= unikmer.UNIK_CANONICAL
 		}
 		if sortKmers {
 			mode |= unikmer.UNIK_SORTED
 		}
+		if withCount {
+			mode |= unikmer.UNIK_COUNT
+		}
 		writer, err := unikmer.NewWriter(outfh, k, mode)
 		checkError(err)
-		m := make(map[uint64]struct{}, mapInitSize)
+		setKmerScheme(&writer.Header, scheme.header())
+		writer.Meta = opt.newMeta()
+		scheme.setMeta(writer.Meta)
 
+		if k > 32 {
+			n := countKmers2(opt, files, scheme, sortKmers, counting, minCount, maxCount, writer)
+			checkError(writer.WriteHeader()) // in case of no k-mers
+			checkError(writer.Flush())
+			if opt.Verbose {
+				log.Infof("%d unique k-mers saved", n)
+			}
+			return
+		}
+
+		// external sorting, k-mers are deduplicated in merging instead of with the map
+		var sorter *codeSorter
+		if sortKmers && !counting && maxMem > 0 && !m.Sorted() {
+			sorter = newCodeSorter(opt, k, true, maxMem, tmpDir)
+			defer sorter.Cleanup()
+			m = nil
+		}
+
+		var mc map[uint64]uint32 // for counting
 		var m2 []uint64
-		if sortKmers {
+		if counting {
+			mc = make(map[uint64]uint32, mapInitSize)
 			m2 = make([]uint64, 0, mapInitSize)
 		}
 
-		var sequence, kmer, preKmer []byte
-		var originalLen, l, end, e int
-		var record *fastx.Record
-		var fastxReader *fastx.Reader
-		var kcode, preKcode unikmer.KmerCode
-		var first bool
-		var i, j, iters int
 		var ok bool
+		var c uint32
 		var n int64
-		for _, file := range files {
-			if opt.Verbose {
-				log.Infof("reading sequence file: %s", file)
+		scheme.scanKmers(opt, files, func(code uint64) {
+			if sorter != nil {
+				sorter.Add(code)
+				return
 			}
-			fastxReader, err = fastx.NewDefaultReader(file)
-			checkError(err)
-			for {
-				record, err = fastxReader.Read()
-				if err != nil {
-					if err == io.EOF {
-						break
-					}
-					checkError(err)
-					break
-				}
 
-				if canonical {
-					iters = 1
-				} else {
-					iters = 2
+			if counting {
+				if c, ok = mc[code]; !ok {
+					m2 = append(m2, code)
 				}
-
-				for j = 0; j < iters; j++ {
-					if j == 0 { // sequence
-						sequence = record.Seq.Seq
-
-						if opt.Verbose {
-							log.Infof("processing sequence: %s", record.ID)
-						}
-					} else { // reverse complement sequence
-						sequence = record.Seq.RevComInplace().Seq
-
-						if opt.Verbose {
-							log.Infof("processing reverse complement sequence: %s", record.ID)
-						}
-					}
-
-					originalLen = len(record.Seq.Seq)
-					l = len(sequence)
-
-					end = l - 1
-					if end < 0 {
-						end = 0
-					}
-					first = true
-					for i = 0; i <= end; i++ {
-						e = i + k
-						if e > originalLen {
-							if circular {
-								e = e - originalLen
-								kmer = sequence[i:]
-								kmer = append(kmer, sequence[0:e]...)
-							} else {
-								break
-							}
-						} else {
-							kmer = sequence[i : i+k]
-						}
-
-						if first {
-							kcode, err = unikmer.NewKmerCode(kmer)
-							first = false
-						} else {
-							kcode, err = unikmer.NewKmerCodeMustFromFormerOne(kmer, preKmer, preKcode)
-						}
-						if err != nil {
-							checkError(fmt.Errorf("fail to encode '%s': %s", kmer, err))
-						}
-						preKmer, preKcode = kmer, kcode
-
-						if canonical {
-							kcode = kcode.Canonical()
-						}
-
-						if _, ok = m[kcode.Code]; !ok {
-							m[kcode.Code] = struct{}{}
-							if sortKmers {
-								m2 = append(m2, kcode.Code)
-							} else {
-								checkError(writer.Write(kcode))
-								n++
-							}
-						}
-					}
+				if c < math.MaxUint32 {
+					mc[code] = c + 1
 				}
+				return
 			}
-		}
-		if sortKmers {
-			n = int64(len(m2))
 
-			if opt.Verbose {
-				log.Infof("sorting %d k-mers", n)
+			if m.Add(code) && !sortKmers {
+				checkError(writer.Write(unikmer.KmerCode{Code: code, K: k}))
+				n++
 			}
-			sort.Sort(unikmer.CodeSlice(m2))
-			if opt.Verbose {
-				log.Infof("done sorting")
+		})
+		if counting {
+			m2 = filterByCount(m2, mc, minCount, maxCount)
+			if opt.Verbose && (minCount > 1 || maxCount > 0) {
+				log.Infof("%d k-mers left after filtering by abundance", len(m2))
 			}
+		}
+		if sorter != nil {
+			n = sorter.Count()
+			writer.Number = n
+			sorter.Iterate(func(code uint64) {
+				checkError(writer.Write(unikmer.KmerCode{Code: code, K: k}))
+			})
+		} else if counting {
+			if sortKmers {
+				if opt.Verbose {
+					log.Infof("sorting %d k-mers", len(m2))
+				}
+				sort.Sort(unikmer.CodeSlice(m2))
+				if opt.Verbose {
+					log.Infof("done sorting")
+				}
+			}
+			n = int64(len(m2))
 			writer.Number = n
-
 			for _, code := range m2 {
-				writer.Write(unikmer.KmerCode{Code: code, K: k})
+				checkError(writer.WriteWithCount(unikmer.KmerCode{Code: code, K: k}, mc[code]))
 			}
+		} else if sortKmers {
+			n = int64(m.Len())
+			writer.Number = n
+			iterateKmerSet(opt, m, true, func(code uint64) {
+				checkError(writer.Write(unikmer.KmerCode{Code: code, K: k}))
+			})
 		}
 
+		checkError(writer.WriteHeader()) // in case of no k-mers
 		checkError(writer.Flush())
 		if opt.Verbose {
 			log.Infof("%d unique k-mers saved", n)
@@ -219,12 +265,114 @@ var countCmd = &cobra.Command{
 	},
 }
 
+// countKmers2 counts k-mers with K > 32 and writes them with the writer.
+func countKmers2(opt *Options, files []string, scheme *kmerScheme, sortKmers bool,
+	counting bool, minCount int, maxCount int, writer *unikmer.Writer) int64 {
+	k := scheme.k
+	var m map[[2]uint64]struct{}
+	var mc map[[2]uint64]uint32 // for counting
+	if counting {
+		mc = make(map[[2]uint64]uint32, mapInitSize)
+	} else {
+		m = make(map[[2]uint64]struct{}, mapInitSize)
+	}
+
+	var m2 [][2]uint64
+	if sortKmers || counting {
+		m2 = make([][2]uint64, 0, mapInitSize)
+	}
+
+	var ok bool
+	var c uint32
+	var n int64
+	scheme.scanKmers2(opt, files, func(code [2]uint64) {
+		if counting {
+			if c, ok = mc[code]; !ok {
+				m2 = append(m2, code)
+			}
+			if c < math.MaxUint32 {
+				mc[code] = c + 1
+			}
+			return
+		}
+
+		if _, ok = m[code]; !ok {
+			m[code] = struct{}{}
+			if sortKmers {
+				m2 = append(m2, code)
+			} else {
+				checkError(writer.Write2(unikmer.KmerCode2{Code: code, K: k}))
+				n++
+			}
+		}
+	})
+	if counting {
+		m2 = filterByCount2(m2, mc, minCount, maxCount)
+		if opt.Verbose && (minCount > 1 || maxCount > 0) {
+			log.Infof("%d k-mers left after filtering by abundance", len(m2))
+		}
+	}
+	if sortKmers {
+		sortCodes2(opt, m2)
+	}
+	if sortKmers || counting {
+		n = int64(len(m2))
+		writer.Number = n
+		for _, code := range m2 {
+			checkError(writer.WriteWithCount2(unikmer.KmerCode2{Code: code, K: k}, mc[code]))
+		}
+	}
+	return n
+}
+
+// filterByCount removes codes with counts out of range [minCount, maxCount],
+// maxCount <= 0 for no limit.
+func filterByCount(codes []uint64, counts map[uint64]uint32, minCount int, maxCount int) []uint64 {
+	if minCount <= 1 && maxCount <= 0 {
+		return codes
+	}
+	var c int
+	i := 0
+	for _, code := range codes {
+		c = int(counts[code])
+		if c < minCount || (maxCount > 0 && c > maxCount) {
+			continue
+		}
+		codes[i] = code
+		i++
+	}
+	return codes[:i]
+}
+
+// filterByCount2 is filterByCount for k-mers with K > 32.
+func filterByCount2(codes [][2]uint64, counts map[[2]uint64]uint32, minCount int, maxCount int) [][2]uint64 {
+	if minCount <= 1 && maxCount <= 0 {
+		return codes
+	}
+	var c int
+	i := 0
+	for _, code := range codes {
+		c = int(counts[code])
+		if c < minCount || (maxCount > 0 && c > maxCount) {
+			continue
+		}
+		codes[i] = code
+		i++
+	}
+	return codes[:i]
+}
+
 func init() {
 	RootCmd.AddCommand(countCmd)
 
 	countCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
-	countCmd.Flags().IntP("kmer-len", "k", 0, "k-mer length")
+	countCmd.Flags().IntP("kmer-len", "k", 0, "k-mer length, it could be omitted with --spaced-seed")
 	countCmd.Flags().BoolP("circular", "", false, "circular genome")
 	countCmd.Flags().BoolP("canonical", "K", false, "only keep the canonical k-mers")
 	countCmd.Flags().BoolP("sort", "s", false, helpSort)
+	countCmd.Flags().BoolP("abundance", "a", false, "save count (abundance) of every k-mer")
+	countCmd.Flags().IntP("min-abundance", "m", 1, "minimum abundance of k-mers to keep")
+	countCmd.Flags().IntP("max-abundance", "M", 0, "maximum abundance of k-mers to keep, 0 for no limit")
+	addKmerSchemeFlags(countCmd)
+	addExternalSortFlags(countCmd)
 }
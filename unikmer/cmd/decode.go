@@ -24,7 +24,6 @@ import (
 	"fmt"
 	"runtime"
 	"strconv"
-	"strings"
 
 	"github.com/shenwei356/breader"
 	"github.com/shenwei356/unikmer"
@@ -58,11 +57,11 @@ var decodeCmd = &cobra.Command{
 		outFile := getFlagString(cmd, "out-file")
 		all := getFlagBool(cmd, "all")
 		k := getFlagPositiveInt(cmd, "kmer-len")
-		if k > 32 {
-			checkError(fmt.Errorf("k > 32 not supported"))
+		if k > unikmer.MaxK2 {
+			checkError(fmt.Errorf("k > %d not supported", unikmer.MaxK2))
 		}
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -77,6 +76,7 @@ var decodeCmd = &cobra.Command{
 		var data interface{}
 		var line string
 		var code uint64
+		var code2 [2]uint64
 		var kmer []byte
 
 		for _, file := range files {
@@ -91,6 +91,24 @@ var decodeCmd = &cobra.Command{
 						continue
 					}
 
+					if k > 32 {
+						code2, err = parseCode2(line)
+						if err != nil {
+							checkError(fmt.Errorf("encode kmer should be non-negative integer: %s", line))
+						}
+						if k < 64 && code2[0] > unikmer.MaxCode[k-32] {
+							checkError(fmt.Errorf("encode integer overflows for k=%d: %s", k, line))
+						}
+
+						kmer = unikmer.Decode2(code2, k)
+						if all {
+							outfh.WriteString(fmt.Sprintf("%s\t%s\n", line, kmer))
+						} else {
+							outfh.WriteString(fmt.Sprintf("%s\n", kmer))
+						}
+						continue
+					}
+
 					code, err = strconv.ParseUint(line, 10, 64)
 					if err != nil {
 						checkError(fmt.Errorf("encode kmer should be non-negative integer: %s", line))
@@ -123,7 +141,7 @@ var decodeCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(decodeCmd)
 
-	decodeCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	decodeCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
 	decodeCmd.Flags().IntP("kmer-len", "k", 0, "k-mer length")
 	decodeCmd.Flags().BoolP("all", "a", false, `output all data: encoded integer, decoded k-mer`)
 
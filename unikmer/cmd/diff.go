@@ -21,12 +21,9 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"runtime"
-	"sort"
 	"sync"
 
 	"github.com/shenwei356/unikmer"
@@ -45,6 +42,12 @@ Attentions:
 Tips:
   1. Increasing threads number (-j/--threads) to accelerate computation,
      in cost of more memory occupation.
+  2. If all files are sorted (k <= 32), k-mers are merged in streaming
+     with little memory occupation, and the result is sorted.
+  3. Use --symmetric to compute symmetric difference, i.e., k-mers
+     existing in only one file.
+  4. For k <= 14, k-mers are saved in a bitmap instead of a hash table,
+     which is faster and the result is always sorted.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -62,147 +65,67 @@ Tips:
 		}
 
 		checkFiles(extDataFile, files...)
+		files = uniqFiles(files)
 
 		outFile := getFlagString(cmd, "out-prefix")
 		sortKmers := getFlagBool(cmd, "sort")
+		symmetric := getFlagBool(cmd, "symmetric")
+
+		var k0 int
+		k0, err = peekK(files[0])
+		checkError(err)
+		if k0 > 32 {
+			checkNoSketch2(files)
+			if symmetric {
+				symDiffKmers2(opt, files, outFile, sortKmers)
+			} else {
+				diffKmers2(opt, files, outFile, sortKmers)
+			}
+			return
+		}
+		if allSorted(files) {
+			if symmetric {
+				mergeSortedKmers(opt, files, outFile, setSymDiff)
+			} else {
+				mergeSortedKmers(opt, files, outFile, setDiff)
+			}
+			return
+		}
+		if symmetric {
+			symDiffKmers(opt, files, outFile, sortKmers)
+			return
+		}
 
 		threads := opt.NumCPUs
 
 		runtime.GOMAXPROCS(threads)
 
-		m := make(map[uint64]struct{}, mapInitSize)
+		var m unikmer.KmerSet
 
-		var infh *bufio.Reader
-		var r *os.File
+		var r io.Closer
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
 		var k int = -1
 		var canonical bool
-		var ok bool
 		var nfiles = len(files)
 
 		// -----------------------------------------------------------------------
 
+		// read firstFile
+
 		file := files[0]
 		if opt.Verbose {
 			log.Infof("processing file (%d/%d): %s", 1, nfiles, file)
 		}
 
-		// only one file given
-		if len(files) == 1 {
-			func() {
-				infh, r, _, err = inStream(file)
-				checkError(err)
-				defer r.Close()
-
-				reader, err = unikmer.NewReader(infh)
-				checkError(err)
-
-				k = reader.K
-
-				if !isStdout(outFile) {
-					outFile += extDataFile
-				}
-				outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-				checkError(err)
-				defer func() {
-					outfh.Flush()
-					if gw != nil {
-						gw.Close()
-					}
-					w.Close()
-				}()
-
-				var writer *unikmer.Writer
-				var m2 []uint64
-
-				if sortKmers {
-					m2 = make([]uint64, 0, mapInitSize)
-				} else {
-					var mode uint32
-					if opt.Compact {
-						mode |= unikmer.UNIK_COMPACT
-					}
-					if reader.Flag&unikmer.UNIK_CANONICAL > 0 {
-						mode |= unikmer.UNIK_CANONICAL
-					}
-					if sortKmers {
-						mode |= unikmer.UNIK_SORTED
-					}
-					writer, err = unikmer.NewWriter(outfh, reader.K, mode)
-					checkError(err)
-				}
-
-				m := make(map[uint64]struct{}, mapInitSize)
-				for {
-					kcode, err = reader.Read()
-					if err != nil {
-						if err == io.EOF {
-							break
-						}
-						checkError(err)
-					}
-
-					if _, ok = m[kcode.Code]; !ok {
-						m[kcode.Code] = struct{}{}
-						if sortKmers {
-							m2 = append(m2, kcode.Code)
-						} else {
-							writer.Write(kcode) // not need to check er
-						}
-					}
-				}
-
-				if sortKmers {
-					var mode uint32
-					if opt.Compact {
-						mode |= unikmer.UNIK_COMPACT
-					}
-					if reader.Flag&unikmer.UNIK_CANONICAL > 0 {
-						mode |= unikmer.UNIK_CANONICAL
-					}
-					mode |= unikmer.UNIK_SORTED
-					writer, err = unikmer.NewWriter(outfh, reader.K, mode)
-					checkError(err)
-
-					writer.Number = int64(len(m2))
-
-					if opt.Verbose {
-						log.Infof("sorting %d k-mers", len(m2))
-					}
-					sort.Sort(unikmer.CodeSlice(m2))
-					if opt.Verbose {
-						log.Infof("done sorting")
-					}
-
-					for _, code := range m2 {
-						writer.Write(unikmer.KmerCode{Code: code, K: k})
-					}
-				}
-
-				checkError(writer.Flush())
-				if opt.Verbose {
-					log.Infof("%d k-mers saved", len(m))
-				}
-			}()
-
-			return
-		}
-
-		// -----------------------------------------------------------------------
-
-		// > 1 files
-
-		// read firstFile
-
-		infh, r, _, err = inStream(file)
-		checkError(err)
-
-		reader, err = unikmer.NewReader(infh)
+		reader, r, err = newBinaryReader(file)
 		checkError(err)
 
 		k = reader.K
 		canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+		header := reader.Header
+		protein := reader.Flag&unikmer.UNIK_PROTEIN > 0
+		m = newKmerSet(k, protein)
 
 		for {
 			kcode, err = reader.Read()
@@ -213,58 +136,21 @@ Tips:
 				checkError(err)
 			}
 
-			m[kcode.Code] = struct{}{}
+			m.Add(kcode.Code)
 		}
 
 		r.Close()
 
 		if opt.Verbose {
-			log.Infof("%d k-mers loaded", len(m))
+			log.Infof("%d k-mers loaded", m.Len())
 		}
 
-		if len(m) == 0 {
+		// only one file given, or no k-mers
+		if len(files) == 1 || m.Len() == 0 {
 			if opt.Verbose {
 				log.Infof("exporting k-mers")
 			}
-
-			if !isStdout(outFile) {
-				outFile += extDataFile
-			}
-			outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-			checkError(err)
-			defer func() {
-				outfh.Flush()
-				if gw != nil {
-					gw.Close()
-				}
-				w.Close()
-			}()
-
-			var mode uint32
-			if opt.Compact {
-				mode |= unikmer.UNIK_COMPACT
-			}
-			if canonical {
-				mode |= unikmer.UNIK_CANONICAL
-			}
-			if sortKmers {
-				mode |= unikmer.UNIK_SORTED
-			}
-
-			writer, err := unikmer.NewWriter(outfh, k, mode)
-			checkError(err)
-
-			if sortKmers {
-				writer.Number = 0
-			}
-
-			writer.Number = 0
-			checkError(writer.WriteHeader())
-			checkError(writer.Flush())
-
-			if opt.Verbose {
-				log.Infof("%d k-mers saved", 0)
-			}
+			writeKmerSet(opt, outFile, header, sortKmers, m)
 			return
 		}
 		// -----------------------------------------------------------------------
@@ -289,7 +175,7 @@ Tips:
 		chFile := make(chan iFile, threads)
 		doneSendFile := make(chan int)
 
-		maps := make(map[int]map[uint64]struct{}, threads)
+		maps := make(map[int]unikmer.KmerSet, threads)
 		maps[0] = m
 
 		// clone maps
@@ -299,7 +185,7 @@ Tips:
 		var wg sync.WaitGroup
 		type iMap struct {
 			i int
-			m map[uint64]struct{}
+			m unikmer.KmerSet
 		}
 		ch := make(chan iMap, threads)
 		doneClone := make(chan int)
@@ -312,11 +198,7 @@ Tips:
 		for i := 1; i < threads; i++ {
 			wg.Add(1)
 			go func(i int) {
-				m1 := make(map[uint64]struct{}, len(m))
-				for k := range m {
-					m1[k] = struct{}{}
-				}
-				ch <- iMap{i: i, m: m1}
+				ch <- iMap{i: i, m: m.Clone()}
 				wg.Done()
 			}(i)
 		}
@@ -336,7 +218,7 @@ Tips:
 			go func(i int) {
 				defer func() {
 					if opt.Verbose {
-						log.Infof("worker %02d: finished with %d k-mers", i, len(maps[i]))
+						log.Infof("worker %02d: finished with %d k-mers", i, maps[i].Len())
 					}
 					wgWorkers.Done()
 				}()
@@ -345,11 +227,9 @@ Tips:
 					log.Infof("worker %02d: started", i)
 				}
 
-				var code uint64
 				var ifile iFile
 				var file string
-				var infh *bufio.Reader
-				var r *os.File
+				var r io.Closer
 				var reader *unikmer.Reader
 				var kcode unikmer.KmerCode
 				var ok bool
@@ -371,10 +251,7 @@ Tips:
 						log.Infof("worker %02d: starting processing file (%d/%d): %s", i, ifile.i+1, nfiles, file)
 					}
 
-					infh, r, _, err = inStream(file)
-					checkError(err)
-
-					reader, err = unikmer.NewReader(infh)
+					reader, r, err = newBinaryReader(file)
 					checkError(err)
 
 					if k != reader.K {
@@ -384,6 +261,7 @@ Tips:
 					if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
 						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
 					}
+					checkKmerScheme(file, reader.Header, header)
 
 					for {
 						kcode, err = reader.Read()
@@ -394,19 +272,16 @@ Tips:
 							checkError(err)
 						}
 
-						code = kcode.Code
 						// delete seen kmer
-						if _, ok = m1[code]; ok { // slowest part
-							delete(m1, code)
-						}
+						m1.Remove(kcode.Code) // slowest part
 					}
 
 					r.Close()
 
 					if opt.Verbose {
-						log.Infof("worker %02d: finished processing file (%d/%d): %s, %d k-mers remain", i, ifile.i+1, nfiles, file, len(m1))
+						log.Infof("worker %02d: finished processing file (%d/%d): %s, %d k-mers remain", i, ifile.i+1, nfiles, file, m1.Len())
 					}
-					if len(m1) == 0 {
+					if m1.Len() == 0 {
 						hasDiff = false
 						toStop <- 1
 						return
@@ -419,9 +294,6 @@ Tips:
 		go func() {
 		SENDFILE:
 			for i, file := range files[1:] {
-				if file == files[0] {
-					continue
-				}
 				select {
 				case <-done:
 					break SENDFILE
@@ -440,19 +312,18 @@ Tips:
 		toStop <- 1
 		<-doneDone
 
-		var m0 map[uint64]struct{}
+		var m0 unikmer.KmerSet
 		if !hasDiff {
 			if opt.Verbose {
 				log.Infof("no set difference found")
 			}
-			// return
+			m0 = newKmerSet(k, protein)
 		} else {
 			if opt.Verbose {
 				log.Infof("merging results from workers")
 			}
-			var code uint64
 			for _, m := range maps {
-				if len(m) == 0 {
+				if m.Len() == 0 {
 					m0 = m
 					break
 				}
@@ -461,22 +332,21 @@ Tips:
 					m0 = m
 					continue
 				}
-				for code = range m0 {
-					if _, ok = m[code]; !ok { // it's already been deleted in other m
-						delete(m0, code) // so it should be deleted
+				m0.Iterate(func(code uint64) {
+					if !m.Contains(code) { // it's already been deleted in other m
+						m0.Remove(code) // so it should be deleted
 					}
-				}
+				})
 
-				if len(m0) == 0 {
+				if m0.Len() == 0 {
 					break
 				}
 			}
 
-			if len(m0) == 0 {
+			if m0.Len() == 0 {
 				if opt.Verbose {
 					log.Warningf("no set difference found")
 				}
-				// return
 			}
 		}
 
@@ -487,75 +357,165 @@ Tips:
 		if opt.Verbose {
 			log.Infof("exporting Kmers")
 		}
+		writeKmerSet(opt, outFile, header, sortKmers, m0)
+	},
+}
 
-		if !isStdout(outFile) {
-			outFile += extDataFile
-		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-		checkError(err)
-		defer func() {
-			outfh.Flush()
-			if gw != nil {
-				gw.Close()
+func init() {
+	RootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	diffCmd.Flags().BoolP("sort", "s", false, helpSort)
+	diffCmd.Flags().BoolP("symmetric", "", false, "compute symmetric difference, i.e., k-mers existing in only one file")
+}
+
+// diffKmers2 computes set difference of binary files with K > 32,
+// files are processed in a single thread.
+func diffKmers2(opt *Options, files []string, outFile string, sortKmers bool) {
+	m := make(map[[2]uint64]struct{}, mapInitSize)
+
+	var nfiles = len(files)
+	if opt.Verbose {
+		log.Infof("processing file (%d/%d): %s", 1, nfiles, files[0])
+	}
+	header := readKmers2(files[0], -1, false, func(kcode unikmer.KmerCode2) {
+		m[kcode.Code] = struct{}{}
+	})
+	k := header.K
+	canonical := header.Flag&unikmer.UNIK_CANONICAL > 0
+
+	if opt.Verbose {
+		log.Infof("%d k-mers loaded", len(m))
+	}
+
+	for i, file := range files[1:] {
+		if len(m) == 0 {
+			if opt.Verbose {
+				log.Infof("no set difference found")
 			}
-			w.Close()
-		}()
+			break
+		}
 
-		var mode uint32
-		if opt.Compact {
-			mode |= unikmer.UNIK_COMPACT
+		if opt.Verbose {
+			log.Infof("processing file (%d/%d): %s", i+2, nfiles, file)
 		}
-		if canonical {
-			mode |= unikmer.UNIK_CANONICAL
+
+		// delete seen kmer
+		readKmers2(file, k, canonical, func(kcode unikmer.KmerCode2) {
+			delete(m, kcode.Code)
+		})
+
+		if opt.Verbose {
+			log.Infof("%d k-mers remain", len(m))
 		}
-		if sortKmers {
-			mode |= unikmer.UNIK_SORTED
+	}
+
+	codes := make([][2]uint64, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+	if sortKmers {
+		sortCodes2(opt, codes)
+	}
+	writeKmers2(opt, outFile, k, canonical, sortKmers, codes)
+}
+
+// symDiffKmers computes symmetric difference of binary files with K <= 32,
+// i.e., k-mers existing in only one file.
+func symDiffKmers(opt *Options, files []string, outFile string, sortKmers bool) {
+	// k-mers existing in only one file, and in multiple files
+	var once, multi unikmer.KmerSet
+
+	var k int = -1
+	var canonical, protein bool
+	var header unikmer.Header
+	var nfiles = len(files)
+	for i, file := range files {
+		if opt.Verbose {
+			log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
 		}
 
-		writer, err := unikmer.NewWriter(outfh, k, mode)
-		checkError(err)
+		func() {
+			reader, r, err := newBinaryReader(file)
+			checkError(err)
+			defer r.Close()
 
-		if sortKmers {
-			writer.Number = int64(len(m0))
-		}
+			if k == -1 {
+				k = reader.K
+				canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+				protein = reader.Flag&unikmer.UNIK_PROTEIN > 0
+				header = reader.Header
+				once, multi = newKmerSet(k, protein), newKmerSet(k, protein)
+			} else if k != reader.K {
+				checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+			} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
+				checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+			} else {
+				checkKmerScheme(file, reader.Header, header)
+			}
 
-		if len(m0) == 0 {
-			writer.Number = 0
-			checkError(writer.WriteHeader())
-		} else {
-			if sortKmers {
-				codes := make([]uint64, len(m0))
-				i := 0
-				for code := range m0 {
-					codes[i] = code
-					i++
-				}
-				if opt.Verbose {
-					log.Infof("sorting %d k-mers", len(codes))
-				}
-				sort.Sort(unikmer.CodeSlice(codes))
-				if opt.Verbose {
-					log.Infof("done sorting")
+			cur := newKmerSet(k, protein) // for duplicated k-mers in a file
+			var kcode unikmer.KmerCode
+			for {
+				kcode, err = reader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
 				}
-				for _, code := range codes {
-					writer.Write(unikmer.KmerCode{Code: code, K: k})
+
+				if !cur.Add(kcode.Code) || multi.Contains(kcode.Code) {
+					continue
 				}
-			} else {
-				for code := range m0 {
-					writer.Write(unikmer.KmerCode{Code: code, K: k})
+				if !once.Add(kcode.Code) {
+					once.Remove(kcode.Code)
+					multi.Add(kcode.Code)
 				}
 			}
-		}
-		checkError(writer.Flush())
+		}()
+	}
+
+	writeKmerSet(opt, outFile, header, sortKmers, once)
+}
+
+// symDiffKmers2 computes symmetric difference of binary files with K > 32.
+func symDiffKmers2(opt *Options, files []string, outFile string, sortKmers bool) {
+	// index (1-based) of the only file containing the k-mer, -1 for multiple files
+	m := make(map[[2]uint64]int, mapInitSize)
+
+	var k int = -1
+	var canonical bool
+	var header *unikmer.Header
+	var v int
+	var ok bool
+	var nfiles = len(files)
+	for i, file := range files {
 		if opt.Verbose {
-			log.Infof("%d k-mers saved", len(m0))
+			log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
 		}
-	},
-}
 
-func init() {
-	RootCmd.AddCommand(diffCmd)
+		header = readKmers2(file, k, canonical, func(kcode unikmer.KmerCode2) {
+			if v, ok = m[kcode.Code]; !ok {
+				m[kcode.Code] = i + 1
+			} else if v != i+1 {
+				m[kcode.Code] = -1
+			}
+		})
+		if k == -1 {
+			k = header.K
+			canonical = header.Flag&unikmer.UNIK_CANONICAL > 0
+		}
+	}
 
-	diffCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
-	diffCmd.Flags().BoolP("sort", "s", false, helpSort)
+	codes := make([][2]uint64, 0, len(m))
+	for code, v := range m {
+		if v > 0 {
+			codes = append(codes, code)
+		}
+	}
+	if sortKmers {
+		sortCodes2(opt, codes)
+	}
+	writeKmers2(opt, outFile, k, canonical, sortKmers, codes)
 }
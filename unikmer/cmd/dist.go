@@ -0,0 +1,520 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// distCmd represents
+var distCmd = &cobra.Command{
+	Use:   "dist",
+	Short: "similarity and distance between binary files",
+	Long: `similarity and distance between binary files
+
+Metrics of every pair of files (A, B):
+  inter          size of intersection
+  union          size of union
+  jaccard        Jaccard index, inter / union
+  containment1   fraction of k-mers of A found in B, inter / size1
+  containment2   fraction of k-mers of B found in A, inter / size2
+  distance       Mash distance, -1/k * ln(2*jaccard / (1 + jaccard))
+
+Sketches:
+  If all files are sketches (created by "unikmer sketch"), metrics are
+  estimated from sketches. For bottom-s MinHash, Jaccard index is the
+  fraction of shared k-mers in the bottom-s hash values of the union,
+  and other metrics are computed on k-mers of sketches.
+
+Output format:
+  1. Default output is a tab-delimited table in long format, one pair per line.
+  2. Use -m/--matrix to output a matrix of one metric. For 'containment',
+     value in row A and column B is the fraction of k-mers of A found in B.
+
+Attentions:
+  1. The 'canonical' flags of all files should be consistent.
+  2. Sketches with different types or parameters can not be compared.
+  3. If all files are sorted (k <= 32), k-mers of every pair are merged in
+     streaming with little memory occupation, otherwise all files are loaded
+     into RAM.
+
+Tips:
+  1. Increasing threads number (-j/--threads) to accelerate computation.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		var files []string
+		infileList := getFlagString(cmd, "infile-list")
+		if infileList != "" {
+			files, err = getListFromFile(infileList)
+			checkError(err)
+		} else {
+			files = getFileList(args)
+		}
+
+		checkFiles(extDataFile, files...)
+		if len(files) < 2 {
+			checkError(fmt.Errorf("at least two files needed"))
+		}
+
+		outFile := getFlagString(cmd, "out-file")
+		matrix := strings.ToLower(getFlagString(cmd, "matrix"))
+		decimals := getFlagNonNegativeInt(cmd, "decimals")
+		if matrix != "" {
+			if _, ok := distMetrics[matrix]; !ok {
+				checkError(fmt.Errorf("invalid value of -m/--matrix: %s, available: %s", matrix, strings.Join(distMetricNames, ", ")))
+			}
+		}
+
+		// check K, canonical flags and k-mer schemes
+		var k int = -1
+		var canonical, hasStdin bool
+		var sketch = true
+		var h, h0 unikmer.Header
+		for _, file := range files {
+			if isStdin(file) {
+				hasStdin = true
+			}
+			h, err = peekHeader(file)
+			checkError(err)
+			if h.Flag&unikmer.UNIK_SKETCH == 0 {
+				sketch = false
+			}
+			if k == -1 {
+				k = h.K
+				canonical = h.Flag&unikmer.UNIK_CANONICAL > 0
+				h0 = h
+			} else if k != h.K {
+				checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", h.K, file, k))
+			} else if (h.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
+				checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+			} else {
+				checkKmerScheme(file, h, h0)
+			}
+		}
+
+		n := len(files)
+		sizes := make([]int64, n)
+		inters := make([][]int64, n)
+		for i := range inters {
+			inters[i] = make([]int64, n)
+		}
+
+		var jaccards [][]float64 // estimated from MinHash sketches
+
+		// files are read for many times in streaming mode, so stdin is not allowed.
+		if sketch {
+			jaccards = distSketches(opt, files, sizes, inters)
+		} else if !hasStdin && allSorted(files) {
+			distSorted(opt, files, sizes, inters)
+		} else if k <= 32 {
+			distMaps(opt, files, sizes, inters)
+		} else {
+			distMaps2(opt, files, sizes, inters)
+		}
+
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		formatFloat := func(v float64) string {
+			return strconv.FormatFloat(v, 'f', decimals, 64)
+		}
+
+		if matrix != "" {
+			metric := distMetrics[matrix]
+			outfh.WriteString("\t" + strings.Join(files, "\t") + "\n")
+			for i := 0; i < n; i++ {
+				outfh.WriteString(files[i])
+				for j := 0; j < n; j++ {
+					info := newDistInfo(k, sizes[i], sizes[j], inters[i][j])
+					if jaccards != nil {
+						info = info.withJaccard(k, jaccards[i][j])
+					}
+					v := info.metric(metric)
+					if metric == distInter || metric == distUnion {
+						outfh.WriteString(fmt.Sprintf("\t%d", int64(v)))
+					} else {
+						outfh.WriteString("\t" + formatFloat(v))
+					}
+				}
+				outfh.WriteString("\n")
+			}
+			return
+		}
+
+		outfh.WriteString("file1\tfile2\tsize1\tsize2\tinter\tunion\tjaccard\tcontainment1\tcontainment2\tdistance\n")
+		var info distInfo
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				info = newDistInfo(k, sizes[i], sizes[j], inters[i][j])
+				if jaccards != nil {
+					info = info.withJaccard(k, jaccards[i][j])
+				}
+				outfh.WriteString(fmt.Sprintf("%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n",
+					files[i], files[j], info.size1, info.size2, info.inter, info.union,
+					formatFloat(info.jaccard), formatFloat(info.containment1),
+					formatFloat(info.containment2), formatFloat(info.distance)))
+			}
+		}
+	},
+}
+
+const (
+	distInter = iota
+	distUnion
+	distJaccard
+	distContainment
+	distDistance
+)
+
+var distMetricNames = []string{"inter", "union", "jaccard", "containment", "distance"}
+
+var distMetrics = map[string]int{
+	"inter":       distInter,
+	"union":       distUnion,
+	"jaccard":     distJaccard,
+	"containment": distContainment,
+	"distance":    distDistance,
+}
+
+// distInfo contains similarity and distance of two k-mer sets.
+type distInfo struct {
+	size1, size2 int64
+	inter, union int64
+
+	jaccard      float64
+	containment1 float64
+	containment2 float64
+	distance     float64
+}
+
+func newDistInfo(k int, size1, size2, inter int64) distInfo {
+	info := distInfo{size1: size1, size2: size2, inter: inter}
+	info.union = size1 + size2 - inter
+	if info.union > 0 {
+		info.jaccard = float64(inter) / float64(info.union)
+	}
+	if size1 > 0 {
+		info.containment1 = float64(inter) / float64(size1)
+	}
+	if size2 > 0 {
+		info.containment2 = float64(inter) / float64(size2)
+	}
+	info.distance = unikmer.MashDistance(info.jaccard, k)
+	return info
+}
+
+// withJaccard replaces the Jaccard index with an estimated one.
+func (info distInfo) withJaccard(k int, jaccard float64) distInfo {
+	info.jaccard = jaccard
+	info.distance = unikmer.MashDistance(jaccard, k)
+	return info
+}
+
+func (info distInfo) metric(m int) float64 {
+	switch m {
+	case distInter:
+		return float64(info.inter)
+	case distUnion:
+		return float64(info.union)
+	case distJaccard:
+		return info.jaccard
+	case distContainment:
+		return info.containment1
+	case distDistance:
+		return info.distance
+	}
+	return 0
+}
+
+// forEachPair calls fn for every pair of files (i < j) with opt.NumCPUs goroutines.
+func forEachPair(opt *Options, n int, fn func(i, j int)) {
+	type pair struct{ i, j int }
+	ch := make(chan pair, opt.NumCPUs)
+	var wg sync.WaitGroup
+	for t := 0; t < opt.NumCPUs; t++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range ch {
+				fn(p.i, p.j)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			ch <- pair{i, j}
+		}
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// forEachFile calls fn for every file with opt.NumCPUs goroutines.
+func forEachFile(opt *Options, files []string, fn func(i int, file string)) {
+	var wg sync.WaitGroup
+	token := make(chan int, opt.NumCPUs)
+	for i, file := range files {
+		token <- 1
+		wg.Add(1)
+		go func(i int, file string) {
+			defer func() {
+				wg.Done()
+				<-token
+			}()
+			fn(i, file)
+		}(i, file)
+	}
+	wg.Wait()
+}
+
+// distSorted computes sizes and intersections of sorted files by streaming merging.
+func distSorted(opt *Options, files []string, sizes []int64, inters [][]int64) {
+	// mergeFiles merges sorted files and calls fn for every k-mer
+	// with indexes of files containing it.
+	mergeFiles := func(files []string, fn func(idx []int)) {
+		readers := make([]*unikmer.Reader, len(files))
+		for i, file := range files {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			readers[i], err = unikmer.NewReader(infh)
+			checkError(err)
+		}
+		iter, err := unikmer.NewMergeIterator(readers...)
+		checkError(err)
+
+		var idx []int
+		for {
+			_, idx, err = iter.Next()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+			fn(idx)
+		}
+	}
+
+	if opt.Verbose {
+		log.Infof("counting k-mers of %d sorted files", len(files))
+	}
+	forEachFile(opt, files, func(i int, file string) {
+		var n int64
+		mergeFiles([]string{file}, func(idx []int) { n++ })
+		sizes[i] = n
+		inters[i][i] = n
+	})
+
+	if opt.Verbose {
+		log.Infof("computing intersections of %d pairs", len(files)*(len(files)-1)/2)
+	}
+	forEachPair(opt, len(files), func(i, j int) {
+		var n int64
+		mergeFiles([]string{files[i], files[j]}, func(idx []int) {
+			if len(idx) == 2 {
+				n++
+			}
+		})
+		inters[i][j], inters[j][i] = n, n
+	})
+}
+
+// distMaps computes sizes and intersections of files (K <= 32) with maps.
+func distMaps(opt *Options, files []string, sizes []int64, inters [][]int64) {
+	if opt.Verbose {
+		log.Infof("loading k-mers of %d files", len(files))
+	}
+	maps := make([]map[uint64]struct{}, len(files))
+	forEachFile(opt, files, func(i int, file string) {
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+
+		m := make(map[uint64]struct{}, mapInitSize)
+		var kcode unikmer.KmerCode
+		for {
+			kcode, err = reader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+			m[kcode.Code] = struct{}{}
+		}
+		maps[i] = m
+		sizes[i] = int64(len(m))
+		inters[i][i] = sizes[i]
+	})
+
+	if opt.Verbose {
+		log.Infof("computing intersections of %d pairs", len(files)*(len(files)-1)/2)
+	}
+	forEachPair(opt, len(files), func(i, j int) {
+		m1, m2 := maps[i], maps[j]
+		if len(m1) > len(m2) {
+			m1, m2 = m2, m1
+		}
+		var n int64
+		var ok bool
+		for code := range m1 {
+			if _, ok = m2[code]; ok {
+				n++
+			}
+		}
+		inters[i][j], inters[j][i] = n, n
+	})
+}
+
+// distMaps2 computes sizes and intersections of files with K > 32 with maps.
+func distMaps2(opt *Options, files []string, sizes []int64, inters [][]int64) {
+	if opt.Verbose {
+		log.Infof("loading k-mers of %d files", len(files))
+	}
+	maps := make([]map[[2]uint64]struct{}, len(files))
+	forEachFile(opt, files, func(i int, file string) {
+		m := make(map[[2]uint64]struct{}, mapInitSize)
+		readKmers2(file, -1, false, func(kcode unikmer.KmerCode2) {
+			m[kcode.Code] = struct{}{}
+		})
+		maps[i] = m
+		sizes[i] = int64(len(m))
+		inters[i][i] = sizes[i]
+	})
+
+	if opt.Verbose {
+		log.Infof("computing intersections of %d pairs", len(files)*(len(files)-1)/2)
+	}
+	forEachPair(opt, len(files), func(i, j int) {
+		m1, m2 := maps[i], maps[j]
+		if len(m1) > len(m2) {
+			m1, m2 = m2, m1
+		}
+		var n int64
+		var ok bool
+		for code := range m1 {
+			if _, ok = m2[code]; ok {
+				n++
+			}
+		}
+		inters[i][j], inters[j][i] = n, n
+	})
+}
+
+// distSketches computes sizes and intersections of sketches, and returns
+// estimated Jaccard indexes for MinHash sketches.
+func distSketches(opt *Options, files []string, sizes []int64, inters [][]int64) [][]float64 {
+	if opt.Verbose {
+		log.Infof("loading %d sketches", len(files))
+	}
+	sketches := make([]*unikmer.Sketch, len(files))
+	forEachFile(opt, files, func(i int, file string) {
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+
+		sketch, err := unikmer.NewSketchFromHeader(reader.Header)
+		checkError(err)
+
+		var kcode unikmer.KmerCode
+		var kcode2 unikmer.KmerCode2
+		for {
+			if reader.K > 32 {
+				kcode2, err = reader.Read2()
+			} else {
+				kcode, err = reader.Read()
+			}
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+			if reader.K > 32 {
+				sketch.Add2(kcode2.Code)
+			} else {
+				sketch.Add(kcode.Code)
+			}
+		}
+		sketches[i] = sketch
+		sizes[i] = int64(sketch.Len())
+		inters[i][i] = sizes[i]
+	})
+
+	var jaccards [][]float64
+	if sketches[0].Size > 0 {
+		jaccards = make([][]float64, len(files))
+		for i := range jaccards {
+			jaccards[i] = make([]float64, len(files))
+			jaccards[i][i] = 1
+		}
+	}
+
+	forEachPair(opt, len(files), func(i, j int) {
+		n, err := sketches[i].Inter(sketches[j])
+		if err != nil {
+			checkError(fmt.Errorf("%s vs %s: %s", files[i], files[j], err))
+		}
+		inters[i][j], inters[j][i] = int64(n), int64(n)
+		if jaccards != nil {
+			jaccards[i][j], _ = sketches[i].Jaccard(sketches[j])
+			jaccards[j][i] = jaccards[i][j]
+		}
+	})
+	return jaccards
+}
+
+func init() {
+	RootCmd.AddCommand(distCmd)
+
+	distCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
+	distCmd.Flags().StringP("matrix", "m", "", `output matrix of a metric instead of long-format table, available: `+strings.Join(distMetricNames, ", "))
+	distCmd.Flags().IntP("decimals", "d", 6, "number of decimal places of float values")
+}
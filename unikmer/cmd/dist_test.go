@@ -0,0 +1,116 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// kmerStrings returns canonical k-mers of a sequence.
+func kmerStrings(t *testing.T, seq []byte, k int) map[string]struct{} {
+	m := make(map[string]struct{})
+	if k > 32 {
+		for code := range kmers2(t, seq, k) {
+			m[unikmer.KmerCode2{Code: code, K: k}.String()] = struct{}{}
+		}
+		return m
+	}
+
+	iter, err := unikmer.NewKmerIterator(seq, k, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		kcode, ok := iter.Next()
+		if !ok {
+			break
+		}
+		m[kcode.String()] = struct{}{}
+	}
+	return m
+}
+
+// TestDist checks intersections computed by distMaps (k <= 32, unsorted),
+// distMaps2 (k > 32, unsorted) and distSorted.
+func TestDist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unikmer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	seqs := genSeqs()
+	for _, k := range []int{21, 41} {
+		sets := make([]map[string]struct{}, len(seqs))
+		for i, seq := range seqs {
+			sets[i] = kmerStrings(t, seq, k)
+		}
+
+		for _, sorted := range []bool{false, true} {
+			s := fmt.Sprintf("-s=%v", sorted)
+
+			files := make([]string, len(seqs))
+			for i, seq := range seqs {
+				prefix := filepath.Join(dir, fmt.Sprintf("%c%d%v", 'a'+i, k, sorted))
+				if err = writeFasta(prefix+".fa", seq); err != nil {
+					t.Fatal(err)
+				}
+				runCmd(t, "count", "-k", strconv.Itoa(k), "-K", s, "-o", prefix, prefix+".fa")
+				files[i] = prefix + extDataFile
+			}
+
+			out := filepath.Join(dir, "dist.tsv")
+			args := append([]string{"dist", "-m", "inter", "-o", out}, files...)
+			runCmd(t, args...)
+
+			data, err := ioutil.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+			if len(lines) != len(files)+1 {
+				t.Fatalf("k=%d sorted=%v: unexpected number of lines: %d", k, sorted, len(lines))
+			}
+			for i, line := range lines[1:] {
+				items := strings.Split(line, "\t")
+				for j, item := range items[1:] {
+					var n int
+					for mer := range sets[i] {
+						if _, ok := sets[j][mer]; ok {
+							n++
+						}
+					}
+					if item != strconv.Itoa(n) {
+						t.Errorf("k=%d sorted=%v: intersection of %d and %d mismatch: %s vs %d", k, sorted, i, j, item, n)
+					}
+				}
+			}
+		}
+	}
+}
@@ -62,7 +62,7 @@ var dumpCmd = &cobra.Command{
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -75,8 +75,10 @@ var dumpCmd = &cobra.Command{
 		var writer *unikmer.Writer
 
 		var m map[uint64]struct{}
+		var m2 map[[2]uint64]struct{} // for k > 32
 		if unique {
 			m = make(map[uint64]struct{}, mapInitSize)
+			m2 = make(map[[2]uint64]struct{}, mapInitSize)
 		}
 
 		var k int = -1
@@ -86,6 +88,7 @@ var dumpCmd = &cobra.Command{
 		var data interface{}
 		var line string
 		var kcode, kcodeC unikmer.KmerCode
+		var kcode2, kcodeC2 unikmer.KmerCode2
 		var ok bool
 		var n int64
 
@@ -112,6 +115,9 @@ var dumpCmd = &cobra.Command{
 						if opt.Compact {
 							mode |= unikmer.UNIK_COMPACT
 						}
+						if opt.Index {
+							mode |= unikmer.UNIK_INDEXED
+						}
 						if canonical || canonicalOnly {
 							mode |= unikmer.UNIK_CANONICAL
 						}
@@ -120,7 +126,36 @@ var dumpCmd = &cobra.Command{
 						}
 						writer, err = unikmer.NewWriter(outfh, l, mode)
 						checkError(err)
-						defer checkError(writer.Flush())
+						writer.Meta = opt.newMeta()
+					}
+
+					if k > 32 {
+						kcode2, err = unikmer.NewKmerCode2([]byte(line))
+						if err != nil {
+							checkError(fmt.Errorf("fail to encode '%s': %s", line, err))
+						}
+
+						if canonicalOnly {
+							kcodeC2 = kcode2.Canonical()
+							if kcode2.Code != kcodeC2.Code {
+								continue
+							}
+							kcode2 = kcodeC2
+						} else if canonical {
+							kcode2 = kcode2.Canonical()
+						}
+
+						if unique {
+							if _, ok = m2[kcode2.Code]; !ok {
+								m2[kcode2.Code] = struct{}{}
+								checkError(writer.Write2(kcode2))
+								n++
+							}
+						} else {
+							checkError(writer.Write2(kcode2))
+							n++
+						}
+						continue
 					}
 
 					kcode, err = unikmer.NewKmerCode([]byte(line))
@@ -152,6 +187,7 @@ var dumpCmd = &cobra.Command{
 			}
 		}
 
+		checkError(writer.WriteHeader()) // in case of no k-mers
 		checkError(writer.Flush())
 		if opt.Verbose {
 			log.Infof("%d unique k-mers found", n)
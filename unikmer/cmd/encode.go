@@ -23,7 +23,6 @@ package cmd
 import (
 	"fmt"
 	"runtime"
-	"strings"
 
 	"github.com/shenwei356/breader"
 	"github.com/shenwei356/unikmer"
@@ -58,7 +57,7 @@ var encodeCmd = &cobra.Command{
 		all := getFlagBool(cmd, "all")
 		canonical := getFlagBool(cmd, "canonical")
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -75,6 +74,7 @@ var encodeCmd = &cobra.Command{
 		var data interface{}
 		var line string
 		var kcode unikmer.KmerCode
+		var kcode2 unikmer.KmerCode2
 
 		for _, file := range files {
 			reader, err = breader.NewDefaultBufferedReader(file)
@@ -94,6 +94,23 @@ var encodeCmd = &cobra.Command{
 						checkError(fmt.Errorf("K-mer length mismatch, previous: %d, current: %d. %s", k, l, line))
 					}
 
+					if k > 32 {
+						kcode2, err = unikmer.NewKmerCode2([]byte(line))
+						if err != nil {
+							checkError(fmt.Errorf("fail to encode '%s': %s", line, err))
+						}
+						if canonical {
+							kcode2 = kcode2.Canonical()
+						}
+
+						if all {
+							outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\n", line, kcode2.String(), code2Str(kcode2.Code), kcode2.BitsString()))
+						} else {
+							outfh.WriteString(code2Str(kcode2.Code) + "\n")
+						}
+						continue
+					}
+
 					kcode, err = unikmer.NewKmerCode([]byte(line))
 					if err != nil {
 						checkError(fmt.Errorf("fail to encode '%s': %s", line, err))
@@ -117,7 +134,7 @@ var encodeCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(encodeCmd)
 
-	encodeCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	encodeCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
 	encodeCmd.Flags().BoolP("all", "a", false, `output all data: orginial k-mer, parsed k-mer, encoded integer, encode bits`)
 	encodeCmd.Flags().BoolP("canonical", "K", false, "keep the canonical k-mers")
 }
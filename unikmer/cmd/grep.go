@@ -40,6 +40,24 @@ var grepCmd = &cobra.Command{
 	Short: "search k-mers from binary files",
 	Long: `search k-mers from binary files
 
+The target file could also be a Bloom filter file (.bloom) created
+by "unikmer bloom", where false positive matches are possible.
+
+For sorted and indexed binary file (created with global flag --index),
+k-mers are searched by binary search on the block index without loading
+all k-mers, which is much faster for a few queries against a big file.
+Note that the block index is required, sorted files without it, or
+compressed ones, are still read entirely.
+For file in bitmap or Elias–Fano encoding (created with "unikmer sort
+--succinct"), k-mers are searched directly in the compact structure.
+
+For file of spaced k-mers (created with "unikmer count --spaced-seed"),
+queries could be k-mers with the length of the weight of the seed, or
+the span of the seed, where bases at don't-care positions are ignored.
+
+For file of protein k-mers (created with "unikmer count --alphabet"),
+queries are protein k-mers, which are encoded with the alphabet of the file.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -60,7 +78,12 @@ var grepCmd = &cobra.Command{
 			checkError(fmt.Errorf("no more than one file should be given"))
 		}
 
-		checkFiles(extDataFile, files...)
+		checkFiles("", files...)
+		for _, file := range files {
+			if !(isStdin(file) || strings.HasSuffix(file, extDataFile) || strings.HasSuffix(file, extBloomFile)) {
+				checkError(fmt.Errorf("input should be stdin or %s/%s file: %s", extDataFile, extBloomFile, file))
+			}
+		}
 
 		outFile := getFlagString(cmd, "out-file")
 		pattern := getFlagStringSlice(cmd, "query")
@@ -85,8 +108,10 @@ var grepCmd = &cobra.Command{
 		}
 
 		file := files[0]
+		isBloom := strings.HasSuffix(file, extBloomFile)
 
 		m := make(map[uint64]struct{}, mapInitSize)
+		var bf *unikmer.BloomFilter
 
 		if opt.Verbose {
 			log.Infof("reading k-mers from %s", file)
@@ -96,28 +121,94 @@ var grepCmd = &cobra.Command{
 		var r *os.File
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
-		var mer []byte
 
-		infh, r, _, err = inStream(file)
+		var compressed bool
+		infh, r, compressed, err = inStream(file)
 		checkError(err)
 		defer r.Close()
 
-		reader, err = unikmer.NewReader(infh)
-		checkError(err)
+		var k int
+		var header unikmer.Header
+		if isBloom {
+			bf, err = unikmer.ReadBloomFilter(infh)
+			checkError(err)
+			header = bf.Header
+		} else {
+			reader, err = unikmer.NewReader(infh)
+			checkError(err)
+			header = reader.Header
+		}
+		k = header.K
+
+		// queries with the length of span of spaced seed are encoded at care positions
+		var seed *unikmer.SpacedSeed
+		if header.Flag&unikmer.UNIK_SPACED > 0 {
+			seed, err = unikmer.NewSpacedSeed(header.SpacedSeed)
+			checkError(err)
+			if opt.Verbose {
+				log.Infof("spaced seed of %s: %s", file, seed)
+			}
+		}
+		validLen := func(query string) bool {
+			return len(query) == k || (seed != nil && len(query) == seed.Span)
+		}
+		protein := header.Flag&unikmer.UNIK_PROTEIN > 0
+		if protein && degenerate {
+			checkError(fmt.Errorf("flag -d/--degenerate not supported for protein k-mers"))
+		}
+		encode := func(query []byte) (unikmer.KmerCode, error) {
+			if seed != nil && len(query) == seed.Span {
+				return seed.Encode(query)
+			}
+			if protein {
+				return unikmer.NewProteinKmerCode(query, header.Alphabet)
+			}
+			return unikmer.NewKmerCode(query)
+		}
+
+		// binary search on the block index of sorted file
+		var searchMode bool
+		if !isBloom && !isStdin(file) && !compressed &&
+			reader.Flag&unikmer.UNIK_SORTED > 0 && reader.Flag&unikmer.UNIK_INDEXED > 0 {
+			searchMode = true
 
-		k := reader.K
+			var fh *os.File
+			fh, err = os.Open(file)
+			checkError(err)
+			defer fh.Close()
+
+			reader, err = unikmer.NewReader(fh)
+			checkError(err)
+			_, err = reader.Index()
+			checkError(err)
+			if opt.Verbose {
+				log.Infof("searching k-mers with block index of %s", file)
+			}
+		} else if !isBloom && reader.Flag&unikmer.UNIK_SORTED > 0 && reader.Flag&unikmer.UNIK_SUCCINCT == 0 {
+			log.Warningf("binary search only supported for uncompressed file created with global flag --index, reading all k-mers of %s", file)
+		}
+		if k > 32 {
+			checkError(fmt.Errorf("k > 32 not supported: %s", file))
+		}
+
+		// k-mers in bitmap or Elias–Fano encoding are searched directly
+		var ss *unikmer.SuccinctSet
+		if !isBloom && reader.Flag&unikmer.UNIK_SUCCINCT > 0 {
+			ss, err = reader.SuccinctSet()
+			checkError(err)
+		}
 
 		// check pattern in advance
 		if patternFile == "" {
 			for _, query := range pattern {
-				if len(query) != k {
+				if !validLen(query) {
 					log.Warningf("length of query sequence (%d) != k size (%d): %s", len(query), k, query)
 					return
 				}
 			}
 		}
 
-		for {
+		for !isBloom && !searchMode && ss == nil {
 			kcode, err = reader.Read()
 			if err != nil {
 				if err == io.EOF {
@@ -129,11 +220,11 @@ var grepCmd = &cobra.Command{
 			m[kcode.Code] = struct{}{}
 		}
 
-		if opt.Verbose {
+		if opt.Verbose && !searchMode {
 			log.Infof("finish reading k-mers from %s", file)
 		}
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -143,6 +234,22 @@ var grepCmd = &cobra.Command{
 			w.Close()
 		}()
 
+		contains := func(kcode unikmer.KmerCode) bool {
+			if isBloom {
+				return bf.Contains(kcode)
+			}
+			if searchMode {
+				ok, err := reader.Contains(kcode.Code)
+				checkError(err)
+				return ok
+			}
+			if ss != nil {
+				return ss.Contains(kcode.Code)
+			}
+			_, ok := m[kcode.Code]
+			return ok
+		}
+
 		var queries [][]byte
 		var q []byte
 		var ok, hit bool
@@ -160,7 +267,7 @@ var grepCmd = &cobra.Command{
 					if query == "" {
 						continue
 					}
-					if len(query) != k {
+					if !validLen(query) {
 						log.Warningf("length of query sequence (%d) != k size (%d): %s", len(query), k, query)
 						continue
 					}
@@ -176,12 +283,12 @@ var grepCmd = &cobra.Command{
 					}
 
 					for _, q = range queries {
-						kcode, err = unikmer.NewKmerCode(q)
+						kcode, err = encode(q)
 						if err != nil {
-							checkError(fmt.Errorf("fail to encode query '%s': %s", mer, err))
+							checkError(fmt.Errorf("fail to encode query '%s': %s", q, err))
 						}
 
-						_, ok = m[kcode.Code]
+						ok = contains(kcode)
 
 						if !invertMatch {
 							hit = ok
@@ -206,7 +313,7 @@ var grepCmd = &cobra.Command{
 				if query == "" {
 					continue
 				}
-				if len(query) != k {
+				if !validLen(query) {
 					log.Warningf("length of query sequence (%d) != k size (%d): %s", len(query), k, query)
 					continue
 				}
@@ -222,12 +329,12 @@ var grepCmd = &cobra.Command{
 				}
 
 				for _, q = range queries {
-					kcode, err = unikmer.NewKmerCode(q)
+					kcode, err = encode(q)
 					if err != nil {
-						checkError(fmt.Errorf("fail to encode query '%s': %s", mer, err))
+						checkError(fmt.Errorf("fail to encode query '%s': %s", q, err))
 					}
 
-					_, ok = m[kcode.Code]
+					ok = contains(kcode)
 
 					if !invertMatch {
 						hit = ok
@@ -254,7 +361,7 @@ var grepCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(grepCmd)
 
-	grepCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	grepCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
 
 	grepCmd.Flags().StringSliceP("query", "q", []string{""}, `query k-mers (multiple values delimted by comma supported)`)
 	grepCmd.Flags().StringP("query-file", "f", "", "query file (one k-mer per line)")
@@ -21,12 +21,9 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"runtime"
-	"sort"
 
 	"github.com/shenwei356/unikmer"
 	"github.com/spf13/cobra"
@@ -38,6 +35,12 @@ var interCmd = &cobra.Command{
 	Short: "intersection of multiple binary files",
 	Long: `intersection of multiple binary files
 
+Tips:
+  1. If all files are sorted (k <= 32), k-mers are merged in streaming
+     with little memory occupation, and the result is sorted.
+  2. For k <= 14, k-mers are saved in a bitmap instead of a hash table,
+     which is faster and the result is always sorted.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -55,143 +58,61 @@ var interCmd = &cobra.Command{
 		}
 
 		checkFiles(extDataFile, files...)
+		files = uniqFiles(files)
 
 		outFile := getFlagString(cmd, "out-prefix")
 		sortKmers := getFlagBool(cmd, "sort")
 
-		m := make(map[uint64]bool, mapInitSize)
+		var k0 int
+		k0, err = peekK(files[0])
+		checkError(err)
+		if k0 > 32 {
+			checkNoSketch2(files)
+			interKmers2(opt, files, outFile, sortKmers)
+			return
+		}
+		if allSorted(files) {
+			mergeSortedKmers(opt, files, outFile, setInter)
+			return
+		}
 
-		var infh *bufio.Reader
-		var r *os.File
+		var m, seen unikmer.KmerSet
+
+		var r io.Closer
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
 		var k int = -1
-		var canonical bool
+		var canonical, protein bool
+		var header unikmer.Header
 		var firstFile = true
-		var hasInter = true
-		var code uint64
-		var ok bool
-		var flag int
 		var nfiles = len(files)
 		for i, file := range files {
-			if !firstFile && file == files[0] {
-				continue
-			}
-
 			if opt.Verbose {
 				log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
 			}
 
-			flag = func() int {
-				infh, r, _, err = inStream(file)
+			func() {
+				reader, r, err = newBinaryReader(file)
 				checkError(err)
 				defer r.Close()
 
-				if len(files) == 1 {
-					reader, err = unikmer.NewReader(infh)
-					checkError(err)
-
-					k = reader.K
-
-					if !isStdout(outFile) {
-						outFile += extDataFile
-					}
-					outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-					checkError(err)
-					defer func() {
-						outfh.Flush()
-						if gw != nil {
-							gw.Close()
-						}
-						w.Close()
-					}()
-
-					var writer *unikmer.Writer
-					var m2 []uint64
-
-					if sortKmers {
-						m2 = make([]uint64, 0, mapInitSize)
-					} else {
-						var mode uint32
-						if opt.Compact {
-							mode |= unikmer.UNIK_COMPACT
-						}
-						if reader.Flag&unikmer.UNIK_CANONICAL > 0 {
-							mode |= unikmer.UNIK_CANONICAL
-						}
-						if sortKmers {
-							mode |= unikmer.UNIK_SORTED
-						}
-						writer, err = unikmer.NewWriter(outfh, reader.K, mode)
-						checkError(err)
-					}
-
-					m := make(map[uint64]struct{}, mapInitSize)
-					for {
-						kcode, err = reader.Read()
-						if err != nil {
-							if err == io.EOF {
-								break
-							}
-							checkError(err)
-						}
-
-						if _, ok = m[kcode.Code]; !ok {
-							m[kcode.Code] = struct{}{}
-							if sortKmers {
-								m2 = append(m2, kcode.Code)
-							} else {
-								writer.Write(kcode) // not need to check er
-							}
-						}
-					}
-
-					if sortKmers {
-						var mode uint32
-						if opt.Compact {
-							mode |= unikmer.UNIK_COMPACT
-						}
-						if reader.Flag&unikmer.UNIK_CANONICAL > 0 {
-							mode |= unikmer.UNIK_CANONICAL
-						}
-						mode |= unikmer.UNIK_SORTED
-						writer, err = unikmer.NewWriter(outfh, reader.K, mode)
-						checkError(err)
-
-						writer.Number = int64(len(m2))
-
-						if opt.Verbose {
-							log.Infof("sorting %d k-mers", len(m2))
-						}
-						sort.Sort(unikmer.CodeSlice(m2))
-						if opt.Verbose {
-							log.Infof("done sorting")
-						}
-
-						for _, code := range m2 {
-							writer.Write(unikmer.KmerCode{Code: code, K: k})
-						}
-					}
-
-					checkError(writer.Flush())
-					if opt.Verbose {
-						log.Infof("%d k-mers saved", len(m))
-					}
-					return flagReturn
-				}
-
-				reader, err = unikmer.NewReader(infh)
-				checkError(err)
-
 				if k == -1 {
 					k = reader.K
 					canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+					protein = reader.Flag&unikmer.UNIK_PROTEIN > 0
+					header = reader.Header
+					m = newKmerSet(k, protein)
 				} else if k != reader.K {
 					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
 				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
 					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				} else {
+					checkKmerScheme(file, reader.Header, header)
 				}
 
+				if !firstFile {
+					seen = newKmerSet(k, protein)
+				}
 				for {
 					kcode, err = reader.Read()
 					if err != nil {
@@ -202,53 +123,32 @@ var interCmd = &cobra.Command{
 					}
 
 					if firstFile {
-						m[kcode.Code] = false
+						m.Add(kcode.Code)
 						continue
 					}
 
-					// mark seen kmer
-					if _, ok = m[kcode.Code]; ok {
-						m[kcode.Code] = true
+					// keep seen kmer
+					if m.Contains(kcode.Code) {
+						seen.Add(kcode.Code)
 					}
 				}
-
-				if firstFile {
-					firstFile = false
-					return flagContinue
-				}
-
-				// remove unseen kmers
-				for code = range m {
-					if m[code] {
-						m[code] = false
-					} else {
-						delete(m, code)
-					}
-				}
-
-				if opt.Verbose {
-					log.Infof("%d k-mers remain", len(m))
-				}
-				if len(m) == 0 {
-					hasInter = false
-					return flagBreak
-				}
-
-				return flagContinue
 			}()
 
-			if flag == flagReturn {
-				return
-			} else if flag == flagBreak {
-				break
+			if firstFile {
+				firstFile = false
+				continue
 			}
-		}
+			m = seen
 
-		if !hasInter {
 			if opt.Verbose {
-				log.Infof("no intersection found")
+				log.Infof("%d k-mers remain", m.Len())
+			}
+			if m.Len() == 0 {
+				if opt.Verbose {
+					log.Infof("no intersection found")
+				}
+				break
 			}
-			// return
 		}
 
 		// output
@@ -256,76 +156,73 @@ var interCmd = &cobra.Command{
 		if opt.Verbose {
 			log.Infof("exporting k-mers")
 		}
+		writeKmerSet(opt, outFile, header, sortKmers, m)
+	},
+}
 
-		if !isStdout(outFile) {
-			outFile += extDataFile
-		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-		checkError(err)
-		defer func() {
-			outfh.Flush()
-			if gw != nil {
-				gw.Close()
-			}
-			w.Close()
-		}()
+func init() {
+	RootCmd.AddCommand(interCmd)
 
-		var mode uint32
-		if opt.Compact {
-			mode |= unikmer.UNIK_COMPACT
-		}
-		if canonical {
-			mode |= unikmer.UNIK_CANONICAL
-		}
-		if sortKmers {
-			mode |= unikmer.UNIK_SORTED
-		}
+	interCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	interCmd.Flags().BoolP("sort", "s", false, helpSort)
+}
 
-		writer, err := unikmer.NewWriter(outfh, k, mode)
-		checkError(err)
+// interKmers2 computes intersection of binary files with K > 32.
+func interKmers2(opt *Options, files []string, outFile string, sortKmers bool) {
+	m := make(map[[2]uint64]bool, mapInitSize)
 
-		if sortKmers {
-			writer.Number = int64(len(m))
+	var k int = -1
+	var canonical bool
+	var header *unikmer.Header
+	var ok bool
+	var nfiles = len(files)
+	for i, file := range files {
+		if opt.Verbose {
+			log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
 		}
 
-		if len(m) == 0 {
-			writer.Number = 0
-			checkError(writer.WriteHeader())
-		} else {
-			if sortKmers {
-				codes := make([]uint64, len(m))
-				i := 0
-				for code := range m {
-					codes[i] = code
-					i++
-				}
-				if opt.Verbose {
-					log.Infof("sorting %d k-mers", len(codes))
-				}
-				sort.Sort(unikmer.CodeSlice(codes))
-				if opt.Verbose {
-					log.Infof("done sorting")
-				}
-				for _, code := range codes {
-					writer.Write(unikmer.KmerCode{Code: code, K: k})
-				}
+		if i == 0 {
+			header = readKmers2(file, k, canonical, func(kcode unikmer.KmerCode2) {
+				m[kcode.Code] = false
+			})
+			k = header.K
+			canonical = header.Flag&unikmer.UNIK_CANONICAL > 0
+			continue
+		}
+
+		// mark seen kmer
+		readKmers2(file, k, canonical, func(kcode unikmer.KmerCode2) {
+			if _, ok = m[kcode.Code]; ok {
+				m[kcode.Code] = true
+			}
+		})
+
+		// remove unseen kmers
+		for code, seen := range m {
+			if seen {
+				m[code] = false
 			} else {
-				for code = range m {
-					// not need to check err
-					writer.Write(unikmer.KmerCode{Code: code, K: k})
-				}
+				delete(m, code)
 			}
 		}
-		checkError(writer.Flush())
+
 		if opt.Verbose {
-			log.Infof("%d k-mers saved", len(m))
+			log.Infof("%d k-mers remain", len(m))
 		}
-	},
-}
-
-func init() {
-	RootCmd.AddCommand(interCmd)
-
-	interCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
-	interCmd.Flags().BoolP("sort", "s", false, helpSort)
+		if len(m) == 0 {
+			if opt.Verbose {
+				log.Infof("no intersection found")
+			}
+			break
+		}
+	}
+
+	codes := make([][2]uint64, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+	if sortKmers {
+		sortCodes2(opt, codes)
+	}
+	writeKmers2(opt, outFile, k, canonical, sortKmers, codes)
 }
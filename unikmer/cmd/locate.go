@@ -27,8 +27,8 @@ import (
 	"os"
 	"runtime"
 	"sort"
-	"strings"
 
+	"github.com/cznic/sortutil"
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fastx"
 	"github.com/shenwei356/unikmer"
@@ -41,8 +41,28 @@ var locateCmd = &cobra.Command{
 	Short: "locate k-mers in genome",
 	Long: `locate k-mers in genome
 
+Output format:
+  1. Tab-delimited table, one location per line, with columns:
+       kmer, seqid, pos, strand
+     Position is 1-based.
+  2. BED6 format (-B/--bed), with columns:
+       seqid, start, end, kmer, score (0), strand
+     Start is 0-based and end is exclusive.
+  Strand is "+" if the k-mer is found in the positive strand of sequence,
+  otherwise "-". K-mers from binary files with 'canonical' flag on are
+  regarded as from the positive strand. Palindromic k-mers, which are equal
+  to their reverse complements, are reported in both strands.
+  For circular genomes (--circular), a k-mer spanning the end and the start
+  of a sequence is split into two BED records, one ending at the end of the
+  sequence and the other starting at the start of the sequence.
+
 Attention:
-  1. output location is 1-based
+  1. K-mers containing bases other than A/C/G/T/U (e.g., N) are skipped.
+  2. Locations of all k-mers in genome are kept in RAM, where sequence IDs
+     are saved only once.
+  3. For spaced k-mers (created with "unikmer count --spaced-seed"), windows
+     matching the spaced k-mers are reported, bases at don't-care positions
+     are shown as 'N'.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -68,6 +88,7 @@ Attention:
 
 		outFile := getFlagString(cmd, "out-prefix")
 		circular := getFlagBool(cmd, "circular")
+		bed := getFlagBool(cmd, "bed")
 
 		genomeFile := getFlagNonEmptyString(cmd, "genome")
 		checkFiles("", genomeFile)
@@ -76,6 +97,7 @@ Attention:
 
 		var k int = -1
 		var canonical bool
+		var header unikmer.Header
 
 		var infh *bufio.Reader
 		var r *os.File
@@ -95,10 +117,15 @@ Attention:
 
 				reader, err = unikmer.NewReader(infh)
 				checkError(err)
+				if reader.K > 32 {
+					checkError(fmt.Errorf("k > 32 not supported: %s", file))
+				}
+				checkDNA(file, reader.Header)
 
 				if k == -1 {
 					k = reader.K
 					canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+					header = reader.Header
 					if opt.Verbose {
 						if canonical {
 							log.Infof("flag of canonical is on")
@@ -110,22 +137,40 @@ Attention:
 					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
 				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
 					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				} else {
+					checkKmerScheme(file, reader.Header, header)
 				}
 			}()
 		}
 
+		// spaced k-mers are located with windows of the span of the seed
+		var seed *unikmer.SpacedSeed
+		span := k
+		if header.Flag&unikmer.UNIK_SPACED > 0 {
+			seed, err = unikmer.NewSpacedSeed(header.SpacedSeed)
+			checkError(err)
+			span = seed.Span
+			if opt.Verbose {
+				log.Infof("spaced seed: %s", seed)
+			}
+		}
+
 		// -----------------------------------------------------------------------
 
-		m := make(map[uint64][]int, mapInitSize)
+		// locations of canonical k-mers, sequence IDs are interned and saved once.
+		m := make(map[uint64][]uint64, mapInitSize)
+		seqIDs := make([]string, 0, 8)
+		seqLens := make([]int, 0, 8)
 
-		var sequence, kmer, preKmer []byte
-		var originalLen, l, end, e int
 		var record *fastx.Record
 		var fastxReader *fastx.Reader
-		var kcode, preKcode unikmer.KmerCode
-		var first bool
-		var i int
+		var iter *unikmer.KmerIterator
+		var siter *unikmer.SpacedKmerIterator
+		var kcode unikmer.KmerCode
+		var code, rcode, loc uint64
+		var seqIdx uint64
 		var ok bool
+		var nSkipped int
 		if opt.Verbose {
 			log.Infof("reading genome file: %s", genomeFile)
 		}
@@ -141,61 +186,73 @@ Attention:
 				break
 			}
 
-			sequence = record.Seq.Seq
-
 			if opt.Verbose {
 				log.Infof("processing sequence: %s", record.ID)
 			}
-
-			originalLen = len(record.Seq.Seq)
-			l = len(sequence)
-
-			end = l - 1
-			if end < 0 {
-				end = 0
+			if len(seqIDs) > locMaxSeqs {
+				checkError(fmt.Errorf("too many sequences (> %d) in genome file: %s", locMaxSeqs, genomeFile))
 			}
-			first = true
-			for i = 0; i <= end; i++ {
-				e = i + k
-				if e > originalLen {
-					if circular {
-						e = e - originalLen
-						kmer = sequence[i:]
-						kmer = append(kmer, sequence[0:e]...)
-					} else {
+			if len(record.Seq.Seq) > locMaxPos {
+				checkError(fmt.Errorf("sequence too long (> %d bp): %s", locMaxPos, record.ID))
+			}
+			seqIdx = uint64(len(seqIDs))
+			seqIDs = append(seqIDs, string(record.ID))
+			seqLens = append(seqLens, len(record.Seq.Seq))
+
+			// spaced k-mers of both strands are saved, as the reverse complement
+			// of a spaced k-mer is not the spaced k-mer of the reverse complement
+			// window for asymmetric seeds.
+			if seed != nil {
+				siter, err = unikmer.NewSpacedKmerIterator(record.Seq.Seq, seed, false, circular)
+				checkError(err)
+				for {
+					_, ok = siter.Next()
+					if !ok {
 						break
 					}
-				} else {
-					kmer = sequence[i : i+k]
+					code, rcode = siter.Codes()
+					m[code] = append(m[code], packLoc(seqIdx, siter.Index(), false))
+					m[rcode] = append(m[rcode], packLoc(seqIdx, siter.Index(), true))
 				}
+				nSkipped += siter.Skipped()
+				continue
+			}
 
-				if first {
-					kcode, err = unikmer.NewKmerCode(kmer)
-					first = false
-				} else {
-					kcode, err = unikmer.NewKmerCodeMustFromFormerOne(kmer, preKmer, preKcode)
-				}
-				if err != nil {
-					checkError(fmt.Errorf("fail to encode '%s': %s", kmer, err))
-				}
-				preKmer, preKcode = kmer, kcode
+			iter, err = unikmer.NewKmerIterator(record.Seq.Seq, k, false, circular)
+			checkError(err)
 
-				kcode = kcode.Canonical()
+			for {
+				kcode, ok = iter.Next()
+				if !ok {
+					break
+				}
 
-				if _, ok = m[kcode.Code]; !ok {
-					m[kcode.Code] = make([]int, 0, 1)
+				code = kcode.Code
+				rcode = kcode.RevComp().Code
+				if rcode < code {
+					code = rcode
+					loc = packLoc(seqIdx, iter.Index(), true)
+				} else {
+					loc = packLoc(seqIdx, iter.Index(), false)
 				}
 
-				m[kcode.Code] = append(m[kcode.Code], i)
+				m[code] = append(m[code], loc)
+				if rcode == code { // palindromic k-mer, found in both strands
+					m[code] = append(m[code], packLoc(seqIdx, iter.Index(), true))
+				}
 			}
+			nSkipped += iter.Skipped()
 		}
 		if opt.Verbose {
 			log.Infof("finished reading genome file: %s", genomeFile)
+			if nSkipped > 0 {
+				log.Infof("%d k-mers containing non-ACGT bases skipped", nSkipped)
+			}
 		}
 
 		// -----------------------------------------------------------------------
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -205,9 +262,15 @@ Attention:
 			w.Close()
 		}()
 
-		var locs, locs2, locs3 []int
-		var loc int
-		var ok2 bool
+		if !bed {
+			outfh.WriteString("kmer\tseqid\tpos\tstrand\n")
+		}
+
+		var locs sortutil.Uint64Slice
+		var mer string
+		var pos, end, seqLen int
+		var rc, queryRC bool
+		var strand byte
 		for i, file := range files {
 			if isStdin(file) {
 				log.Warningf("ignoring stdin")
@@ -223,58 +286,56 @@ Attention:
 				reader, err = unikmer.NewReader(infh)
 				checkError(err)
 
-				if !canonical {
-					for {
-						kcode, err = reader.Read()
-						if err != nil {
-							if err == io.EOF {
-								break
-							}
-							checkError(err)
+				for {
+					kcode, err = reader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
 						}
+						checkError(err)
+					}
 
-						locs, ok = m[kcode.Code]
-						locs2, ok2 = m[kcode.RevComp().Code]
-						if ok {
-							locs3 = locs
-							if ok2 {
-								locs3 = append(locs3, locs2...)
-							}
-						} else {
-							if ok2 {
-								locs3 = locs2
-							} else {
-								continue
-							}
-						}
-						locs = uniqInts(locs3)
-						sort.Ints(locs)
-						outfh.WriteString(kcode.String() + "\t")
-						outfh.WriteString(fmt.Sprintf("%d", locs[0]+1))
-						for _, loc = range locs[1:] {
-							outfh.WriteString(fmt.Sprintf(",%d", loc+1))
+					// the query k-mer is the reverse complement of the canonical one
+					queryRC = false
+					code = kcode.Code
+					if !canonical && seed == nil {
+						rcode = kcode.RevComp().Code
+						if rcode < code {
+							code = rcode
+							queryRC = true
 						}
-						outfh.WriteString("\n")
 					}
-				} else {
-					for {
-						kcode, err = reader.Read()
-						if err != nil {
-							if err == io.EOF {
-								break
-							}
-							checkError(err)
-						}
 
-						if locs, ok = m[kcode.Code]; ok {
-							// locs = uniqInts(locs)
-							sort.Ints(locs)
-							outfh.WriteString(kcode.String() + "\t")
-							outfh.WriteString(fmt.Sprintf("%d", locs[0]+1))
-							for _, loc = range locs[1:] {
-								outfh.WriteString(fmt.Sprintf(",%d", loc+1))
+					if locs, ok = m[code]; !ok {
+						continue
+					}
+					sort.Sort(locs)
+
+					if seed != nil {
+						mer = string(seed.Expand(code))
+					} else {
+						mer = kcode.String()
+					}
+					for _, loc = range locs {
+						seqIdx, pos, rc = unpackLoc(loc)
+						if rc == queryRC {
+							strand = '+'
+						} else {
+							strand = '-'
+						}
+						if bed {
+							end = pos + span
+							// k-mers across the end of circular sequences are split
+							if seqLen = seqLens[seqIdx]; end > seqLen {
+								outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%s\t0\t%c\n",
+									seqIDs[seqIdx], pos, seqLen, mer, strand))
+								pos, end = 0, end-seqLen
 							}
-							outfh.WriteString("\n")
+							outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%s\t0\t%c\n",
+								seqIDs[seqIdx], pos, end, mer, strand))
+						} else {
+							outfh.WriteString(fmt.Sprintf("%s\t%s\t%d\t%c\n",
+								mer, seqIDs[seqIdx], pos+1, strand))
 						}
 					}
 				}
@@ -283,10 +344,32 @@ Attention:
 	},
 }
 
+// A location of k-mer is packed in an uint64:
+// 31 bits for index of sequence, 32 bits for position, and 1 bit for strand.
+const (
+	locMaxSeqs = 1<<31 - 1
+	locMaxPos  = 1<<32 - 1
+)
+
+// packLoc packs the index of sequence, 0-based position and the strand
+// (rc is true if the canonical k-mer is from the negative strand).
+func packLoc(seqIdx uint64, pos int, rc bool) uint64 {
+	loc := seqIdx<<33 | uint64(pos)<<1
+	if rc {
+		loc |= 1
+	}
+	return loc
+}
+
+func unpackLoc(loc uint64) (uint64, int, bool) {
+	return loc >> 33, int(loc >> 1 & locMaxPos), loc&1 > 0
+}
+
 func init() {
 	RootCmd.AddCommand(locateCmd)
 
 	locateCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 	locateCmd.Flags().BoolP("circular", "", false, "circular genome")
 	locateCmd.Flags().StringP("genome", "g", "", "genome in (gzipped) fasta file")
+	locateCmd.Flags().BoolP("bed", "B", false, "output in BED6 format")
 }
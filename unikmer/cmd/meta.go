@@ -0,0 +1,203 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// metaCmd represents
+var metaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "view and edit metadata of binary files",
+	Long: `view and edit metadata of binary files
+
+Metadata are key-value pairs saved in the header of binary file,
+including version of unikmer, command line, source files, creation time
+and some options, which are automatically filled by commands writing
+binary files.
+
+Without -s/--set or -d/--delete, metadata of all files are shown in
+tab-delimited format (file, key, value). Otherwise, metadata of the only
+input file are edited and saved to a new file, k-mers are not changed.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		var files []string
+		infileList := getFlagString(cmd, "infile-list")
+		if infileList != "" {
+			files, err = getListFromFile(infileList)
+			checkError(err)
+		} else {
+			files = getFileList(args)
+		}
+
+		checkFiles(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-prefix")
+		sets := getFlagStringArray(cmd, "set")
+		deletes := getFlagStringSlice(cmd, "delete")
+
+		edits := make(map[string]string, len(sets))
+		for _, item := range sets {
+			i := strings.Index(item, "=")
+			if i <= 0 {
+				checkError(fmt.Errorf("invalid value of -s/--set: %s, format: key=value", item))
+			}
+			edits[item[:i]] = item[i+1:]
+		}
+
+		if len(edits) == 0 && len(deletes) == 0 {
+			viewMeta(opt, files, outFile)
+			return
+		}
+
+		if len(files) > 1 {
+			checkError(fmt.Errorf("only one input file allowed for editing metadata"))
+		}
+		file := files[0]
+
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+
+		meta := reader.Meta
+		if meta == nil {
+			meta = make(map[string]string, len(edits))
+		}
+		for _, key := range deletes {
+			delete(meta, key)
+		}
+		for key, value := range edits {
+			meta[key] = value
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		// offsets in index are of uncompressed file
+		outfh, gw, w, err := outStream(outFile, opt.Compress && reader.Flag&unikmer.UNIK_INDEXED == 0, opt.CompressionLevel, opt.Codec)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		writer, err := unikmer.NewWriter(outfh, reader.K, reader.Flag)
+		checkError(err)
+		writer.Number = reader.Number
+		writer.SketchSize = reader.SketchSize
+		writer.SketchScale = reader.SketchScale
+		setKmerScheme(&writer.Header, reader.Header)
+		writer.Meta = meta
+		checkError(writer.WriteHeader())
+
+		var kcode unikmer.KmerCode
+		var kcode2 unikmer.KmerCode2
+		var count uint32
+		var n int64
+		for {
+			if reader.K > 32 {
+				kcode2, count, err = reader.ReadWithCount2()
+			} else {
+				kcode, count, err = reader.ReadWithCount()
+			}
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+
+			if reader.K > 32 {
+				checkError(writer.WriteWithCount2(kcode2, count))
+			} else {
+				checkError(writer.WriteWithCount(kcode, count))
+			}
+			n++
+		}
+		checkError(writer.Flush())
+
+		if opt.Verbose {
+			log.Infof("%d k-mers saved", n)
+		}
+	},
+}
+
+// viewMeta outputs metadata of binary files in tab-delimited format.
+func viewMeta(opt *Options, files []string, outFile string) {
+	outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
+	checkError(err)
+	defer func() {
+		outfh.Flush()
+		if gw != nil {
+			gw.Close()
+		}
+		w.Close()
+	}()
+
+	outfh.WriteString("file\tkey\tvalue\n")
+	for _, file := range files {
+		func() {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			reader, err := unikmer.NewReader(infh)
+			checkError(err)
+
+			keys := make([]string, 0, len(reader.Meta))
+			for key := range reader.Meta {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\n", file, key, reader.Meta[key]))
+			}
+		}()
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(metaCmd)
+
+	metaCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout), for viewing, it's the out file`)
+	metaCmd.Flags().StringArrayP("set", "s", []string{}, `set metadata in format of key=value, could be given multiple times`)
+	metaCmd.Flags().StringSliceP("delete", "d", []string{}, `delete metadata of given keys, multiple values supported`)
+}
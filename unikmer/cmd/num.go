@@ -25,7 +25,6 @@ import (
 	"fmt"
 	"os"
 	"runtime"
-	"strings"
 
 	"github.com/shenwei356/unikmer"
 	"github.com/spf13/cobra"
@@ -39,7 +38,11 @@ var numCmd = &cobra.Command{
 
 Attention:
   - This command is designed to quickly inspect the number of k-mers in binary file,
-  - For non-sorted file, it returns '-1'. You can use 'unikmer stats -a' for these files.
+  - For indexed file (created with global flag --index), the number is read from
+    the block index at the end of file.
+  - For uncompressed file (created with global flag -C/--no-compress), the number
+    is computed from the file size.
+  - For other non-sorted file, it returns '-1'. You can use 'unikmer stats -a' for these files.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -61,7 +64,7 @@ Attention:
 		outFile := getFlagString(cmd, "out-file")
 		showFile := getFlagBool(cmd, "file-name")
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -73,21 +76,34 @@ Attention:
 
 		var infh *bufio.Reader
 		var r *os.File
+		var compressed bool
 		var reader *unikmer.Reader
+		var n int64
 
 		for _, file := range files {
 			func() {
-				infh, r, _, err = inStream(file)
+				infh, r, compressed, err = inStream(file)
 				checkError(err)
 				defer r.Close()
 
 				reader, err = unikmer.NewReader(infh)
 				checkError(err)
 
+				n = reader.Number
+				if n < 0 && !isStdin(file) && !compressed {
+					if reader.Flag&unikmer.UNIK_INDEXED > 0 {
+						n, err = numFromIndex(file)
+						checkError(err)
+					} else if mm, err := unikmer.NewMmapReader(file); err == nil {
+						n = int64(mm.Len())
+						mm.Close()
+					}
+				}
+
 				if showFile {
-					outfh.WriteString(fmt.Sprintf("%d\t%s\n", reader.Number, file))
+					outfh.WriteString(fmt.Sprintf("%d\t%s\n", n, file))
 				} else {
-					outfh.WriteString(fmt.Sprintf("%d\n", reader.Number))
+					outfh.WriteString(fmt.Sprintf("%d\n", n))
 				}
 
 			}()
@@ -95,9 +111,28 @@ Attention:
 	},
 }
 
+// numFromIndex reads the number of k-mers from the block index of an indexed file.
+func numFromIndex(file string) (int64, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	reader, err := unikmer.NewReader(fh)
+	if err != nil {
+		return 0, err
+	}
+	index, err := reader.Index()
+	if err != nil {
+		return 0, err
+	}
+	return index.Number, nil
+}
+
 func init() {
 	RootCmd.AddCommand(numCmd)
 
-	numCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	numCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
 	numCmd.Flags().BoolP("file-name", "n", false, `show file name`)
 }
@@ -40,7 +40,8 @@ convertion, set operations and searching on unique small k-mers (k <= 32)
 without frequency information.
 
 K-mers (k <= 32) are encoded into 'uint64', stored in builtin 'map' of golang
-in RAM, and serialized in binary format.
+in RAM, and serialized in binary format. Longer k-mers (32 < k <= 64) are
+encoded into two 'uint64' words.
 
 Version: v%s
 
@@ -71,8 +72,10 @@ func init() {
 	RootCmd.PersistentFlags().IntP("threads", "j", defaultThreads, "number of CPUs to use. (default value: 1 for single-CPU PC, 2 for others)")
 	RootCmd.PersistentFlags().BoolP("verbose", "", false, "print verbose information")
 	RootCmd.PersistentFlags().BoolP("no-compress", "C", false, "do not compress binary file (not recommended)")
-	RootCmd.PersistentFlags().IntP("compression-level", "L", flate.DefaultCompression, "compression level")
+	RootCmd.PersistentFlags().IntP("compression-level", "L", flate.DefaultCompression, "compression level (gzip: 1-9, zstd: 1-22)")
+	RootCmd.PersistentFlags().StringP("codec", "", codecGzip, `compression codec of binary files, "gzip" or "zstd". Out files with suffix ".gz" or ".zst" are compressed with the corresponding codec`)
 	RootCmd.PersistentFlags().BoolP("compact", "c", false, "write more compact binary file with little loss of speed")
+	RootCmd.PersistentFlags().BoolP("index", "", false, "write binary file with block index for random access and quick counting. The file is not compressed as offsets of blocks are of uncompressed data, sorting k-mers (-s/--sort) helps reduce the size")
 	RootCmd.PersistentFlags().StringP("infile-list", "i", "", "file of input files list (one file per line), if given, files from cli arguments are ignored")
 }
 
@@ -21,10 +21,8 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"runtime"
 
 	"github.com/shenwei356/unikmer"
@@ -72,7 +70,7 @@ Attentions:
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -84,10 +82,11 @@ Attentions:
 
 		var writer *unikmer.Writer
 
-		var infh *bufio.Reader
-		var r *os.File
+		var r io.Closer
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
+		var kcode2 unikmer.KmerCode2
+		var count uint32
 		var k int = -1
 		var canonical bool
 		var flag int
@@ -100,27 +99,55 @@ Attentions:
 			}
 
 			flag = func() int {
-				infh, r, _, err = inStream(file)
+				reader, r, err = newBinaryReader(file)
 				checkError(err)
 				defer r.Close()
 
-				reader, err = unikmer.NewReader(infh)
-				checkError(err)
-
 				if k == -1 {
 					k = reader.K
 					writer, err = unikmer.NewWriter(outfh, k, reader.Flag)
 					checkError(err)
+					writer.Meta = opt.newMeta()
+					setKmerScheme(&writer.Header, reader.Header)
 				} else if k != reader.K {
 					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
 				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
 					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				} else {
+					checkKmerScheme(file, reader.Header, writer.Header)
 				}
 
-				if sampling {
+				if mm, ok := r.(*unikmer.MmapReader); ok && sampling {
+					// random access of memory-mapped file
+					for j = (start - 1) % window; j < mm.Len(); j += window {
+						n++
+						if k > 32 {
+							writer.Write2(mm.At2(j)) // not need to check err
+						} else {
+							writer.Write(mm.At(j)) // not need to check err
+						}
+					}
+				} else if k > 32 {
+					j = 0
+					for {
+						kcode2, count, err = reader.ReadWithCount2()
+						if err != nil {
+							if err == io.EOF {
+								break
+							}
+							checkError(err)
+						}
+
+						j++
+						if !sampling || (j-start)%window == 0 || j == start {
+							n++
+							writer.WriteWithCount2(kcode2, count) // not need to check err
+						}
+					}
+				} else if sampling {
 					j = 0
 					for {
-						kcode, err = reader.Read()
+						kcode, count, err = reader.ReadWithCount()
 						if err != nil {
 							if err == io.EOF {
 								break
@@ -131,13 +158,13 @@ Attentions:
 						j++
 						if (j-start)%window == 0 || j == start {
 							n++
-							writer.Write(kcode) // not need to check err
+							writer.WriteWithCount(kcode, count) // not need to check err
 						}
 					}
 
 				} else {
 					for {
-						kcode, err = reader.Read()
+						kcode, count, err = reader.ReadWithCount()
 						if err != nil {
 							if err == io.EOF {
 								break
@@ -146,7 +173,7 @@ Attentions:
 						}
 
 						n++
-						writer.Write(kcode) // not need to check err
+						writer.WriteWithCount(kcode, count) // not need to check err
 					}
 
 				}
@@ -0,0 +1,229 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/pflag"
+)
+
+// runCmd runs a command with arguments in-process. Flags changed by
+// previous runs are reset to default values in advance.
+func runCmd(t *testing.T, args ...string) {
+	cmd, _, err := RootCmd.Find(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset := func(f *pflag.Flag) {
+		if f.Changed {
+			f.Value.Set(f.DefValue)
+			f.Changed = false
+		}
+	}
+	RootCmd.PersistentFlags().VisitAll(reset)
+	cmd.Flags().VisitAll(reset)
+
+	RootCmd.SetArgs(args)
+	if err = RootCmd.Execute(); err != nil {
+		t.Fatalf("%v: %s", args, err)
+	}
+}
+
+func randSeq(r *rand.Rand, n int) []byte {
+	s := make([]byte, n)
+	for i := range s {
+		s[i] = "ACGT"[r.Intn(4)]
+	}
+	return s
+}
+
+func writeFasta(file string, seq []byte) error {
+	return ioutil.WriteFile(file, []byte(fmt.Sprintf(">%s\n%s\n", filepath.Base(file), seq)), 0644)
+}
+
+// genSeqs returns three sequences sharing some regions,
+// so that all set operations have non-empty results.
+func genSeqs() [][]byte {
+	r := rand.New(rand.NewSource(11))
+	common, ab, bc := randSeq(r, 300), randSeq(r, 300), randSeq(r, 300)
+	cat := func(parts ...[]byte) []byte {
+		var s []byte
+		for _, p := range parts {
+			s = append(s, p...)
+			s = append(s, randSeq(r, 200)...)
+		}
+		return s
+	}
+	return [][]byte{
+		cat(common, ab),
+		cat(ab, common, bc),
+		cat(bc, common),
+	}
+}
+
+func kmers2(t *testing.T, seq []byte, k int) map[[2]uint64]struct{} {
+	iter, err := unikmer.NewKmerIterator2(seq, k, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[[2]uint64]struct{})
+	for {
+		kcode, ok := iter.Next()
+		if !ok {
+			break
+		}
+		m[kcode.Code] = struct{}{}
+	}
+	return m
+}
+
+func readSet2(t *testing.T, file string) (map[[2]uint64]struct{}, unikmer.Header) {
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	if err != nil {
+		t.Fatalf("%s: %s", file, err)
+	}
+	m := make(map[[2]uint64]struct{})
+	var kcode unikmer.KmerCode2
+	for {
+		kcode, err = reader.Read2()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("%s: %s", file, err)
+		}
+		if _, ok := m[kcode.Code]; ok {
+			t.Errorf("%s: duplicated k-mer: %s", file, kcode)
+		}
+		m[kcode.Code] = struct{}{}
+	}
+	return m, reader.Header
+}
+
+func checkSet2(t *testing.T, name string, file string, sorted bool, expected map[[2]uint64]struct{}) {
+	m, h := readSet2(t, file)
+	if sorted && h.Flag&unikmer.UNIK_SORTED == 0 {
+		t.Errorf("%s: k-mers are not sorted", name)
+	}
+	if len(m) != len(expected) {
+		t.Errorf("%s: number of k-mers mismatch: %d vs %d", name, len(m), len(expected))
+		return
+	}
+	for code := range expected {
+		if _, ok := m[code]; !ok {
+			t.Errorf("%s: k-mer missing: %s", name, unikmer.KmerCode2{Code: code, K: h.K})
+			return
+		}
+	}
+}
+
+func TestSetOperationsK41(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unikmer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	k := 41
+	seqs := genSeqs()
+	sets := make([]map[[2]uint64]struct{}, len(seqs))
+	for i, seq := range seqs {
+		sets[i] = kmers2(t, seq, k)
+	}
+
+	// expected results of files a, b, c
+	union := make(map[[2]uint64]struct{})
+	inter := make(map[[2]uint64]struct{})
+	diff := make(map[[2]uint64]struct{})
+	symDiff := make(map[[2]uint64]struct{})
+	for code := range sets[0] {
+		_, inB := sets[1][code]
+		_, inC := sets[2][code]
+		if inB && inC {
+			inter[code] = struct{}{}
+		}
+		if !inB && !inC {
+			diff[code] = struct{}{}
+		}
+	}
+	hits := make(map[[2]uint64]int)
+	for _, m := range sets {
+		for code := range m {
+			union[code] = struct{}{}
+			hits[code]++
+		}
+	}
+	for code, n := range hits {
+		if n == 1 {
+			symDiff[code] = struct{}{}
+		}
+	}
+	if len(inter) == 0 || len(diff) == 0 || len(symDiff) == 0 {
+		t.Fatalf("bad test data: inter: %d, diff: %d, symmetric diff: %d", len(inter), len(diff), len(symDiff))
+	}
+
+	for _, sorted := range []bool{false, true} {
+		s := fmt.Sprintf("-s=%v", sorted)
+
+		files := make([]string, len(seqs))
+		for i, seq := range seqs {
+			prefix := filepath.Join(dir, fmt.Sprintf("%c%v", 'a'+i, sorted))
+			if err = writeFasta(prefix+".fa", seq); err != nil {
+				t.Fatal(err)
+			}
+			runCmd(t, "count", "-k", fmt.Sprintf("%d", k), "-K", s, "-o", prefix, prefix+".fa")
+			files[i] = prefix + extDataFile
+			checkSet2(t, fmt.Sprintf("count (sorted: %v)", sorted), files[i], sorted, sets[i])
+		}
+
+		out := filepath.Join(dir, "out")
+		for _, c := range []struct {
+			name     string
+			args     []string
+			expected map[[2]uint64]struct{}
+		}{
+			{"union", []string{"union", s}, union},
+			{"inter", []string{"inter", s}, inter},
+			{"diff", []string{"diff", s}, diff},
+			{"diff -s", []string{"diff", s, "--symmetric"}, symDiff},
+		} {
+			args := append(c.args, "-o", out)
+			args = append(args, files...)
+			args = append(args, files[1]) // duplicated file is ignored
+			runCmd(t, args...)
+			checkSet2(t, fmt.Sprintf("%s (sorted: %v)", c.name, sorted), out+extDataFile, sorted, c.expected)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// sketchCmd represents
+var sketchCmd = &cobra.Command{
+	Use:   "sketch",
+	Short: "MinHash or FracMinHash sketch of k-mers",
+	Long: `MinHash or FracMinHash sketch of k-mers
+
+Sketch types:
+  1. bottom-s MinHash (-s/--size): k-mers with the s smallest hash values.
+  2. FracMinHash (-S/--scale): k-mers with hash values smaller than
+     1/scale of the max hash value.
+
+Input:
+  1. Binary files (.unik) by default.
+  2. FASTA/Q sequences if -k/--kmer-len or --spaced-seed is given,
+     k-mers are extracted in the same way as "unikmer count", e.g.,
+     minimizers (-W/--minimizer-window), syncmers (--syncmer-s),
+     spaced k-mers (--spaced-seed), and protein k-mers (--alphabet,
+     -t/--translate).
+
+Output:
+  The sketch is saved as a sorted binary file with the flag of sketch,
+  so it can be handled like other binary files. "unikmer dist" computes
+  estimated Jaccard index and Mash distance of sketches. For sketches with
+  k <= 32, "unikmer inter/union/diff" keep the sketch flag of FracMinHash
+  sketches, while only "unikmer union" is supported for bottom-s MinHash
+  sketches, and the union is truncated to the s k-mers with the smallest
+  hash values. Set operations of sketches with k > 32 are not supported.
+
+Attentions:
+  1. The 'canonical' flags of all binary files should be consistent.
+  2. Sketches with different types or parameters can not be compared.
+  3. The k-mer sampling, spaced seed and alphabet of all binary files
+     should be consistent, and they are saved in the sketch.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+		seq.ValidateSeq = false
+
+		var err error
+
+		var files []string
+		infileList := getFlagString(cmd, "infile-list")
+		if infileList != "" {
+			files, err = getListFromFile(infileList)
+			checkError(err)
+		} else {
+			files = getFileList(args)
+		}
+
+		outFile := getFlagString(cmd, "out-prefix")
+		size := getFlagNonNegativeInt(cmd, "size")
+		scale := getFlagNonNegativeInt(cmd, "scale")
+		if (size > 0) == (scale > 0) {
+			checkError(fmt.Errorf("one and only one of -s/--size and -S/--scale should be given"))
+		}
+
+		newSketch := func(k int) *unikmer.Sketch {
+			var sketch *unikmer.Sketch
+			if size > 0 {
+				sketch, err = unikmer.NewMinHashSketch(k, size)
+			} else {
+				sketch, err = unikmer.NewFracMinHashSketch(k, scale)
+			}
+			checkError(err)
+			return sketch
+		}
+
+		var sketch *unikmer.Sketch
+		var header unikmer.Header                                                                  // flags and parameters of k-mer scheme
+		var meta = make(map[string]string)                                                         // metadata of k-mer scheme
+		if getFlagNonNegativeInt(cmd, "kmer-len") > 0 || getFlagString(cmd, "spaced-seed") != "" { // from sequences
+			scheme := getKmerScheme(cmd)
+			checkFiles("", files...)
+			sketch = newSketch(scheme.k)
+			if scheme.k > 32 {
+				scheme.scanKmers2(opt, files, sketch.Add2)
+			} else {
+				scheme.scanKmers(opt, files, sketch.Add)
+			}
+			header = scheme.header()
+			scheme.setMeta(meta)
+		} else { // from binary files
+			checkFiles(extDataFile, files...)
+
+			var infh *bufio.Reader
+			var r *os.File
+			var reader *unikmer.Reader
+			var kcode unikmer.KmerCode
+			var kcode2 unikmer.KmerCode2
+			var k int
+			var nfiles = len(files)
+			for i, file := range files {
+				if opt.Verbose {
+					log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+				}
+
+				func() {
+					infh, r, _, err = inStream(file)
+					checkError(err)
+					defer r.Close()
+
+					reader, err = unikmer.NewReader(infh)
+					checkError(err)
+
+					if sketch == nil {
+						k = reader.K
+						header = reader.Header
+						sketch = newSketch(k)
+					} else {
+						if k != reader.K {
+							checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+						}
+						if reader.Flag&unikmer.UNIK_CANONICAL != header.Flag&unikmer.UNIK_CANONICAL {
+							checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+						}
+						checkKmerScheme(file, reader.Header, header)
+					}
+
+					for {
+						if k > 32 {
+							kcode2, err = reader.Read2()
+						} else {
+							kcode, err = reader.Read()
+						}
+						if err != nil {
+							if err == io.EOF {
+								break
+							}
+							checkError(err)
+						}
+
+						if k > 32 {
+							sketch.Add2(kcode2.Code)
+						} else {
+							sketch.Add(kcode.Code)
+						}
+					}
+				}()
+			}
+			meta["canonical"] = fmt.Sprintf("%v", header.Flag&unikmer.UNIK_CANONICAL > 0)
+		}
+
+		writeSketch(opt, outFile, sketch, header, meta)
+	},
+}
+
+// writeSketch writes k-mers of the sketch to a sorted binary file,
+// with the k-mer scheme in header h and metadata.
+func writeSketch(opt *Options, outFile string, sketch *unikmer.Sketch, h unikmer.Header, meta map[string]string) {
+	if !isStdout(outFile) {
+		outFile += extDataFile
+	}
+	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
+	checkError(err)
+	defer func() {
+		outfh.Flush()
+		if gw != nil {
+			gw.Close()
+		}
+		w.Close()
+	}()
+
+	mode := uint32(unikmer.UNIK_SORTED|unikmer.UNIK_SKETCH) | h.Flag&unikmer.UNIK_CANONICAL
+	if opt.Index {
+		mode |= unikmer.UNIK_INDEXED
+	}
+	writer, err := unikmer.NewWriter(outfh, sketch.K, mode)
+	checkError(err)
+	setKmerScheme(&writer.Header, h)
+	writer.Meta = opt.newMeta()
+	for key, value := range meta {
+		writer.Meta[key] = value
+	}
+	writer.SketchSize = sketch.Size
+	writer.SketchScale = sketch.Scale
+	writer.Number = int64(sketch.Len())
+
+	checkError(writer.WriteHeader())
+	if sketch.K > 32 {
+		for _, code := range sketch.Codes2() {
+			checkError(writer.Write2(unikmer.KmerCode2{Code: code, K: sketch.K}))
+		}
+	} else {
+		for _, code := range sketch.Codes() {
+			checkError(writer.Write(unikmer.KmerCode{Code: code, K: sketch.K}))
+		}
+	}
+	checkError(writer.Flush())
+	if opt.Verbose {
+		log.Infof("%d k-mers saved", sketch.Len())
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(sketchCmd)
+
+	sketchCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	sketchCmd.Flags().IntP("size", "s", 0, "sketch size of bottom-s MinHash")
+	sketchCmd.Flags().IntP("scale", "S", 0, "scale of FracMinHash")
+	sketchCmd.Flags().IntP("kmer-len", "k", 0, "k-mer length, if given, input files are FASTA/Q sequences")
+	sketchCmd.Flags().BoolP("canonical", "K", false, "only keep the canonical k-mers (for FASTA/Q input)")
+	sketchCmd.Flags().BoolP("circular", "", false, "circular genome (for FASTA/Q input)")
+	addKmerSchemeFlags(sketchCmd)
+}
@@ -26,7 +26,6 @@ import (
 	"io"
 	"os"
 	"runtime"
-	"sort"
 
 	"github.com/shenwei356/unikmer"
 	"github.com/spf13/cobra"
@@ -37,6 +36,13 @@ var sortCmd = &cobra.Command{
 	Use:   "sort",
 	Short: "sort k-mers in binary files to reduce file size",
 	Long: `sort k-mers in binary files to reduce file size
+
+Tips:
+  1. For big files (k <= 32), use --max-mem to limit the memory usage,
+     sorted chunks are saved to temporary files in --tmp-dir and then merged.
+  2. For dense k-mer sets of short k, e.g., k <= 15, use --succinct to save
+     unique k-mers in a bitmap or Elias–Fano encoding, which is much smaller.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -57,13 +63,37 @@ var sortCmd = &cobra.Command{
 
 		outFile := getFlagString(cmd, "out-prefix")
 		unique := getFlagBool(cmd, "unique")
+		succinct := getFlagBool(cmd, "succinct")
+		maxMem, tmpDir := getExternalSortFlags(cmd)
 
-		m := make([]uint64, 0, mapInitSize)
+		var k0 int
+		k0, err = peekK(files[0])
+		checkError(err)
+		if succinct {
+			if k0 > 32 {
+				checkError(fmt.Errorf("flag --succinct only supports k <= 32"))
+			}
+			if opt.Index {
+				checkError(fmt.Errorf("flag --succinct is not compatible with global flag --index"))
+			}
+			unique = true
+		}
+		if k0 > 32 {
+			sortKmers2(opt, files, outFile, unique)
+			return
+		}
+
+		var sorter *codeSorter
+		defer func() {
+			if sorter != nil {
+				sorter.Cleanup()
+			}
+		}()
 
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -81,8 +111,10 @@ var sortCmd = &cobra.Command{
 		var kcode unikmer.KmerCode
 		var k int = -1
 		var canonical bool
+		var header unikmer.Header
 		var firstFile = true
 		var flag int
+		var total int64
 		var nfiles = len(files)
 		for i, file := range files {
 			if !firstFile && file == files[0] {
@@ -104,21 +136,37 @@ var sortCmd = &cobra.Command{
 				if k == -1 {
 					k = reader.K
 					canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+					header = reader.Header
+					if succinct && header.Flag&unikmer.UNIK_PROTEIN > 0 {
+						checkError(fmt.Errorf("flag --succinct does not support protein k-mers"))
+					}
 
 					var mode uint32
 					if opt.Compact {
 						mode |= unikmer.UNIK_COMPACT
 					}
+					if opt.Index {
+						mode |= unikmer.UNIK_INDEXED
+					}
 					if canonical {
 						mode |= unikmer.UNIK_CANONICAL
 					}
 					mode |= unikmer.UNIK_SORTED
+					if succinct {
+						mode |= unikmer.UNIK_SUCCINCT
+					}
 					writer, err = unikmer.NewWriter(outfh, k, mode)
 					checkError(err)
+					writer.Meta = opt.newMeta()
+					setKmerScheme(&writer.Header, header)
+
+					sorter = newCodeSorter(opt, k, unique, maxMem, tmpDir)
 				} else if k != reader.K {
 					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
 				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
 					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				} else {
+					checkKmerScheme(file, reader.Header, header)
 				}
 
 				for {
@@ -130,8 +178,8 @@ var sortCmd = &cobra.Command{
 						checkError(err)
 					}
 
-					m = append(m, kcode.Code)
-
+					sorter.Add(kcode.Code)
+					total++
 				}
 
 				return flagContinue
@@ -144,33 +192,18 @@ var sortCmd = &cobra.Command{
 			}
 		}
 
-		if opt.Verbose {
-			log.Infof("sorting %d k-mers", len(m))
-		}
-		sort.Sort(unikmer.CodeSlice(m))
-		if opt.Verbose {
-			log.Infof("done sorting")
+		if !unique {
+			writer.Number = total
 		}
-
 		var n int
-		if unique {
-			var last uint64 = ^uint64(0)
-			for _, code := range m {
-				if code == last {
-					continue
-				}
-				last = code
-				n++
-				writer.Write(unikmer.KmerCode{Code: code, K: k})
-			}
-		} else {
-			writer.Number = int64(len(m))
-			for _, code := range m {
-				writer.Write(unikmer.KmerCode{Code: code, K: k})
-			}
-			n = len(m)
-		}
+		sorter.Iterate(func(code uint64) {
+			n++
+			writer.Write(unikmer.KmerCode{Code: code, K: k}) // not need to check err
+		})
 
+		if !succinct { // header of succinct file is written along with k-mers in Flush
+			checkError(writer.WriteHeader()) // in case of no k-mers
+		}
 		checkError(writer.Flush())
 		if opt.Verbose {
 			log.Infof("%d k-mers saved", n)
@@ -183,4 +216,49 @@ func init() {
 
 	sortCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 	sortCmd.Flags().BoolP("unique", "u", false, `remove duplicated k-mers`)
+	sortCmd.Flags().BoolP("succinct", "", false, `save unique k-mers in bitmap or Elias–Fano encoding (k <= 32), smaller for dense k-mer sets`)
+	addExternalSortFlags(sortCmd)
+}
+
+// sortKmers2 sorts k-mers of binary files with K > 32.
+func sortKmers2(opt *Options, files []string, outFile string, unique bool) {
+	codes := make([][2]uint64, 0, mapInitSize)
+
+	var k int = -1
+	var canonical bool
+	var header *unikmer.Header
+	var nfiles = len(files)
+	for i, file := range files {
+		if i > 0 && file == files[0] {
+			continue
+		}
+
+		if opt.Verbose {
+			log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+		}
+
+		header = readKmers2(file, k, canonical, func(kcode unikmer.KmerCode2) {
+			codes = append(codes, kcode.Code)
+		})
+		if k == -1 {
+			k = header.K
+			canonical = header.Flag&unikmer.UNIK_CANONICAL > 0
+		}
+	}
+
+	sortCodes2(opt, codes)
+
+	if unique && len(codes) > 0 {
+		j := 1
+		for _, code := range codes[1:] {
+			if code == codes[j-1] {
+				continue
+			}
+			codes[j] = code
+			j++
+		}
+		codes = codes[:j]
+	}
+
+	writeKmers2(opt, outFile, k, canonical, true, codes)
 }
@@ -68,6 +68,7 @@ Tips:
 		outFile := getFlagString(cmd, "out-file")
 		all := getFlagBool(cmd, "all")
 		tabular := getFlagBool(cmd, "tabular")
+		showMeta := getFlagBool(cmd, "meta")
 		skipErr := getFlagBool(cmd, "skip-err")
 		sTrue := getFlagString(cmd, "symbol-true")
 		sFalse := getFlagString(cmd, "symbol-false")
@@ -75,7 +76,7 @@ Tips:
 			checkError(fmt.Errorf("values of -/--symbol-true and -F/--symbol--false should be different"))
 		}
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -90,17 +91,48 @@ Tips:
 			colnames := []string{
 				"file",
 				"k",
-				"gzipped",
+				"compressed",
 				"compact",
 				"canonical",
 				"sorted",
+				"counted",
+				"sketch",
+				"sampling",
+				"seed",
+				"alphabet",
 			}
 			if all {
-				colnames = append(colnames, []string{"number"}...)
+				colnames = append(colnames, []string{"number", "total_count"}...)
+			}
+			if showMeta {
+				colnames = append(colnames, "meta")
 			}
 			outfh.WriteString(strings.Join(colnames, "\t") + "\n")
 		}
 
+		// write one record in tabular format
+		writeInfo := func(info statInfo) {
+			outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\t%s\t%s\t%s",
+				info.file,
+				info.k,
+				boolStr(sTrue, sFalse, info.compressed),
+				boolStr(sTrue, sFalse, info.compact),
+				boolStr(sTrue, sFalse, info.canonical),
+				boolStr(sTrue, sFalse, info.sorted),
+				boolStr(sTrue, sFalse, info.counted),
+				info.sketch,
+				info.sampling,
+				info.seed,
+				info.alphabet))
+			if all {
+				outfh.WriteString(fmt.Sprintf("\t%d\t%s", info.number, info.totalCountStr(false)))
+			}
+			if showMeta {
+				outfh.WriteString("\t" + info.meta)
+			}
+			outfh.WriteString("\n")
+		}
+
 		ch := make(chan statInfo, opt.NumCPUs)
 		statInfos := make([]statInfo, 0, 1000)
 
@@ -128,24 +160,7 @@ Tips:
 					if !tabular {
 						statInfos = append(statInfos, info)
 					} else {
-						if !all {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted)))
-						} else {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%d\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted),
-								info.number))
-						}
+						writeInfo(info)
 					}
 					id++
 				} else { // check bufferd result
@@ -154,24 +169,7 @@ Tips:
 							if !tabular {
 								statInfos = append(statInfos, info1)
 							} else {
-								if !all {
-									outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\n",
-										info1.file,
-										info1.k,
-										boolStr(sTrue, sFalse, info1.gzipped),
-										boolStr(sTrue, sFalse, info1.compact),
-										boolStr(sTrue, sFalse, info1.canonical),
-										boolStr(sTrue, sFalse, info.sorted)))
-								} else {
-									outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%d\n",
-										info1.file,
-										info1.k,
-										boolStr(sTrue, sFalse, info1.gzipped),
-										boolStr(sTrue, sFalse, info1.compact),
-										boolStr(sTrue, sFalse, info1.canonical),
-										boolStr(sTrue, sFalse, info.sorted),
-										info1.number))
-								}
+								writeInfo(info1)
 							}
 
 							delete(buf, info1.id)
@@ -197,24 +195,7 @@ Tips:
 					if !tabular {
 						statInfos = append(statInfos, info)
 					} else {
-						if !all {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted)))
-						} else {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%d\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted),
-								info.number))
-						}
+						writeInfo(info)
 					}
 				}
 			}
@@ -260,10 +241,11 @@ Tips:
 				var infh *bufio.Reader
 				var r *os.File
 				var reader *unikmer.Reader
-				var gzipped bool
-				var n int64
+				var compressed bool
+				var n, total int64
+				var count uint32
 
-				infh, r, gzipped, err = inStream(file)
+				infh, r, compressed, err = inStream(file)
 				if err != nil {
 					select {
 					case <-cancel:
@@ -288,12 +270,17 @@ Tips:
 				}
 
 				n = 0
+				counted := reader.Flag&unikmer.UNIK_COUNT > 0
 				if all {
-					if reader.Flag&unikmer.UNIK_SORTED > 0 && reader.Number >= 0 {
+					if reader.Flag&unikmer.UNIK_SORTED > 0 && reader.Number >= 0 && !counted {
 						n = reader.Number
 					} else {
 						for {
-							_, err = reader.Read()
+							if reader.K > 32 {
+								_, count, err = reader.ReadWithCount2()
+							} else {
+								_, count, err = reader.ReadWithCount()
+							}
 							if err != nil {
 								if err == io.EOF {
 									break
@@ -302,17 +289,25 @@ Tips:
 							}
 
 							n++
+							total += int64(count)
 						}
 					}
 				}
 				ch <- statInfo{
-					file:      file,
-					k:         reader.K,
-					gzipped:   gzipped,
-					compact:   reader.Flag&unikmer.UNIK_COMPACT > 0,
-					canonical: reader.Flag&unikmer.UNIK_CANONICAL > 0,
-					sorted:    reader.Flag&unikmer.UNIK_SORTED > 0,
-					number:    n,
+					file:       file,
+					k:          reader.K,
+					compressed: compressed,
+					compact:    reader.Flag&unikmer.UNIK_COMPACT > 0,
+					canonical:  reader.Flag&unikmer.UNIK_CANONICAL > 0,
+					sorted:     reader.Flag&unikmer.UNIK_SORTED > 0,
+					counted:    counted,
+					sketch:     sketchStr(reader.Header),
+					sampling:   samplingStr(reader.Header),
+					seed:       spacedSeedStr(reader.Header),
+					alphabet:   alphabetStr(reader.Header),
+					meta:       metaStr(reader.Meta),
+					number:     n,
+					total:      total,
 
 					err: nil,
 					id:  id,
@@ -340,55 +335,71 @@ Tips:
 		columns := []prettytable.Column{
 			{Header: "file"},
 			{Header: "k", AlignRight: true},
-			{Header: "gzipped"},
+			{Header: "compressed"},
 			{Header: "compact"},
 			{Header: "canonical"},
 			{Header: "sorted"},
+			{Header: "counted"},
+			{Header: "sketch"},
+			{Header: "sampling"},
+			{Header: "seed"},
+			{Header: "alphabet"},
 		}
 		if all {
 			columns = append(columns, []prettytable.Column{
 				{Header: "number", AlignRight: true},
+				{Header: "total_count", AlignRight: true},
 			}...)
 		}
+		if showMeta {
+			columns = append(columns, prettytable.Column{Header: "meta"})
+		}
 		tbl, err := prettytable.NewTable(columns...)
 
 		checkError(err)
 		tbl.Separator = "  "
 
 		for _, info := range statInfos {
-			if !all {
-				tbl.AddRow(
-					info.file,
-					info.k,
-					boolStr(sTrue, sFalse, info.gzipped),
-					boolStr(sTrue, sFalse, info.compact),
-					boolStr(sTrue, sFalse, info.canonical),
-					boolStr(sTrue, sFalse, info.sorted),
-				)
-			} else {
-				tbl.AddRow(
-					info.file,
-					info.k,
-					boolStr(sTrue, sFalse, info.gzipped),
-					boolStr(sTrue, sFalse, info.compact),
-					boolStr(sTrue, sFalse, info.canonical),
-					boolStr(sTrue, sFalse, info.sorted),
-					humanize.Comma(info.number),
-				)
+			row := []interface{}{
+				info.file,
+				info.k,
+				boolStr(sTrue, sFalse, info.compressed),
+				boolStr(sTrue, sFalse, info.compact),
+				boolStr(sTrue, sFalse, info.canonical),
+				boolStr(sTrue, sFalse, info.sorted),
+				boolStr(sTrue, sFalse, info.counted),
+				info.sketch,
+				info.sampling,
+				info.seed,
+				info.alphabet,
+			}
+			if all {
+				row = append(row, humanize.Comma(info.number), info.totalCountStr(true))
+			}
+			if showMeta {
+				row = append(row, info.meta)
 			}
+			tbl.AddRow(row...)
 		}
 		outfh.Write(tbl.Bytes())
 	},
 }
 
 type statInfo struct {
-	file      string
-	k         int
-	gzipped   bool
-	compact   bool
-	canonical bool
-	sorted    bool
-	number    int64
+	file       string
+	k          int
+	compressed bool
+	compact    bool
+	canonical  bool
+	sorted     bool
+	counted    bool
+	sketch     string
+	sampling   string
+	seed       string
+	alphabet   string
+	meta       string
+	number     int64
+	total      int64 // sum of counts of all k-mers, only for files with counts
 
 	err error
 	id  uint64
@@ -397,14 +408,86 @@ type statInfo struct {
 func init() {
 	RootCmd.AddCommand(statCmd)
 
-	statCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	statCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
 	statCmd.Flags().BoolP("all", "a", false, "all information, including number of k-mers")
 	statCmd.Flags().BoolP("tabular", "t", false, "output in machine-friendly tabular format")
+	statCmd.Flags().BoolP("meta", "m", false, "show metadata of binary files")
 	statCmd.Flags().BoolP("skip-err", "e", false, "skip error, only show warning message")
 	statCmd.Flags().StringP("symbol-true", "T", "✓", "smybol for true")
 	statCmd.Flags().StringP("symbol-false", "F", "✕", "smybol for false")
 }
 
+// sketchStr returns type and parameter of sketch, or "-" for non-sketch files.
+func sketchStr(h unikmer.Header) string {
+	if h.Flag&unikmer.UNIK_SKETCH == 0 {
+		return "-"
+	}
+	if h.SketchSize > 0 {
+		return fmt.Sprintf("MinHash(s=%d)", h.SketchSize)
+	}
+	return fmt.Sprintf("FracMinHash(scale=%d)", h.SketchScale)
+}
+
+// samplingStr returns the scheme and parameters of k-mer sampling,
+// or "-" for files of all k-mers.
+func samplingStr(h unikmer.Header) string {
+	switch {
+	case h.Flag&unikmer.UNIK_MINIMIZER > 0:
+		return fmt.Sprintf("minimizer(w=%d,%s)", h.MinimizerWindow,
+			unikmer.MinimizerOrderingName(h.MinimizerOrdering))
+	case h.Flag&unikmer.UNIK_SYNCMER > 0 && h.SyncmerType == unikmer.SyncmerOpen:
+		return fmt.Sprintf("open-syncmer(s=%d,offset=%d)", h.SyncmerS, h.SyncmerOffset)
+	case h.Flag&unikmer.UNIK_SYNCMER > 0:
+		return fmt.Sprintf("closed-syncmer(s=%d)", h.SyncmerS)
+	}
+	return "-"
+}
+
+// spacedSeedStr returns the spaced seed, or "-" for contiguous k-mers.
+func spacedSeedStr(h unikmer.Header) string {
+	if h.Flag&unikmer.UNIK_SPACED == 0 {
+		return "-"
+	}
+	return h.SpacedSeed
+}
+
+// alphabetStr returns the alphabet of amino acids, or "dna" for DNA k-mers.
+func alphabetStr(h unikmer.Header) string {
+	if h.Flag&unikmer.UNIK_PROTEIN == 0 {
+		return "dna"
+	}
+	return unikmer.AlphabetName(h.Alphabet)
+}
+
+// metaStr returns metadata in format of "key=value; key2=value2" with sorted keys,
+// or "-" for files without metadata.
+func metaStr(meta map[string]string) string {
+	if len(meta) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]string, len(keys))
+	for i, key := range keys {
+		items[i] = key + "=" + meta[key]
+	}
+	return strings.Join(items, "; ")
+}
+
+// totalCountStr returns sum of counts, or "-" for files without counts.
+func (info statInfo) totalCountStr(comma bool) string {
+	if !info.counted {
+		return "-"
+	}
+	if comma {
+		return humanize.Comma(info.total)
+	}
+	return fmt.Sprintf("%d", info.total)
+}
+
 func boolStr(sTrue, sFalse string, v bool) string {
 	if v {
 		return sTrue
@@ -84,6 +84,7 @@ Attention:
 		var reader *unikmer.Reader
 		reader, err = unikmer.NewReader(infh)
 		checkError(err)
+		checkDNA(file, reader.Header)
 
 		if k >= reader.K {
 			log.Errorf("k (%d) should be small than k size (%d) of %s", k, reader.K, file)
@@ -93,7 +94,7 @@ Attention:
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -107,19 +108,28 @@ Attention:
 		if opt.Compact {
 			mode |= unikmer.UNIK_COMPACT
 		}
+		if opt.Index {
+			mode |= unikmer.UNIK_INDEXED
+		}
 		if canonical {
 			mode |= unikmer.UNIK_CANONICAL
 		}
 		writer, err := unikmer.NewWriter(outfh, k, mode)
 		checkError(err)
+		writer.Meta = opt.newMeta()
 
 		m := make(map[uint64]struct{}, mapInitSize)
 
 		var kcode, kcode2 unikmer.KmerCode
+		var kcodeL unikmer.KmerCode2 // for K > 32
 		var kmer []byte
 		var ok bool
 		for {
-			kcode, err = reader.Read()
+			if reader.K > 32 {
+				kcodeL, err = reader.Read2()
+			} else {
+				kcode, err = reader.Read()
+			}
 			if err != nil {
 				if err == io.EOF {
 					break
@@ -127,7 +137,11 @@ Attention:
 				checkError(err)
 			}
 
-			kmer = kcode.Bytes()
+			if reader.K > 32 {
+				kmer = kcodeL.Bytes()
+			} else {
+				kmer = kcode.Bytes()
+			}
 			kmer = kmer[0:k]
 
 			kcode2, err = unikmer.NewKmerCode(kmer)
@@ -145,6 +159,7 @@ Attention:
 			}
 		}
 
+		checkError(writer.WriteHeader()) // in case of no k-mers
 		checkError(writer.Flush())
 	},
 }
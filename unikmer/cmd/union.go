@@ -21,12 +21,9 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"runtime"
-	"sort"
 
 	"github.com/shenwei356/unikmer"
 	"github.com/spf13/cobra"
@@ -42,6 +39,15 @@ var unionCmd = &cobra.Command{
 Attentions:
   1. the 'canonical' flags of all files should be consistent.
 
+Tips:
+  1. For big files (k <= 32), use --max-mem along with -s/--sort to limit
+     the memory usage, sorted chunks are saved to temporary files in --tmp-dir
+     and then merged.
+  2. If all files are sorted (k <= 32), k-mers are merged in streaming
+     with little memory occupation, and the result is sorted.
+  3. For k <= 14, k-mers are saved in a bitmap instead of a hash table,
+     which is faster and the result is always sorted.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -59,16 +65,39 @@ Attentions:
 		}
 
 		checkFiles(extDataFile, files...)
+		files = uniqFiles(files)
 
 		outFile := getFlagString(cmd, "out-prefix")
 		sortKmers := getFlagBool(cmd, "sort")
+		maxMem, tmpDir := getExternalSortFlags(cmd)
+
+		var k0 int
+		k0, err = peekK(files[0])
+		checkError(err)
+		if k0 > 32 {
+			checkNoSketch2(files)
+			unionKmers2(opt, files, outFile, sortKmers)
+			return
+		}
+		if allSorted(files) {
+			mergeSortedKmers(opt, files, outFile, setUnion)
+			return
+		}
 
-		m := make(map[uint64]struct{}, mapInitSize)
+		var m unikmer.KmerSet
+
+		// external sorting, k-mers are deduplicated in merging instead of with the map
+		var sorter *codeSorter
+		defer func() {
+			if sorter != nil {
+				sorter.Cleanup()
+			}
+		}()
 
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -80,53 +109,62 @@ Attentions:
 
 		var writer *unikmer.Writer
 
-		var infh *bufio.Reader
-		var r *os.File
+		var r io.Closer
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
 		var k int = -1
-		var canonical bool
-		var firstFile = true
-		var ok bool
+		var canonical, protein bool
+		var header unikmer.Header
 		var n int64
 		var flag int
 		var nfiles = len(files)
 		for i, file := range files {
-			if !firstFile && file == files[0] {
-				continue
-			}
-
 			if opt.Verbose {
 				log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
 			}
 
 			flag = func() int {
-				infh, r, _, err = inStream(file)
+				reader, r, err = newBinaryReader(file)
 				checkError(err)
 				defer r.Close()
 
-				reader, err = unikmer.NewReader(infh)
-				checkError(err)
-
 				if k == -1 {
 					k = reader.K
 					canonical = reader.Flag&unikmer.UNIK_CANONICAL > 0
+					protein = reader.Flag&unikmer.UNIK_PROTEIN > 0
+					header = reader.Header
+
+					m = newKmerSet(k, protein)
+					// k-mers in bitmap are sorted for free
+					if m.Sorted() {
+						sortKmers = true
+					}
 
 					if !sortKmers {
 						var mode uint32
 						if opt.Compact {
 							mode |= unikmer.UNIK_COMPACT
 						}
+						if opt.Index {
+							mode |= unikmer.UNIK_INDEXED
+						}
 						if canonical {
 							mode |= unikmer.UNIK_CANONICAL
 						}
 						writer, err = unikmer.NewWriter(outfh, k, mode)
 						checkError(err)
+						writer.Meta = opt.newMeta()
+						setKmerScheme(&writer.Header, header)
+					} else if maxMem > 0 && !m.Sorted() {
+						sorter = newCodeSorter(opt, k, true, maxMem, tmpDir)
+						m = nil
 					}
 				} else if k != reader.K {
 					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
 				} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
 					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				} else {
+					checkKmerScheme(file, reader.Header, header)
 				}
 
 				for {
@@ -138,9 +176,13 @@ Attentions:
 						checkError(err)
 					}
 
+					if sorter != nil {
+						sorter.Add(kcode.Code)
+						continue
+					}
+
 					// new kmers
-					if _, ok = m[kcode.Code]; !ok {
-						m[kcode.Code] = struct{}{}
+					if m.Add(kcode.Code) {
 						n++
 						if !sortKmers {
 							writer.Write(kcode) // not need to check err
@@ -163,33 +205,33 @@ Attentions:
 			if opt.Compact {
 				mode |= unikmer.UNIK_COMPACT
 			}
+			if opt.Index {
+				mode |= unikmer.UNIK_INDEXED
+			}
 			if canonical {
 				mode |= unikmer.UNIK_CANONICAL
 			}
 			mode |= unikmer.UNIK_SORTED
 			writer, err = unikmer.NewWriter(outfh, k, mode)
 			checkError(err)
+			writer.Meta = opt.newMeta()
+			setKmerScheme(&writer.Header, header)
+		}
 
-			writer.Number = int64(len(m))
-
-			codes := make([]uint64, len(m))
-			i := 0
-			for code := range m {
-				codes[i] = code
-				i++
-			}
-			if opt.Verbose {
-				log.Infof("sorting %d k-mers", len(codes))
-			}
-			sort.Sort(unikmer.CodeSlice(codes))
-			if opt.Verbose {
-				log.Infof("done sorting")
-			}
-			for _, code := range codes {
-				writer.Write(unikmer.KmerCode{Code: code, K: k})
-			}
+		if sorter != nil {
+			n = sorter.Count()
+			writer.Number = n
+			sorter.Iterate(func(code uint64) {
+				writer.Write(unikmer.KmerCode{Code: code, K: k}) // not need to check err
+			})
+		} else if sortKmers {
+			writer.Number = n
+			iterateKmerSet(opt, m, true, func(code uint64) {
+				writer.Write(unikmer.KmerCode{Code: code, K: k}) // not need to check err
+			})
 		}
 
+		checkError(writer.WriteHeader()) // in case of no k-mers
 		checkError(writer.Flush())
 		if opt.Verbose {
 			log.Infof("%d k-mers saved", n)
@@ -202,4 +244,38 @@ func init() {
 
 	unionCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 	unionCmd.Flags().BoolP("sort", "s", false, helpSort)
+	addExternalSortFlags(unionCmd)
+}
+
+// unionKmers2 computes union of binary files with K > 32.
+func unionKmers2(opt *Options, files []string, outFile string, sortKmers bool) {
+	m := make(map[[2]uint64]struct{}, mapInitSize)
+	codes := make([][2]uint64, 0, mapInitSize)
+
+	var k int = -1
+	var canonical bool
+	var header *unikmer.Header
+	var ok bool
+	var nfiles = len(files)
+	for i, file := range files {
+		if opt.Verbose {
+			log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+		}
+
+		header = readKmers2(file, k, canonical, func(kcode unikmer.KmerCode2) {
+			if _, ok = m[kcode.Code]; !ok {
+				m[kcode.Code] = struct{}{}
+				codes = append(codes, kcode.Code)
+			}
+		})
+		if k == -1 {
+			k = header.K
+			canonical = header.Flag&unikmer.UNIK_CANONICAL > 0
+		}
+	}
+
+	if sortKmers {
+		sortCodes2(opt, codes)
+	}
+	writeKmers2(opt, outFile, k, canonical, sortKmers, codes)
 }
@@ -40,9 +40,31 @@ var uniqsCmd = &cobra.Command{
 	Short: "mapping k-mers back to genome and find unique subsequences",
 	Long: `mapping k-mers back to genome and find unique subsequences
 
+K-mers of genome are checked in order and the subsequences consisting of
+qualified k-mers are reported. A k-mer is qualified if:
+  1. it's in the given binary files, which are optional with -b/--background,
+  2. and it's not in any of background sets (-b/--background),
+  3. and it's not multiple mapped in the genome, unless -M/--allow-muliple-mapped-kmer.
+
+Background sets:
+  Background sets could be binary files (.unik) or genomes in (gzipped)
+  FASTA/Q files, which are used for finding subsequences absent from
+  a collection of genomes, e.g., for designing diagnostic markers.
+  K-mer size is read from binary files, or given by -k/--kmer-len when
+  only sequence files are given.
+
+Output:
+  1. Default output is in BED3 format, with left-closed and right-open
+     0-based interval.
+  2. Statistics of every region are appended with -s/--stats, including
+     length of region, number of k-mers in region and number of qualified
+     k-mers. They are saved in the header of FASTA format (-a/--output-fasta).
+
 Attention:
-  1. default output is in BED3 format, with left-closed and right-open
-     0-based interval
+  1. K-mers containing bases other than A/C/G/T/U (e.g., N) are skipped,
+     and they break the unique subsequences.
+  2. The K and 'canonical' flags of all binary files should be consistent.
+  3. K-mers of all background sets are loaded into RAM.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -51,16 +73,29 @@ Attention:
 
 		var err error
 
+		bgFiles := getFlagStringSlice(cmd, "background")
+
 		var files []string
 		infileList := getFlagString(cmd, "infile-list")
 		if infileList != "" {
 			files, err = getListFromFile(infileList)
 			checkError(err)
-		} else {
+		} else if len(args) > 0 || len(bgFiles) == 0 {
 			files = getFileList(args)
 		}
 
 		checkFiles(extDataFile, files...)
+		checkFiles("", bgFiles...)
+		for _, file := range bgFiles {
+			if isStdin(file) {
+				checkError(fmt.Errorf("stdin not supported for background sets"))
+			}
+		}
+		kFlag := getFlagNonNegativeInt(cmd, "kmer-len")
+		if kFlag > 32 {
+			checkError(fmt.Errorf("k > 32 not supported"))
+		}
+		showStats := getFlagBool(cmd, "stats")
 
 		outFile := getFlagString(cmd, "out-prefix")
 		circular := getFlagBool(cmd, "circular")
@@ -107,6 +142,10 @@ Attention:
 
 				reader, err = unikmer.NewReader(infh)
 				checkError(err)
+				if reader.K > 32 {
+					checkError(fmt.Errorf("k > 32 not supported: %s", file))
+				}
+				checkDNA(file, reader.Header)
 
 				if k == -1 {
 					k = reader.K
@@ -152,21 +191,77 @@ Attention:
 			}()
 		}
 
-		if opt.Verbose {
+		if opt.Verbose && len(files) > 0 {
 			log.Infof("%d k-mers loaded", len(m))
 		}
 
+		// -----------------------------------------------------------------------
+
+		// k-mers of background sets
+		var bg map[uint64]struct{}
+		if len(bgFiles) > 0 {
+			if k == -1 {
+				for _, file := range bgFiles {
+					if strings.HasSuffix(file, extDataFile) {
+						k, err = peekK(file)
+						checkError(err)
+						break
+					}
+				}
+			}
+			if k == -1 {
+				if kFlag == 0 {
+					checkError(fmt.Errorf("flag -k/--kmer-len needed when no binary files given"))
+				}
+				k = kFlag
+			} else if kFlag > 0 && kFlag != k {
+				checkError(fmt.Errorf("value of -k/--kmer-len (%d) not equal to K (%d) of binary files", kFlag, k))
+			}
+
+			bg = make(map[uint64]struct{}, mapInitSize)
+			for i, file := range bgFiles {
+				if opt.Verbose {
+					log.Infof("reading background set (%d/%d): %s", i+1, len(bgFiles), file)
+				}
+				if strings.HasSuffix(file, extDataFile) {
+					loadBackgroundBinaryFile(file, k, bg)
+				} else {
+					nSkipped := loadBackgroundSeqFile(file, k, bg)
+					if opt.Verbose && nSkipped > 0 {
+						log.Infof("%d k-mers containing non-ACGT bases skipped", nSkipped)
+					}
+				}
+			}
+			if opt.Verbose {
+				log.Infof("%d k-mers loaded from background sets", len(bg))
+			}
+		}
+
+		// found checks if a canonical k-mer is in the given k-mers
+		// and absent from background sets.
+		found := func(code uint64) bool {
+			if len(files) > 0 {
+				if _, ok := m[code]; !ok {
+					return false
+				}
+			}
+			if bg != nil {
+				if _, ok := bg[code]; ok {
+					return false
+				}
+			}
+			return true
+		}
+
 		// -----------------------------------------------------------------------
 		var m2 map[uint64]bool
 
-		var sequence, kmer, preKmer []byte
-		var originalLen, l, end, e int
 		var record *fastx.Record
 		var fastxReader *fastx.Reader
-		var preKcode unikmer.KmerCode
-		var first bool
-		var i int
+		var iter *unikmer.KmerIterator
+		var i, preI int
 		var ok bool
+		var nSkipped int
 		var multipleMapped bool
 
 		if !mMapped {
@@ -186,46 +281,18 @@ Attention:
 					break
 				}
 
-				sequence = record.Seq.Seq
-
 				if opt.Verbose {
 					log.Infof("processing sequence: %s", record.ID)
 				}
 
-				originalLen = len(record.Seq.Seq)
-				l = len(sequence)
-
-				end = l - 1
-				if end < 0 {
-					end = 0
-				}
-				first = true
-				for i = 0; i <= end; i++ {
-					e = i + k
-					if e > originalLen {
-						if circular {
-							e = e - originalLen
-							kmer = sequence[i:]
-							kmer = append(kmer, sequence[0:e]...)
-						} else {
-							break
-						}
-					} else {
-						kmer = sequence[i : i+k]
-					}
+				iter, err = unikmer.NewKmerIterator(record.Seq.Seq, k, true, circular)
+				checkError(err)
 
-					if first {
-						kcode, err = unikmer.NewKmerCode(kmer)
-						first = false
-					} else {
-						kcode, err = unikmer.NewKmerCodeMustFromFormerOne(kmer, preKmer, preKcode)
-					}
-					if err != nil {
-						checkError(fmt.Errorf("fail to encode '%s': %s", kmer, err))
+				for {
+					kcode, ok = iter.Next()
+					if !ok {
+						break
 					}
-					preKmer, preKcode = kmer, kcode
-
-					kcode = kcode.Canonical()
 
 					if multipleMapped, ok = m2[kcode.Code]; !ok {
 						m2[kcode.Code] = false
@@ -253,7 +320,7 @@ Attention:
 
 		// -----------------------------------------------------------------------
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -263,6 +330,44 @@ Attention:
 			w.Close()
 		}()
 
+		// output writes a region [start, end) of a sequence.
+		output := func(record *fastx.Record, start, end int) {
+			var stats string
+			if showStats {
+				var nKmers, nQualified int
+				if end > len(record.Seq.Seq) { // circular genome
+					end = len(record.Seq.Seq)
+				}
+				iter, err := unikmer.NewKmerIterator(record.Seq.Seq[start:end], k, true, false)
+				checkError(err)
+				for {
+					kcode, ok := iter.Next()
+					if !ok {
+						break
+					}
+					nKmers++
+					if !mMapped && m2[kcode.Code] {
+						continue
+					}
+					if found(kcode.Code) {
+						nQualified++
+					}
+				}
+				if outputFASTA {
+					stats = fmt.Sprintf(" len=%d kmers=%d qualified=%d", end-start, nKmers, nQualified)
+				} else {
+					stats = fmt.Sprintf("\t%d\t%d\t%d", end-start, nKmers, nQualified)
+				}
+			}
+
+			if outputFASTA {
+				outfh.WriteString(fmt.Sprintf(">%s:%d-%d%s\n%s\n", record.ID, start+1, end, stats,
+					record.Seq.SubSeq(start+1, end).FormatSeq(60)))
+			} else {
+				outfh.WriteString(fmt.Sprintf("%s\t%d\t%d%s\n", record.ID, start, end, stats))
+			}
+		}
+
 		var c, start, nonUniqs, nonUniqsNum, lastNonUniqsNum, lastmatch, ii int
 		var flag bool = true
 		if opt.Verbose {
@@ -280,54 +385,40 @@ Attention:
 				break
 			}
 
-			sequence = record.Seq.Seq
-
 			if opt.Verbose {
 				log.Infof("processinig sequence: %s", record.ID)
 			}
 
-			originalLen = len(record.Seq.Seq)
-			l = len(sequence)
-
-			end = l - 1
-			if end < 0 {
-				end = 0
-			}
-
 			c = 0
 			start = -1
 			nonUniqs = 0
 			nonUniqsNum = 0
 
-			first = true
-			for i = 0; i <= end; i++ {
-				e = i + k
-				if e > originalLen {
-					if circular {
-						e = e - originalLen
-						kmer = sequence[i:]
-						kmer = append(kmer, sequence[0:e]...)
-					} else {
-						break
-					}
-				} else {
-					kmer = sequence[i : i+k]
-				}
+			iter, err = unikmer.NewKmerIterator(record.Seq.Seq, k, true, circular)
+			checkError(err)
 
-				if first {
-					kcode, err = unikmer.NewKmerCode(kmer)
-					first = false
-				} else {
-					kcode, err = unikmer.NewKmerCodeMustFromFormerOne(kmer, preKmer, preKcode)
+			preI = -1
+			for {
+				kcode, ok = iter.Next()
+				if !ok {
+					break
 				}
-				if err != nil {
-					checkError(fmt.Errorf("encoding '%s': %s", kmer, err))
+				i = iter.Index()
+
+				// k-mers containing non-ACGT bases are skipped, which breaks the region
+				if i > preI+1 && preI >= 0 {
+					ii = lastmatch + 1
+					if lastNonUniqsNum <= maxContNonUniqKmersNum &&
+						start >= 0 && ii-start >= minLen {
+						output(record, start, ii)
+					}
+					c = 0
+					start = -1
+					flag = true
 				}
-				preKmer, preKcode = kmer, kcode
+				preI = i
 
-				kcode = kcode.Canonical()
-
-				if _, ok = m[kcode.Code]; ok {
+				if found(kcode.Code) {
 					if c+1 >= k {
 						lastmatch = i
 						lastNonUniqsNum = nonUniqsNum
@@ -338,12 +429,7 @@ Attention:
 							ii = lastmatch + 1
 							if lastNonUniqsNum <= maxContNonUniqKmersNum &&
 								start >= 0 && ii-start >= minLen {
-								if outputFASTA {
-									outfh.WriteString(fmt.Sprintf(">%s:%d-%d\n%s\n", record.ID, start+1, ii,
-										record.Seq.SubSeq(start+1, ii).FormatSeq(60)))
-								} else {
-									outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\n", record.ID, start, ii))
-								}
+								output(record, start, ii)
 							}
 
 							c = 0
@@ -385,12 +471,7 @@ Attention:
 						ii = lastmatch + 1
 						if lastNonUniqsNum <= maxContNonUniqKmersNum &&
 							start >= 0 && ii-start >= minLen {
-							if outputFASTA {
-								outfh.WriteString(fmt.Sprintf(">%s:%d-%d\n%s\n", record.ID, start+1, ii,
-									record.Seq.SubSeq(start+1, ii).FormatSeq(60)))
-							} else {
-								outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\n", record.ID, start, ii))
-							}
+							output(record, start, ii)
 						}
 						c = 0
 						start = -1
@@ -399,20 +480,87 @@ Attention:
 				}
 				// debug.WriteString(fmt.Sprintln(i, c, start, lastmatch, nonUniqs, nonUniqsNum, lastNonUniqsNum))
 			}
+			nSkipped += iter.Skipped()
+
 			ii = lastmatch + 1
 			if lastNonUniqsNum <= maxContNonUniqKmersNum+1 &&
 				start >= 0 && ii-start >= minLen {
-				if outputFASTA {
-					outfh.WriteString(fmt.Sprintf(">%s:%d-%d\n%s\n", record.ID, start+1, ii,
-						record.Seq.SubSeq(start+1, ii).FormatSeq(60)))
-				} else {
-					outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\n", record.ID, start, ii))
-				}
+				output(record, start, ii)
 			}
 		}
+		if opt.Verbose && nSkipped > 0 {
+			log.Infof("%d k-mers containing non-ACGT bases skipped", nSkipped)
+		}
 	},
 }
 
+// loadBackgroundBinaryFile adds canonical k-mers of a binary file to m.
+func loadBackgroundBinaryFile(file string, k int, m map[uint64]struct{}) {
+	infh, r, _, err := inStream(file)
+	checkError(err)
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	checkError(err)
+	checkDNA(file, reader.Header)
+	if reader.K != k {
+		checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+	}
+	canonical := reader.Flag&unikmer.UNIK_CANONICAL > 0
+
+	var kcode unikmer.KmerCode
+	for {
+		kcode, err = reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+		}
+
+		if canonical {
+			m[kcode.Code] = struct{}{}
+		} else {
+			m[kcode.Canonical().Code] = struct{}{}
+		}
+	}
+}
+
+// loadBackgroundSeqFile adds canonical k-mers of a sequence file to m,
+// and returns the number of skipped k-mers.
+func loadBackgroundSeqFile(file string, k int, m map[uint64]struct{}) int {
+	fastxReader, err := fastx.NewDefaultReader(file)
+	checkError(err)
+
+	var record *fastx.Record
+	var iter *unikmer.KmerIterator
+	var kcode unikmer.KmerCode
+	var ok bool
+	var nSkipped int
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+			break
+		}
+
+		iter, err = unikmer.NewKmerIterator(record.Seq.Seq, k, true, false)
+		checkError(err)
+		for {
+			kcode, ok = iter.Next()
+			if !ok {
+				break
+			}
+			m[kcode.Code] = struct{}{}
+		}
+		nSkipped += iter.Skipped()
+	}
+	return nSkipped
+}
+
 func init() {
 	RootCmd.AddCommand(uniqsCmd)
 
@@ -424,4 +572,7 @@ func init() {
 	uniqsCmd.Flags().BoolP("output-fasta", "a", false, "output fasta format instead of BED3")
 	uniqsCmd.Flags().IntP("max-cont-non-uniq-kmers", "x", 0, "max continuous non-unique k-mers")
 	uniqsCmd.Flags().IntP("max-num-cont-non-uniq-kmers", "X", 0, "max number of continuous non-unique k-mers")
+	uniqsCmd.Flags().StringSliceP("background", "b", []string{}, `background sets, binary files or (gzipped) FASTA/Q files (multiple values delimted by comma supported)`)
+	uniqsCmd.Flags().IntP("kmer-len", "k", 0, "k-mer size, only needed when no binary files given")
+	uniqsCmd.Flags().BoolP("stats", "s", false, "append statistics of regions")
 }
@@ -20,4 +20,76 @@
 
 package cmd
 
+import (
+	"fmt"
+	"io"
+
+	"github.com/shenwei356/unikmer"
+)
+
 const extDataFile = ".unik"
+
+const extBloomFile = ".bloom"
+
+// newBinaryReader returns a Reader of binary file. Uncompressed files of
+// fixed-length k-mers are memory-mapped (see unikmer.MmapReader),
+// and others are read in streaming.
+func newBinaryReader(file string) (*unikmer.Reader, io.Closer, error) {
+	if !isStdin(file) {
+		if m, err := unikmer.NewMmapReader(file); err == nil {
+			return m.Reader(), m, nil
+		}
+	}
+
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := unikmer.NewReader(infh)
+	if err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+	return reader, r, nil
+}
+
+// checkKmerScheme checks whether k-mers of a binary file are sampled with
+// the same scheme and parameters, and are of the same spaced seed and
+// alphabet, as previous files.
+func checkKmerScheme(file string, h unikmer.Header, h0 unikmer.Header) {
+	if !h.SameSampling(h0) {
+		checkError(fmt.Errorf("k-mer sampling (%s) of binary file '%s' not compatible with previous files (%s)",
+			samplingStr(h), file, samplingStr(h0)))
+	}
+	if !h.SameSpacedSeed(h0) {
+		checkError(fmt.Errorf("spaced seed (%s) of binary file '%s' not compatible with previous files (%s)",
+			spacedSeedStr(h), file, spacedSeedStr(h0)))
+	}
+	if !h.SameAlphabet(h0) {
+		checkError(fmt.Errorf("alphabet (%s) of binary file '%s' not compatible with previous files (%s)",
+			alphabetStr(h), file, alphabetStr(h0)))
+	}
+}
+
+// setKmerScheme copies the scheme and parameters of k-mer sampling, the
+// spaced seed and the alphabet from header h to dst, it should be called
+// before writing the header.
+func setKmerScheme(dst *unikmer.Header, h unikmer.Header) {
+	dst.Flag |= h.Flag & (unikmer.UNIK_MINIMIZER | unikmer.UNIK_SYNCMER | unikmer.UNIK_SPACED | unikmer.UNIK_PROTEIN)
+	dst.MinimizerWindow = h.MinimizerWindow
+	dst.MinimizerOrdering = h.MinimizerOrdering
+	dst.SyncmerType = h.SyncmerType
+	dst.SyncmerS = h.SyncmerS
+	dst.SyncmerOffset = h.SyncmerOffset
+	dst.SpacedSeed = h.SpacedSeed
+	dst.Alphabet = h.Alphabet
+}
+
+// checkDNA exits if a binary file contains protein k-mers,
+// for commands only supporting DNA k-mers.
+func checkDNA(file string, h unikmer.Header) {
+	if h.Flag&unikmer.UNIK_PROTEIN > 0 {
+		checkError(fmt.Errorf("binary file '%s' of protein k-mers (alphabet: %s) not supported",
+			file, alphabetStr(h)))
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 
 	"github.com/shenwei356/go-logging"
 	"github.com/shenwei356/util/stringutil"
@@ -36,10 +37,33 @@ var log = logging.MustGetLogger("unikmer")
 func checkError(err error) {
 	if err != nil {
 		log.Error(err)
+		runExitHooks()
 		os.Exit(-1)
 	}
 }
 
+// exitHooks are called before exiting on errors, e.g., for removing temporary files.
+var exitHooks []func()
+var exitHooksMu sync.Mutex
+
+// addExitHook registers a function to be called before exiting on errors.
+func addExitHook(fn func()) {
+	exitHooksMu.Lock()
+	exitHooks = append(exitHooks, fn)
+	exitHooksMu.Unlock()
+}
+
+// runExitHooks calls registered functions only once.
+func runExitHooks() {
+	exitHooksMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitHooksMu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
 func isStdin(file string) bool {
 	return file == "-"
 }
@@ -186,6 +210,12 @@ func getFlagStringSlice(cmd *cobra.Command, flag string) []string {
 	return value
 }
 
+func getFlagStringArray(cmd *cobra.Command, flag string) []string {
+	value, err := cmd.Flags().GetStringArray(flag)
+	checkError(err)
+	return value
+}
+
 func getListFromFile(file string) ([]string, error) {
 	fh, err := os.Open(file)
 	if err != nil {
@@ -27,11 +27,28 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/klauspost/compress/zstd"
 	gzip "github.com/klauspost/pgzip"
 )
 
-func outStream(file string, gzipped bool, level int) (*bufio.Writer, io.WriteCloser, *os.File, error) {
+// compression codecs
+const (
+	codecGzip = "gzip"
+	codecZstd = "zstd"
+)
+
+// isCompressedFile checks whether the out file should be compressed by the suffix.
+func isCompressedFile(file string) bool {
+	file = strings.ToLower(file)
+	return strings.HasSuffix(file, ".gz") || strings.HasSuffix(file, ".zst")
+}
+
+// outStream creates the out file, which is compressed with the codec if
+// compressed is true. Files with suffix .gz or .zst are compressed with the
+// corresponding codec.
+func outStream(file string, compressed bool, level int, codec string) (*bufio.Writer, io.WriteCloser, *os.File, error) {
 	var w *os.File
 	if file == "-" {
 		w = os.Stdout
@@ -51,51 +68,101 @@ func outStream(file string, gzipped bool, level int) (*bufio.Writer, io.WriteClo
 		}
 	}
 
-	if gzipped {
-		// gw := gzip.NewWriter(w)
-		gw, err := gzip.NewWriterLevel(w, level)
+	if !compressed {
+		return bufio.NewWriterSize(w, os.Getpagesize()), nil, w, nil
+	}
+
+	switch name := strings.ToLower(file); {
+	case strings.HasSuffix(name, ".gz"):
+		codec = codecGzip
+	case strings.HasSuffix(name, ".zst"):
+		codec = codecZstd
+	}
+
+	if codec == codecZstd {
+		zlevel := zstd.SpeedDefault
+		if level > 0 {
+			zlevel = zstd.EncoderLevelFromZstd(level)
+		}
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zlevel))
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("fail to write %s: %s", file, err)
 		}
-		return bufio.NewWriterSize(gw, os.Getpagesize()), gw, w, nil
+		return bufio.NewWriterSize(zw, os.Getpagesize()), zw, w, nil
 	}
-	return bufio.NewWriterSize(w, os.Getpagesize()), nil, w, nil
+
+	// gw := gzip.NewWriter(w)
+	gw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("fail to write %s: %s", file, err)
+	}
+	return bufio.NewWriterSize(gw, os.Getpagesize()), gw, w, nil
 }
 
+// stdin is cached, so it could be opened more than once, e.g., peeking header
+// before reading.
+var stdinStream *bufio.Reader
+var stdinCompressed bool
+
+// inStream opens the file, gzip and zstd compressed files are detected by
+// magic bytes and decompressed.
 func inStream(file string) (*bufio.Reader, *os.File, bool, error) {
 	var err error
 	var r *os.File
-	var gzipped bool
+	var compressed bool
 	if file == "-" {
+		if stdinStream != nil {
+			return stdinStream, os.Stdin, stdinCompressed, nil
+		}
 		if !detectStdin() {
-			return nil, nil, gzipped, errors.New("stdin not detected")
+			return nil, nil, compressed, errors.New("stdin not detected")
 		}
 		r = os.Stdin
 	} else {
 		r, err = os.Open(file)
 		if err != nil {
-			return nil, nil, gzipped, fmt.Errorf("fail to read %s: %s", file, err)
+			return nil, nil, compressed, fmt.Errorf("fail to read %s: %s", file, err)
 		}
 	}
 
 	br := bufio.NewReaderSize(r, os.Getpagesize())
 
+	var gzipped, zstded bool
 	if gzipped, err = isGzip(br); err != nil {
-		return nil, nil, gzipped, fmt.Errorf("fail to check is file (%s) gzipped: %s", file, err)
+		return nil, nil, compressed, fmt.Errorf("fail to check is file (%s) gzipped: %s", file, err)
 	} else if gzipped {
 		gr, err := gzip.NewReader(br)
 		if err != nil {
-			return nil, r, gzipped, fmt.Errorf("fail to create gzip reader for %s: %s", file, err)
+			return nil, r, compressed, fmt.Errorf("fail to create gzip reader for %s: %s", file, err)
 		}
 		br = bufio.NewReaderSize(gr, os.Getpagesize())
+	} else if zstded, err = isZstd(br); err != nil {
+		return nil, nil, compressed, fmt.Errorf("fail to check is file (%s) zstd compressed: %s", file, err)
+	} else if zstded {
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, r, compressed, fmt.Errorf("fail to create zstd reader for %s: %s", file, err)
+		}
+		br = bufio.NewReaderSize(zr, os.Getpagesize())
 	}
-	return br, r, gzipped, nil
+	compressed = gzipped || zstded
+	if file == "-" {
+		stdinStream, stdinCompressed = br, compressed
+	}
+	return br, r, compressed, nil
 }
 
 func isGzip(b *bufio.Reader) (bool, error) {
 	return checkBytes(b, []byte{0x1f, 0x8b})
 }
 
+func isZstd(b *bufio.Reader) (bool, error) {
+	if m, _ := b.Peek(4); len(m) < 4 { // too short to be zstd compressed
+		return false, nil
+	}
+	return checkBytes(b, []byte{0x28, 0xb5, 0x2f, 0xfd})
+}
+
 func checkBytes(b *bufio.Reader, buf []byte) (bool, error) {
 	m, err := b.Peek(len(buf))
 	if err != nil {
@@ -0,0 +1,372 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// kmerScheme contains the k-mer size and the scheme of extracting k-mers
+// from FASTA/Q sequences, i.e., all k-mers, minimizers, syncmers, spaced
+// k-mers, or protein k-mers of protein sequences or translated DNA sequences.
+// It's shared by "unikmer count" and "unikmer sketch".
+type kmerScheme struct {
+	k         int
+	canonical bool
+	circular  bool
+
+	window   int   // window of minimizers, 0 for all k-mers
+	ordering uint8 // ordering of minimizers
+	syncmer  *unikmer.Syncmer
+	seed     *unikmer.SpacedSeed
+
+	protein     bool
+	alphabet    uint8
+	geneticCode *unikmer.GeneticCode // for translating DNA sequences
+}
+
+// kmerIterator is the common interface of KmerIterator, MinimizerIterator,
+// SyncmerIterator, SpacedKmerIterator and ProteinKmerIterator.
+type kmerIterator interface {
+	Next() (unikmer.KmerCode, bool)
+	Skipped() int
+}
+
+// addKmerSchemeFlags adds flags of the k-mer scheme, shorthands already
+// used by the command are omitted.
+func addKmerSchemeFlags(cmd *cobra.Command) {
+	shorthand := func(s string) string {
+		if cmd.Flags().ShorthandLookup(s) != nil {
+			return ""
+		}
+		return s
+	}
+	cmd.Flags().IntP("minimizer-window", shorthand("W"), 0, "only keep minimizers of every W consecutive k-mers, 0 for all k-mers")
+	cmd.Flags().StringP("minimizer-order", "", "hash", `ordering of k-mers for choosing minimizers, "lex" (lexicographic) or "hash"`)
+	cmd.Flags().IntP("syncmer-s", shorthand("S"), 0, "only keep syncmers with s-mers of this length, 0 for all k-mers")
+	cmd.Flags().StringP("syncmer-type", "", "closed", `type of syncmers, "closed" or "open"`)
+	cmd.Flags().IntP("syncmer-offset", "", 0, "offset of the smallest s-mer in open syncmers, in range [0, k-s]")
+	cmd.Flags().StringP("alphabet", "", "dna", `alphabet of sequences, "dna", or "protein", "murphy15", "murphy10" and "dayhoff6" for protein sequences`)
+	cmd.Flags().BoolP("translate", shorthand("t"), false, "extract protein k-mers of DNA sequences translated in six frames")
+	cmd.Flags().IntP("genetic-code", shorthand("g"), 1, "NCBI ID of genetic code table for translation, e.g., 11 for bacteria")
+	cmd.Flags().StringP("spaced-seed", "", "", `spaced seed for extracting spaced k-mers, e.g., "1101101", where 0s are don't-care positions`)
+}
+
+// flagStr returns the name of a flag along with its shorthand, e.g., "-k/--kmer-len".
+func flagStr(cmd *cobra.Command, name string) string {
+	if f := cmd.Flags().Lookup(name); f != nil && f.Shorthand != "" {
+		return "-" + f.Shorthand + "/--" + name
+	}
+	return "--" + name
+}
+
+// getKmerScheme parses and checks flags of the k-mer scheme.
+func getKmerScheme(cmd *cobra.Command) *kmerScheme {
+	var err error
+	s := &kmerScheme{
+		k:         getFlagNonNegativeInt(cmd, "kmer-len"),
+		canonical: getFlagBool(cmd, "canonical"),
+		circular:  getFlagBool(cmd, "circular"),
+	}
+
+	// k is the weight of spaced seed
+	if spacedSeed := getFlagString(cmd, "spaced-seed"); spacedSeed != "" {
+		s.seed, err = unikmer.NewSpacedSeed(spacedSeed)
+		checkError(err)
+		if s.k > 0 && s.k != s.seed.Weight {
+			checkError(fmt.Errorf("value of -k/--kmer-len (%d) should be equal to the weight of spaced seed (%d)", s.k, s.seed.Weight))
+		}
+		s.k = s.seed.Weight
+	} else if s.k == 0 {
+		checkError(fmt.Errorf("flag -k/--kmer-len needed"))
+	}
+	if s.k > unikmer.MaxK2 {
+		checkError(fmt.Errorf("k > %d not supported", unikmer.MaxK2))
+	}
+
+	s.protein, s.alphabet = parseAlphabet(getFlagString(cmd, "alphabet"))
+
+	// DNA sequences are translated to protein sequences
+	if getFlagBool(cmd, "translate") {
+		s.geneticCode, err = unikmer.NewGeneticCode(getFlagPositiveInt(cmd, "genetic-code"))
+		if err != nil {
+			checkError(fmt.Errorf("%s, available: %v", err, unikmer.GeneticCodeIDs()))
+		}
+		if s.circular {
+			checkError(fmt.Errorf("flag --circular and %s are not compatible", flagStr(cmd, "translate")))
+		}
+		if !s.protein {
+			s.protein, s.alphabet = true, unikmer.AlphabetProtein
+		}
+	}
+	if s.protein {
+		if s.k > unikmer.MaxProteinK {
+			checkError(fmt.Errorf("k > %d not supported for protein k-mers", unikmer.MaxProteinK))
+		}
+		if s.seed != nil {
+			checkError(fmt.Errorf("flag --alphabet and --spaced-seed are not compatible"))
+		}
+		if s.canonical {
+			checkError(fmt.Errorf("flag -K/--canonical is not supported for protein k-mers"))
+		}
+	}
+
+	s.window = getFlagNonNegativeInt(cmd, "minimizer-window")
+	if s.window > 0 {
+		if s.k > 32 {
+			checkError(fmt.Errorf("minimizer only supports k <= 32"))
+		}
+		if s.seed != nil {
+			checkError(fmt.Errorf("flag %s and --spaced-seed are not compatible", flagStr(cmd, "minimizer-window")))
+		}
+		if s.protein {
+			checkError(fmt.Errorf("flag %s and --alphabet are not compatible", flagStr(cmd, "minimizer-window")))
+		}
+		s.ordering = parseMinimizerOrdering(getFlagString(cmd, "minimizer-order"))
+	}
+
+	if syncmerS := getFlagNonNegativeInt(cmd, "syncmer-s"); syncmerS > 0 {
+		if s.k > 32 {
+			checkError(fmt.Errorf("syncmer only supports k <= 32"))
+		}
+		if s.window > 0 {
+			checkError(fmt.Errorf("flag %s and %s are not compatible", flagStr(cmd, "minimizer-window"), flagStr(cmd, "syncmer-s")))
+		}
+		if s.seed != nil {
+			checkError(fmt.Errorf("flag %s and --spaced-seed are not compatible", flagStr(cmd, "syncmer-s")))
+		}
+		if s.protein {
+			checkError(fmt.Errorf("flag %s and --alphabet are not compatible", flagStr(cmd, "syncmer-s")))
+		}
+		s.syncmer, err = unikmer.NewSyncmer(s.k, syncmerS,
+			parseSyncmerType(getFlagString(cmd, "syncmer-type")), getFlagNonNegativeInt(cmd, "syncmer-offset"))
+		checkError(err)
+	}
+	return s
+}
+
+// header returns a header with the flags and parameters of the scheme,
+// which could be copied to the header of a Writer with setKmerScheme.
+func (s *kmerScheme) header() unikmer.Header {
+	h := unikmer.Header{K: s.k}
+	if s.canonical {
+		h.Flag |= unikmer.UNIK_CANONICAL
+	}
+	if s.window > 0 {
+		h.Flag |= unikmer.UNIK_MINIMIZER
+		h.MinimizerWindow = s.window
+		h.MinimizerOrdering = s.ordering
+	}
+	if s.syncmer != nil {
+		h.Flag |= unikmer.UNIK_SYNCMER
+		h.SyncmerType = s.syncmer.Type
+		h.SyncmerS = s.syncmer.S
+		h.SyncmerOffset = s.syncmer.Offset
+	}
+	if s.seed != nil {
+		h.Flag |= unikmer.UNIK_SPACED
+		h.SpacedSeed = s.seed.String()
+	}
+	if s.protein {
+		h.Flag |= unikmer.UNIK_PROTEIN
+		h.Alphabet = s.alphabet
+	}
+	return h
+}
+
+// setMeta saves options not in the header to the metadata.
+func (s *kmerScheme) setMeta(meta map[string]string) {
+	meta["canonical"] = fmt.Sprintf("%v", s.canonical)
+	meta["circular"] = fmt.Sprintf("%v", s.circular)
+	if s.geneticCode != nil {
+		meta["genetic-code"] = fmt.Sprintf("%d", s.geneticCode.ID)
+	}
+}
+
+// newIterator creates an iterator of k-mers (K <= 32) of the sequence.
+func (s *kmerScheme) newIterator(sequence []byte) (kmerIterator, error) {
+	if s.window > 0 {
+		return unikmer.NewMinimizerIterator(sequence, s.k, s.window, s.ordering, s.canonical, s.circular)
+	} else if s.syncmer != nil {
+		return unikmer.NewSyncmerIterator(sequence, s.syncmer, s.canonical, s.circular)
+	} else if s.seed != nil {
+		return unikmer.NewSpacedKmerIterator(sequence, s.seed, s.canonical, s.circular)
+	} else if s.protein {
+		return unikmer.NewProteinKmerIterator(sequence, s.k, s.alphabet)
+	}
+	return unikmer.NewKmerIterator(sequence, s.k, s.canonical, s.circular)
+}
+
+// scanKmers calls fn for every k-mer (K <= 32) of sequences in files.
+func (s *kmerScheme) scanKmers(opt *Options, files []string, fn func(code uint64)) {
+	var iter kmerIterator
+	var kcode unikmer.KmerCode
+	var ok bool
+	var err error
+	s.scan(opt, files, func(sequence []byte) int {
+		iter, err = s.newIterator(sequence)
+		checkError(err)
+		for {
+			kcode, ok = iter.Next()
+			if !ok {
+				break
+			}
+			fn(kcode.Code)
+		}
+		return iter.Skipped()
+	})
+}
+
+// scanKmers2 is scanKmers for k-mers with K > 32.
+func (s *kmerScheme) scanKmers2(opt *Options, files []string, fn func(code [2]uint64)) {
+	var iter *unikmer.KmerIterator2
+	var kcode unikmer.KmerCode2
+	var ok bool
+	var err error
+	s.scan(opt, files, func(sequence []byte) int {
+		iter, err = unikmer.NewKmerIterator2(sequence, s.k, s.canonical, s.circular)
+		checkError(err)
+		for {
+			kcode, ok = iter.Next()
+			if !ok {
+				break
+			}
+			fn(kcode.Code)
+		}
+		return iter.Skipped()
+	})
+}
+
+// scan calls fn for sequences of records in files, i.e., every sequence
+// and its reverse complement sequence (not canonical), or six frames of
+// translated sequences, fn returns the number of skipped k-mers.
+func (s *kmerScheme) scan(opt *Options, files []string, fn func(sequence []byte) int) {
+	var err error
+	var sequence []byte
+	var record *fastx.Record
+	var fastxReader *fastx.Reader
+	var j, iters, skipped int
+	var nSkipped int64
+	if s.geneticCode != nil {
+		iters = len(unikmer.Frames)
+	} else if s.canonical || s.protein {
+		iters = 1
+	} else {
+		iters = 2
+	}
+	for _, file := range files {
+		if opt.Verbose {
+			log.Infof("reading sequence file: %s", file)
+		}
+		fastxReader, err = fastx.NewDefaultReader(file)
+		checkError(err)
+		for {
+			record, err = fastxReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+				break
+			}
+
+			for j = 0; j < iters; j++ {
+				if s.geneticCode != nil { // translated sequence of a frame
+					sequence, err = s.geneticCode.Translate(record.Seq.Seq, unikmer.Frames[j])
+					checkError(err)
+
+					if opt.Verbose {
+						log.Infof("processing translated sequence: %s, frame: %d", record.ID, unikmer.Frames[j])
+					}
+				} else if j == 0 { // sequence
+					sequence = record.Seq.Seq
+
+					if opt.Verbose {
+						log.Infof("processing sequence: %s", record.ID)
+					}
+				} else { // reverse complement sequence
+					sequence = record.Seq.RevComInplace().Seq
+
+					if opt.Verbose {
+						log.Infof("processing reverse complement sequence: %s", record.ID)
+					}
+				}
+
+				skipped = fn(sequence)
+				if j == 0 || s.geneticCode != nil {
+					nSkipped += int64(skipped)
+				}
+			}
+		}
+	}
+	if opt.Verbose && nSkipped > 0 {
+		if s.protein {
+			log.Infof("%d k-mers containing illegal residues skipped", nSkipped)
+		} else {
+			log.Infof("%d k-mers containing non-ACGT bases skipped", nSkipped)
+		}
+	}
+}
+
+// parseMinimizerOrdering parses the name of minimizer ordering.
+func parseMinimizerOrdering(name string) uint8 {
+	switch strings.ToLower(name) {
+	case "lex":
+		return unikmer.MinimizerLexicographic
+	case "hash":
+		return unikmer.MinimizerHashed
+	}
+	checkError(fmt.Errorf(`invalid minimizer ordering: %s, available: "lex", "hash"`, name))
+	return 0
+}
+
+// parseSyncmerType parses the name of syncmer type.
+func parseSyncmerType(name string) uint8 {
+	switch strings.ToLower(name) {
+	case "closed":
+		return unikmer.SyncmerClosed
+	case "open":
+		return unikmer.SyncmerOpen
+	}
+	checkError(fmt.Errorf(`invalid syncmer type: %s, available: "closed", "open"`, name))
+	return 0
+}
+
+// parseAlphabet parses the name of alphabet, protein is false for "dna".
+func parseAlphabet(name string) (protein bool, alphabet uint8) {
+	name = strings.ToLower(name)
+	if name == "dna" {
+		return false, 0
+	}
+	for alphabet = unikmer.AlphabetProtein; alphabet <= unikmer.AlphabetDayhoff6; alphabet++ {
+		if name == unikmer.AlphabetName(alphabet) {
+			return true, alphabet
+		}
+	}
+	checkError(fmt.Errorf(`invalid alphabet: %s, available: "dna", "protein", "murphy15", "murphy10", "dayhoff6"`, name))
+	return false, 0
+}
@@ -0,0 +1,197 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// peekK returns K of a binary file by peeking its header, the stream is not
+// consumed, so stdin could still be read later.
+func peekK(file string) (int, error) {
+	h, err := peekHeader(file)
+	return h.K, err
+}
+
+// peekHeader returns the header of a binary file by peeking, the stream is
+// not consumed, so stdin could still be read later. Metadata, the last part of
+// the header, is not parsed for it may be bigger than the buffer.
+func peekHeader(file string) (unikmer.Header, error) {
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		return unikmer.Header{}, err
+	}
+	if !isStdin(file) {
+		defer r.Close()
+	}
+
+	buf, err := infh.Peek(infh.Size())
+	if err != nil && err != io.EOF {
+		return unikmer.Header{}, fmt.Errorf("fail to read header of %s: %s", file, err)
+	}
+	n := len(unikmer.Magic)
+	if len(buf) < n+8 {
+		return unikmer.Header{}, fmt.Errorf("fail to read header of %s: %s", file, io.ErrUnexpectedEOF)
+	}
+	buf = append([]byte(nil), buf...)
+	flag := binary.BigEndian.Uint32(buf[n+4:n+8]) &^ unikmer.UNIK_META
+	binary.BigEndian.PutUint32(buf[n+4:n+8], flag)
+
+	reader, err := unikmer.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return unikmer.Header{}, fmt.Errorf("fail to read header of %s: %s", file, err)
+	}
+	return reader.Header, nil
+}
+
+// code2Str formats a two-word code as a 128-bit decimal integer.
+func code2Str(code [2]uint64) string {
+	var v, lo big.Int
+	v.SetUint64(code[0])
+	v.Lsh(&v, 64)
+	lo.SetUint64(code[1])
+	v.Or(&v, &lo)
+	return v.String()
+}
+
+// parseCode2 parses a 128-bit decimal integer to a two-word code.
+func parseCode2(s string) ([2]uint64, error) {
+	var code [2]uint64
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok || v.Sign() < 0 || v.BitLen() > 128 {
+		return code, fmt.Errorf("invalid 128-bit non-negative integer: %s", s)
+	}
+	var lo big.Int
+	lo.And(v, new(big.Int).SetUint64(^uint64(0)))
+	code[1] = lo.Uint64()
+	code[0] = v.Rsh(v, 64).Uint64()
+	return code, nil
+}
+
+// readKmers2 reads all KmerCode2 from a binary file with K > 32.
+// K and 'canonical' flag are checked if k > 0.
+func readKmers2(file string, k int, canonical bool, fn func(kcode unikmer.KmerCode2)) *unikmer.Header {
+	infh, r, _, err := inStream(file)
+	checkError(err)
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	checkError(err)
+
+	if k > 0 {
+		if k != reader.K {
+			checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+		} else if (reader.Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
+			checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+		}
+	}
+
+	var kcode unikmer.KmerCode2
+	for {
+		kcode, err = reader.Read2()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+		}
+
+		fn(kcode)
+	}
+	return &reader.Header
+}
+
+// sortCodes2 sorts two-word codes.
+func sortCodes2(opt *Options, codes [][2]uint64) {
+	if opt.Verbose {
+		log.Infof("sorting %d k-mers", len(codes))
+	}
+	sort.Sort(unikmer.CodeSlice2(codes))
+	if opt.Verbose {
+		log.Infof("done sorting")
+	}
+}
+
+// writeKmers2 writes k-mers (K > 32) to binary file in the given order,
+// codes should be sorted in advance if sorted is true.
+func writeKmers2(opt *Options, outFile string, k int, canonical bool, sorted bool, codes [][2]uint64) {
+	if !isStdout(outFile) {
+		outFile += extDataFile
+	}
+	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
+	checkError(err)
+	defer func() {
+		outfh.Flush()
+		if gw != nil {
+			gw.Close()
+		}
+		w.Close()
+	}()
+
+	var mode uint32
+	if opt.Compact {
+		mode |= unikmer.UNIK_COMPACT
+	}
+	if opt.Index {
+		mode |= unikmer.UNIK_INDEXED
+	}
+	if canonical {
+		mode |= unikmer.UNIK_CANONICAL
+	}
+	if sorted {
+		mode |= unikmer.UNIK_SORTED
+	}
+
+	writer, err := unikmer.NewWriter(outfh, k, mode)
+	checkError(err)
+	writer.Meta = opt.newMeta()
+	writer.Number = int64(len(codes))
+
+	if len(codes) == 0 {
+		checkError(writer.WriteHeader())
+	}
+	for _, code := range codes {
+		checkError(writer.Write2(unikmer.KmerCode2{Code: code, K: k}))
+	}
+	checkError(writer.Flush())
+	if opt.Verbose {
+		log.Infof("%d k-mers saved", len(codes))
+	}
+}
+
+// checkNoSketch2 exits if any of binary files with K > 32 is a sketch,
+// for set operations of sketches are only supported for K <= 32.
+func checkNoSketch2(files []string) {
+	for _, file := range files {
+		h, err := peekHeader(file)
+		checkError(err)
+		if h.Flag&unikmer.UNIK_SKETCH > 0 {
+			checkError(fmt.Errorf("set operations of sketches only support k <= 32, binary file '%s' is a sketch of k = %d", file, h.K))
+		}
+	}
+}
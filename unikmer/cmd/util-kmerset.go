@@ -0,0 +1,117 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//b
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"sort"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// newKmerSet creates a KmerSet for DNA k-mers with K <= 32,
+// or protein k-mers with K <= 12.
+func newKmerSet(k int, protein bool) unikmer.KmerSet {
+	var m unikmer.KmerSet
+	var err error
+	if protein {
+		m, err = unikmer.NewProteinKmerSet(k, mapInitSize)
+	} else {
+		m, err = unikmer.NewKmerSet(k, mapInitSize)
+	}
+	checkError(err)
+	return m
+}
+
+// iterateKmerSet calls fn for every k-mer in the set, in ascending order if
+// sorted is true. Sets in bitmap are already sorted, others are sorted here.
+func iterateKmerSet(opt *Options, m unikmer.KmerSet, sorted bool, fn func(code uint64)) {
+	if !sorted || m.Sorted() {
+		m.Iterate(fn)
+		return
+	}
+
+	codes := make([]uint64, 0, m.Len())
+	m.Iterate(func(code uint64) {
+		codes = append(codes, code)
+	})
+	if opt.Verbose {
+		log.Infof("sorting %d k-mers", len(codes))
+	}
+	sort.Sort(unikmer.CodeSlice(codes))
+	if opt.Verbose {
+		log.Infof("done sorting")
+	}
+	for _, code := range codes {
+		fn(code)
+	}
+}
+
+// writeKmerSet writes k-mers (K <= 32) in the set to binary file,
+// k-mers are sorted if sorted is true or the set is sorted.
+// K, 'canonical' flag and k-mer sampling are the same as the header h.
+func writeKmerSet(opt *Options, outFile string, h unikmer.Header, sorted bool, m unikmer.KmerSet) {
+	if !isStdout(outFile) {
+		outFile += extDataFile
+	}
+	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
+	checkError(err)
+	defer func() {
+		outfh.Flush()
+		if gw != nil {
+			gw.Close()
+		}
+		w.Close()
+	}()
+
+	sorted = sorted || m.Sorted()
+	k := h.K
+
+	var mode uint32
+	if opt.Compact {
+		mode |= unikmer.UNIK_COMPACT
+	}
+	if opt.Index {
+		mode |= unikmer.UNIK_INDEXED
+	}
+	if h.Flag&unikmer.UNIK_CANONICAL > 0 {
+		mode |= unikmer.UNIK_CANONICAL
+	}
+	if sorted {
+		mode |= unikmer.UNIK_SORTED
+	}
+
+	writer, err := unikmer.NewWriter(outfh, k, mode)
+	checkError(err)
+	writer.Meta = opt.newMeta()
+	setKmerScheme(&writer.Header, h)
+	writer.Number = int64(m.Len())
+
+	if m.Len() == 0 {
+		checkError(writer.WriteHeader())
+	}
+	iterateKmerSet(opt, m, sorted, func(code uint64) {
+		checkError(writer.Write(unikmer.KmerCode{Code: code, K: k}))
+	})
+	checkError(writer.Flush())
+	if opt.Verbose {
+		log.Infof("%d k-mers saved", m.Len())
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// set operations computed by streaming merging.
+const (
+	setUnion = iota
+	setInter
+	setDiff
+	setSymDiff
+)
+
+// allSorted checks whether all binary files are sorted and with K <= 32.
+func allSorted(files []string) bool {
+	var h unikmer.Header
+	var err error
+	for _, file := range files {
+		h, err = peekHeader(file)
+		checkError(err)
+		if h.K > 32 || h.Flag&unikmer.UNIK_SORTED == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeSortedKmers computes set operation of sorted binary files (K <= 32)
+// by streaming k-way merging with unikmer.MergeIterator, instead of loading
+// k-mers into RAM. The result is written as a sorted binary file.
+// Duplicated files should be removed with uniqFiles in advance.
+func mergeSortedKmers(opt *Options, files []string, outFile string, op int) {
+	if opt.Verbose {
+		log.Infof("merging %d sorted files", len(files))
+	}
+
+	readers := make([]*unikmer.Reader, len(files))
+	var k int = -1
+	var canonical bool
+	for i, file := range files {
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		readers[i], err = unikmer.NewReader(infh)
+		checkError(err)
+
+		if k == -1 {
+			k = readers[i].K
+			canonical = readers[i].Flag&unikmer.UNIK_CANONICAL > 0
+		} else if k != readers[i].K {
+			checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", readers[i].K, file, k))
+		} else if (readers[i].Flag&unikmer.UNIK_CANONICAL > 0) != canonical {
+			checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+		} else {
+			checkKmerScheme(file, readers[i].Header, readers[0].Header)
+		}
+	}
+
+	iter, err := unikmer.NewMergeIterator(readers...)
+	checkError(err)
+
+	// keep the sketch flag if all files are sketches with the same parameters
+	sketch := true
+	for _, reader := range readers {
+		if reader.Flag&unikmer.UNIK_SKETCH == 0 ||
+			reader.SketchSize != readers[0].SketchSize || reader.SketchScale != readers[0].SketchScale {
+			sketch = false
+			break
+		}
+	}
+
+	// the union of bottom-s MinHash sketches is truncated to s k-mers with
+	// the smallest hash values, while other operations do not give a valid sketch.
+	var minHash *unikmer.Sketch
+	if sketch && readers[0].SketchSize > 0 {
+		if op != setUnion {
+			checkError(fmt.Errorf("only union is supported for bottom-s MinHash sketches, please use FracMinHash sketches instead"))
+		}
+		minHash, err = unikmer.NewMinHashSketch(k, readers[0].SketchSize)
+		checkError(err)
+	}
+
+	if !isStdout(outFile) {
+		outFile += extDataFile
+	}
+	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
+	checkError(err)
+	defer func() {
+		outfh.Flush()
+		if gw != nil {
+			gw.Close()
+		}
+		w.Close()
+	}()
+
+	var mode uint32
+	if canonical {
+		mode |= unikmer.UNIK_CANONICAL
+	}
+	mode |= unikmer.UNIK_SORTED
+	if opt.Index {
+		mode |= unikmer.UNIK_INDEXED
+	}
+	if sketch {
+		mode |= unikmer.UNIK_SKETCH
+	}
+
+	writer, err := unikmer.NewWriter(outfh, k, mode)
+	checkError(err)
+	writer.Meta = opt.newMeta()
+	if sketch {
+		writer.SketchSize = readers[0].SketchSize
+		writer.SketchScale = readers[0].SketchScale
+	}
+	setKmerScheme(&writer.Header, readers[0].Header)
+
+	nfiles := len(files)
+	var kcode unikmer.KmerCode
+	var idx []int
+	var keep bool
+	var n int64
+	for {
+		kcode, idx, err = iter.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+		}
+
+		switch op {
+		case setUnion:
+			keep = true
+		case setInter:
+			keep = len(idx) == nfiles
+		case setDiff:
+			keep = len(idx) == 1 && idx[0] == 0
+		case setSymDiff:
+			keep = len(idx) == 1
+		}
+		if !keep {
+			continue
+		}
+
+		if minHash != nil {
+			minHash.Add(kcode.Code)
+			continue
+		}
+
+		checkError(writer.Write(kcode))
+		n++
+	}
+
+	if minHash != nil {
+		codes := minHash.Codes()
+		writer.Number = int64(len(codes))
+		for _, code := range codes {
+			checkError(writer.Write(unikmer.KmerCode{Code: code, K: k}))
+		}
+		n = int64(len(codes))
+	}
+
+	if n == 0 {
+		writer.Number = 0
+		checkError(writer.WriteHeader())
+	}
+	checkError(writer.Flush())
+	if opt.Verbose {
+		log.Infof("%d k-mers saved", n)
+	}
+}
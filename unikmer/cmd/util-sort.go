@@ -0,0 +1,319 @@
+// Copyright © 2018 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/dustin/go-humanize"
+	"github.com/shenwei356/unikmer"
+	"github.com/shenwei356/util/pathutil"
+	"github.com/spf13/cobra"
+)
+
+// chunkBufSize is the buffer size for reading and writing temporary files.
+const chunkBufSize = 1 << 16
+
+const helpMaxMem = `max memory for sorting k-mers (k <= 32), e.g., 500M, 4G. ` +
+	`Sorted chunks are spilled to temporary files and merged when exceeded. 0 for no limit`
+
+// addExternalSortFlags adds flags for external sorting.
+func addExternalSortFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("max-mem", "", "0", helpMaxMem)
+	cmd.Flags().StringP("tmp-dir", "", os.TempDir(), "directory for temporary files of external sorting")
+}
+
+// getExternalSortFlags returns the memory budget in bytes and directory of temporary files.
+func getExternalSortFlags(cmd *cobra.Command) (int64, string) {
+	s := getFlagString(cmd, "max-mem")
+	maxMem, err := humanize.ParseBytes(s)
+	if err != nil {
+		checkError(fmt.Errorf("invalid value of --max-mem: %s", s))
+	}
+	tmpDir := getFlagString(cmd, "tmp-dir")
+	if maxMem > 0 {
+		var existed bool
+		existed, err = pathutil.DirExists(tmpDir)
+		checkError(err)
+		if !existed {
+			checkError(os.MkdirAll(tmpDir, 0755))
+		}
+	}
+	return int64(maxMem), tmpDir
+}
+
+// codeSorter sorts k-mer codes (k <= 32) under a memory budget.
+// When the number of codes exceeds the budget, sorted chunks are spilled
+// into temporary binary files, which are k-way merged during iteration.
+type codeSorter struct {
+	opt      *Options
+	k        int
+	unique   bool // remove duplicated codes
+	maxCodes int  // max number of codes in RAM, 0 for no limit
+	tmpDir   string
+
+	codes  []uint64
+	sorted bool
+	chunks []string
+	merged bool  // whether chunks are merged into one
+	n      int64 // number of merged codes
+}
+
+// newCodeSorter creates a codeSorter, maxMem <= 0 for sorting in RAM.
+func newCodeSorter(opt *Options, k int, unique bool, maxMem int64, tmpDir string) *codeSorter {
+	s := &codeSorter{opt: opt, k: k, unique: unique, tmpDir: tmpDir}
+	if maxMem > 0 {
+		s.maxCodes = int(maxMem / 8)
+		if s.maxCodes < 1 {
+			s.maxCodes = 1
+		}
+	}
+	if s.maxCodes > 0 && s.maxCodes < mapInitSize {
+		s.codes = make([]uint64, 0, s.maxCodes)
+	} else {
+		s.codes = make([]uint64, 0, mapInitSize)
+	}
+
+	// temporary files are also removed when exiting on errors
+	addExitHook(func() {
+		for _, file := range s.chunks {
+			os.Remove(file)
+		}
+	})
+	return s
+}
+
+// Add adds one code.
+func (s *codeSorter) Add(code uint64) {
+	if s.maxCodes > 0 && len(s.codes) >= s.maxCodes {
+		s.spill()
+	}
+	s.codes = append(s.codes, code)
+	s.sorted = false
+}
+
+// sortCodes sorts codes in RAM, and removes duplicated ones if needed.
+func (s *codeSorter) sortCodes() {
+	if s.sorted {
+		return
+	}
+	if s.opt.Verbose {
+		log.Infof("sorting %d k-mers", len(s.codes))
+	}
+	sort.Sort(unikmer.CodeSlice(s.codes))
+	if s.opt.Verbose {
+		log.Infof("done sorting")
+	}
+	if s.unique && len(s.codes) > 0 {
+		j := 1
+		for _, code := range s.codes[1:] {
+			if code == s.codes[j-1] {
+				continue
+			}
+			s.codes[j] = code
+			j++
+		}
+		s.codes = s.codes[:j]
+	}
+	s.sorted = true
+}
+
+// spill writes sorted codes in RAM into a temporary file.
+func (s *codeSorter) spill() {
+	if len(s.codes) == 0 {
+		return
+	}
+	s.sortCodes()
+
+	file, writer, done := s.newChunk()
+	if s.opt.Verbose {
+		log.Infof("spilling %d k-mers to temporary file: %s", len(s.codes), file)
+	}
+	writer.Number = int64(len(s.codes))
+	for _, code := range s.codes {
+		checkError(writer.Write(unikmer.KmerCode{Code: code, K: s.k}))
+	}
+	done()
+
+	s.codes = s.codes[:0]
+}
+
+// newChunk creates a temporary file and returns a writer of it,
+// done should be called after writing.
+func (s *codeSorter) newChunk() (string, *unikmer.Writer, func()) {
+	fh, err := ioutil.TempFile(s.tmpDir, "unikmer-sort-*"+extDataFile)
+	checkError(err)
+	file := fh.Name()
+	s.chunks = append(s.chunks, file)
+
+	outfh := bufio.NewWriterSize(fh, chunkBufSize)
+	writer, err := unikmer.NewWriter(outfh, s.k, unikmer.UNIK_SORTED)
+	checkError(err)
+	return file, writer, func() {
+		checkError(writer.WriteHeader()) // in case of no k-mers
+		checkError(writer.Flush())
+		checkError(outfh.Flush())
+		checkError(fh.Close())
+	}
+}
+
+// Count returns the number of codes to be outputted by Iterate.
+// Temporary files are merged into one, and codes are counted in merging,
+// so Iterate only needs to read the merged file.
+func (s *codeSorter) Count() int64 {
+	if len(s.chunks) == 0 {
+		s.sortCodes()
+		return int64(len(s.codes))
+	}
+	if !s.merged {
+		s.merge()
+	}
+	return s.n
+}
+
+// merge k-way merges temporary files into one, and counts the codes.
+func (s *codeSorter) merge() {
+	s.spill() // the remaining codes
+	chunks := s.chunks
+
+	file, writer, done := s.newChunk()
+	if s.opt.Verbose {
+		log.Infof("merging %d temporary files into: %s", len(chunks), file)
+	}
+	s.n = 0
+	mergeChunks(chunks, s.unique, func(code uint64) {
+		checkError(writer.Write(unikmer.KmerCode{Code: code, K: s.k}))
+		s.n++
+	})
+	done()
+
+	for _, file := range chunks {
+		checkError(os.Remove(file))
+	}
+	s.chunks = []string{file}
+	s.merged = true
+}
+
+// Iterate calls fn for every code in ascending order. It can be called multiple times.
+func (s *codeSorter) Iterate(fn func(code uint64)) {
+	if len(s.chunks) == 0 {
+		s.sortCodes()
+		for _, code := range s.codes {
+			fn(code)
+		}
+		return
+	}
+
+	// the remaining codes
+	s.spill()
+
+	if s.opt.Verbose && !s.merged {
+		log.Infof("merging %d temporary files", len(s.chunks))
+	}
+	mergeChunks(s.chunks, s.unique, fn)
+}
+
+// mergeChunks k-way merges sorted temporary files and calls fn for every code,
+// duplicated codes are removed if unique is true.
+func mergeChunks(chunks []string, unique bool, fn func(code uint64)) {
+	h := make(chunkHeap, 0, len(chunks))
+	var fh *os.File
+	var err error
+	for _, file := range chunks {
+		fh, err = os.Open(file)
+		checkError(err)
+		defer fh.Close()
+
+		c := &chunk{file: file}
+		c.reader, err = unikmer.NewReader(bufio.NewReaderSize(fh, chunkBufSize))
+		checkError(err)
+		if c.next() {
+			h = append(h, c)
+		}
+	}
+	heap.Init(&h)
+
+	var c *chunk
+	var last uint64
+	var first = true
+	for len(h) > 0 {
+		c = h[0]
+		if !(unique && !first && c.code == last) {
+			fn(c.code)
+		}
+		last, first = c.code, false
+
+		if c.next() {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+}
+
+// Cleanup removes temporary files.
+func (s *codeSorter) Cleanup() {
+	for _, file := range s.chunks {
+		checkError(os.Remove(file))
+	}
+	s.chunks = nil
+}
+
+// chunk is a sorted temporary file in merging.
+type chunk struct {
+	file   string
+	reader *unikmer.Reader
+	code   uint64
+}
+
+// next reads the next code, false is returned at the end of file.
+func (c *chunk) next() bool {
+	kcode, err := c.reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return false
+		}
+		checkError(fmt.Errorf("%s: %s", c.file, err))
+	}
+	c.code = kcode.Code
+	return true
+}
+
+// chunkHeap is a min-heap of chunks by their current codes.
+type chunkHeap []*chunk
+
+func (h chunkHeap) Len() int            { return len(h) }
+func (h chunkHeap) Less(i, j int) bool  { return h[i].code < h[j].code }
+func (h chunkHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *chunkHeap) Push(x interface{}) { *h = append(*h, x.(*chunk)) }
+func (h *chunkHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
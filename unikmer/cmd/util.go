@@ -24,8 +24,10 @@ import (
 	"compress/flate"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/shenwei356/unikmer"
 	"github.com/shenwei356/util/pathutil"
@@ -47,20 +49,67 @@ type Options struct {
 	Compress         bool
 	Compact          bool
 	CompressionLevel int
+	Codec            string
+	Index            bool
+
+	// metadata of output binary files
+	Command string
+	Sources []string
+}
+
+// newMeta returns metadata of output binary file, including version of unikmer,
+// command line, source files and creation time.
+func (opt Options) newMeta() map[string]string {
+	meta := map[string]string{
+		"version": "unikmer v" + VERSION,
+		"command": opt.Command,
+		"created": time.Now().Format(time.RFC3339),
+	}
+	if len(opt.Sources) > 0 {
+		meta["sources"] = strings.Join(opt.Sources, ",")
+	}
+	return meta
 }
 
 func getOptions(cmd *cobra.Command) *Options {
+	codec := strings.ToLower(getFlagString(cmd, "codec"))
 	level := getFlagInt(cmd, "compression-level")
-	if level < flate.HuffmanOnly || level > flate.BestCompression {
-		checkError(fmt.Errorf("gzip: invalid compression level: %d", level))
+	switch codec {
+	case codecGzip:
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			checkError(fmt.Errorf("gzip: invalid compression level: %d", level))
+		}
+	case codecZstd:
+		if level != flate.DefaultCompression && (level < 1 || level > 22) {
+			checkError(fmt.Errorf("zstd: invalid compression level: %d", level))
+		}
+	default:
+		checkError(fmt.Errorf("invalid compression codec: %s, available: %s, %s", codec, codecGzip, codecZstd))
+	}
+	index := getFlagBool(cmd, "index")
+	noCompress := getFlagBool(cmd, "no-compress")
+	if index && !noCompress {
+		// offsets in index are of uncompressed file
+		log.Warningf("flag --index disables compression of binary files, use -C/--no-compress to suppress this warning")
+	}
+
+	sources := cmd.Flags().Args()
+	if infileList := getFlagString(cmd, "infile-list"); infileList != "" {
+		sources, _ = getListFromFile(infileList) // error is checked in commands
 	}
+
 	return &Options{
 		NumCPUs: getFlagPositiveInt(cmd, "threads"),
 		// NumCPUs: 1,
 		Verbose:          getFlagBool(cmd, "verbose"),
-		Compress:         !getFlagBool(cmd, "no-compress"),
+		Compress:         !noCompress && !index,
 		Compact:          getFlagBool(cmd, "compact"),
 		CompressionLevel: level,
+		Codec:            codec,
+		Index:            index,
+
+		Command: strings.Join(os.Args, " "),
+		Sources: sources,
 	}
 }
 
@@ -175,7 +224,7 @@ func sortUnikFile(opt Options, unique bool, file string, outFile string) (*unikm
 	if !isStdout(outFile) {
 		outFile += extDataFile
 	}
-	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel, opt.Codec)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -192,6 +241,9 @@ func sortUnikFile(opt Options, unique bool, file string, outFile string) (*unikm
 	if opt.Compact {
 		mode |= unikmer.UNIK_COMPACT
 	}
+	if opt.Index {
+		mode |= unikmer.UNIK_INDEXED
+	}
 	if reader.Flag&unikmer.UNIK_CANONICAL > 0 {
 		mode |= unikmer.UNIK_CANONICAL
 	}
@@ -202,6 +254,7 @@ func sortUnikFile(opt Options, unique bool, file string, outFile string) (*unikm
 	if err != nil {
 		return nil, 0, err
 	}
+	writer.Meta = opt.newMeta()
 
 	// read
 	m := make([]uint64, 0, mapInitSize)
@@ -244,6 +297,21 @@ func sortUnikFile(opt Options, unique bool, file string, outFile string) (*unikm
 	return &reader.Header, n, nil
 }
 
+// uniqFiles removes duplicated files and keeps the order,
+// so a file repeated in the arguments is only processed once.
+func uniqFiles(files []string) []string {
+	files2 := make([]string, 0, len(files))
+	seen := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		if _, ok := seen[file]; ok {
+			continue
+		}
+		seen[file] = struct{}{}
+		files2 = append(files2, file)
+	}
+	return files2
+}
+
 func uniqInts(data []int) []int {
 	if len(data) == 0 || len(data) == 1 {
 		return data
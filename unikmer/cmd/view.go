@@ -38,6 +38,12 @@ var viewCmd = &cobra.Command{
 	Short: "read and output binary format to plain text",
 	Long: `read and output binary format to plain text
 
+Attention:
+  1. For binary files with k-mer counts (e.g., "unikmer count -a"),
+     counts are shown in the last column, or in the FASTA/Q header.
+  2. For binary files of protein k-mers in reduced alphabets, every
+     residue is shown as the first residue of its group.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -62,7 +68,7 @@ var viewCmd = &cobra.Command{
 		outFastq := getFlagBool(cmd, "fastq")
 		showCodeOnly := getFlagBool(cmd, "show-code-only")
 
-		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		outfh, gw, w, err := outStream(outFile, isCompressedFile(outFile), opt.CompressionLevel, opt.Codec)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -76,6 +82,9 @@ var viewCmd = &cobra.Command{
 		var r *os.File
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
+		var count uint32
+		var withCount, protein bool
+		var mer string
 
 		var quality string
 		for _, file := range files {
@@ -90,9 +99,53 @@ var viewCmd = &cobra.Command{
 				if outFastq {
 					quality = strings.Repeat("g", reader.K)
 				}
+				withCount = reader.Flag&unikmer.UNIK_COUNT > 0
+				protein = reader.Flag&unikmer.UNIK_PROTEIN > 0
+
+				if reader.K > 32 {
+					var kcode2 unikmer.KmerCode2
+					var code string
+					for {
+						kcode2, count, err = reader.ReadWithCount2()
+						if err != nil {
+							if err == io.EOF {
+								break
+							}
+							checkError(err)
+						}
+
+						code = code2Str(kcode2.Code)
+						if withCount {
+							if outFasta {
+								outfh.WriteString(fmt.Sprintf(">%s %d\n%s\n", code, count, kcode2.String()))
+							} else if outFastq {
+								outfh.WriteString(fmt.Sprintf(">%s %d\n%s\n+\n%s\n", code, count, kcode2.String(), quality))
+							} else if showCodeOnly {
+								outfh.WriteString(fmt.Sprintf("%s\t%d\n", code, count))
+							} else if showCode {
+								outfh.WriteString(fmt.Sprintf("%s\t%s\t%d\n", kcode2.String(), code, count))
+							} else {
+								outfh.WriteString(fmt.Sprintf("%s\t%d\n", kcode2.String(), count))
+							}
+							continue
+						}
+						if outFasta {
+							outfh.WriteString(fmt.Sprintf(">%s\n%s\n", code, kcode2.String()))
+						} else if outFastq {
+							outfh.WriteString(fmt.Sprintf(">%s\n%s\n+\n%s\n", code, kcode2.String(), quality))
+						} else if showCodeOnly {
+							outfh.WriteString(code + "\n")
+						} else if showCode {
+							outfh.WriteString(kcode2.String() + "\t" + code + "\n")
+						} else {
+							outfh.WriteString(kcode2.String() + "\n")
+						}
+					}
+					return
+				}
 
 				for {
-					kcode, err = reader.Read()
+					kcode, count, err = reader.ReadWithCount()
 					if err != nil {
 						if err == io.EOF {
 							break
@@ -100,17 +153,38 @@ var viewCmd = &cobra.Command{
 						checkError(err)
 					}
 
+					if protein {
+						mer = string(unikmer.DecodeProtein(kcode.Code, kcode.K, reader.Alphabet))
+					} else {
+						mer = kcode.String()
+					}
+
+					if withCount {
+						if outFasta {
+							outfh.WriteString(fmt.Sprintf(">%d %d\n%s\n", kcode.Code, count, mer))
+						} else if outFastq {
+							outfh.WriteString(fmt.Sprintf(">%d %d\n%s\n+\n%s\n", kcode.Code, count, mer, quality))
+						} else if showCodeOnly {
+							outfh.WriteString(fmt.Sprintf("%d\t%d\n", kcode.Code, count))
+						} else if showCode {
+							outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\n", mer, kcode.Code, count))
+						} else {
+							outfh.WriteString(fmt.Sprintf("%s\t%d\n", mer, count))
+						}
+						continue
+					}
+
 					// outfh.WriteString(fmt.Sprintf("%s\n", kcode.Bytes())) // slower
 					if outFasta {
-						outfh.WriteString(fmt.Sprintf(">%d\n%s\n", kcode.Code, kcode.String()))
+						outfh.WriteString(fmt.Sprintf(">%d\n%s\n", kcode.Code, mer))
 					} else if outFastq {
-						outfh.WriteString(fmt.Sprintf(">%d\n%s\n+\n%s\n", kcode.Code, kcode.String(), quality))
+						outfh.WriteString(fmt.Sprintf(">%d\n%s\n+\n%s\n", kcode.Code, mer, quality))
 					} else if showCodeOnly {
 						outfh.WriteString(fmt.Sprintf("%d\n", kcode.Code))
 					} else if showCode {
-						outfh.WriteString(fmt.Sprintf("%s\t%d\n", kcode.String(), kcode.Code))
+						outfh.WriteString(fmt.Sprintf("%s\t%d\n", mer, kcode.Code))
 					} else {
-						outfh.WriteString(kcode.String() + "\n")
+						outfh.WriteString(mer + "\n")
 					}
 				}
 
@@ -122,7 +196,7 @@ var viewCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(viewCmd)
 
-	viewCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	viewCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz or .zst for compressed out)`)
 	viewCmd.Flags().BoolP("show-code", "n", false, `show encoded integer along with k-mer`)
 	viewCmd.Flags().BoolP("show-code-only", "N", false, `only show encoded integers, faster than cutting from result of -n/--show-cde`)
 	viewCmd.Flags().BoolP("fasta", "a", false, `output in FASTA format, with encoded integer as FASTA header`)